@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/postgres"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
+)
+
+const (
+	keysUsage    = "usage: app keys list|rotate|retire <kid>"
+	migrateUsage = "usage: app migrate up|down [steps]|status"
+	rolesUsage   = "usage: app roles list <user>|grant <user> <role>|revoke <user> <role>"
+)
+
+type commands struct {
+	authService   *services.AuthService
+	ledgerService *services.LedgerService
+	migrator      *postgres.Migrator
+}
+
+func (cmds *commands) run(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "keys":
+		return cmds.runKeys(ctx, args[1:])
+	case "migrate":
+		return cmds.runMigrate(ctx, args[1:])
+	case "reconcile":
+		return cmds.runReconcile(ctx)
+	case "roles":
+		return cmds.runRoles(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func (cmds *commands) runKeys(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		keys, err := cmds.authService.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "KID\tACTIVE\tRETIRED\tCREATED AT")
+		for _, key := range keys {
+			fmt.Fprintf(writer, "%s\t%t\t%t\t%s\n", key.Id, key.Active, key.Retired, key.CreatedAt.Format(time.RFC3339))
+		}
+
+		return writer.Flush()
+	case "rotate":
+		kid, err := cmds.authService.RotateKey(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("active key: %s\n", kid)
+
+		return nil
+	case "retire":
+		if len(args) < 2 {
+			return errors.New(keysUsage)
+		}
+
+		err := cmds.authService.RetireKey(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("retired key: %s\n", args[1])
+
+		return nil
+	default:
+		return errors.New(keysUsage)
+	}
+}
+
+func (cmds *commands) runMigrate(ctx context.Context, args []string) error {
+	if cmds.migrator == nil {
+		return errors.New("migrations require postgres storage")
+	}
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := cmds.migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied: %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		reverted, err := cmds.migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted: %d_%s\n", migration.Version, migration.Name)
+		}
+
+		return err
+	case "status":
+		migrations, err := cmds.migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, migration := range migrations {
+			appliedAt := "pending"
+			if migration.AppliedAt != nil {
+				appliedAt = migration.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", migration.Version, migration.Name, appliedAt)
+		}
+
+		return writer.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// runRoles lets an operator grant the first admin role,
+// the next ones can be granted through the admin api.
+func (cmds *commands) runRoles(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New(rolesUsage)
+	}
+
+	switch args[0] {
+	case "list":
+	case "grant":
+		if len(args) < 3 {
+			return errors.New(rolesUsage)
+		}
+
+		err := cmds.authService.GrantRole(ctx, args[1], args[2])
+		if err != nil {
+			return err
+		}
+	case "revoke":
+		if len(args) < 3 {
+			return errors.New(rolesUsage)
+		}
+
+		err := cmds.authService.RevokeRole(ctx, args[1], args[2])
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New(rolesUsage)
+	}
+
+	roles, err := cmds.authService.GetRoles(ctx, args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s\n", args[1], strings.Join(roles, ", "))
+
+	return nil
+}
+
+// runReconcile prints the report as json and fails if any account drifts,
+// so that it can be run from cron or CI.
+func (cmds *commands) runReconcile(ctx context.Context) error {
+	report, err := cmds.ledgerService.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	if !report.IsClean() {
+		return fmt.Errorf("found %d drifting accounts, ledger total %d", len(report.Drifting), report.LedgerTotal)
+	}
+
+	return nil
+}
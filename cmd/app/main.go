@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -15,27 +16,25 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/config"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/handlers"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/file"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/memory"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/postgres"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
 )
 
-const backEndPort = 8080
-
 func main() {
-	var (
-		dbUser            string
-    	dbPassword        string
-   		sessionExpiration int
-	)
-
-	flag.StringVar(&dbUser, "dbuser", "postgres", "database user")
-	flag.StringVar(&dbPassword, "dbpass", "root1234", "database password")
-	flag.IntVar(&sessionExpiration, "exp", 3600, "session expiration time")
-
-	flag.Parse()
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("error in config loading: %v\n", err)
+	}
 
-	config := zap.Config{
+	loggerConfig := zap.Config{
 		Level:            zap.NewAtomicLevelAt(zapcore.DebugLevel),
 		Development:      true,
 		Encoding:         "console",
@@ -44,64 +43,310 @@ func main() {
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
-	logger, err := config.Build()
+	logger, err := loggerConfig.Build()
 	if err != nil {
 		log.Fatal(err)
 	}
 	sugarLogger := logger.Sugar()
 
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost",
-		"5432",
-		dbUser,
-		dbPassword,
-		"shop",
-	))
+	var (
+		authStorage    services.AuthStorage
+		shopStorage    services.ShopStorage
+		productStorage services.ProductStorage
+		ledgerStorage  services.LedgerStorage
+		tradeStorage   services.TradeStorage
+		keyStorage     services.KeyStorage
+		sessionStorage services.SessionStorage
+		migrator       *postgres.Migrator
+	)
+	switch cfg.Storage {
+	case "postgres":
+		poolConfig, err := pgxpool.ParseConfig(cfg.Database.ConnString())
+		if err != nil {
+			log.Fatalf("error in postgres initialization: %v\n", err)
+		}
+		poolConfig.MaxConns = int32(cfg.Database.MaxConns)
+		poolConfig.MinConns = int32(cfg.Database.MinConns)
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			log.Fatalf("error in postgres initialization: %v\n", err)
+		}
+		defer pool.Close()
+
+		migrator, err = postgres.NewMigrator(pool)
+		if err != nil {
+			log.Fatalf("error in migrator initialization: %v\n", err)
+		}
+
+		authStorage, err = postgres.NewAuthStorage(pool)
+		if err != nil {
+			log.Fatalf("error in auth storage initialization: %v\n", err)
+		}
+		postgresShopStorage, err := postgres.NewShopStorage(pool)
+		if err != nil {
+			log.Fatalf("error in shop storage initialization: %v\n", err)
+		}
+		shopStorage, productStorage = postgresShopStorage, postgresShopStorage
+		ledgerStorage, err = postgres.NewLedgerStorage(pool)
+		if err != nil {
+			log.Fatalf("error in ledger storage initialization: %v\n", err)
+		}
+		tradeStorage, err = postgres.NewTradeStorage(pool)
+		if err != nil {
+			log.Fatalf("error in trade storage initialization: %v\n", err)
+		}
+
+		sessionStorage, err = postgres.NewSessionStorage(pool)
+		if err != nil {
+			log.Fatalf("error in session storage initialization: %v\n", err)
+		}
+
+		if cfg.Auth.KeyStorage == "postgres" {
+			keyStorage, err = postgres.NewKeyStorage(pool)
+			if err != nil {
+				log.Fatalf("error in key storage initialization: %v\n", err)
+			}
+		}
+	case "memory":
+		db := memory.NewDB()
+
+		authStorage, err = memory.NewAuthStorage(db)
+		if err != nil {
+			log.Fatalf("error in auth storage initialization: %v\n", err)
+		}
+		memoryShopStorage, err := memory.NewShopStorage(db)
+		if err != nil {
+			log.Fatalf("error in shop storage initialization: %v\n", err)
+		}
+		shopStorage, productStorage = memoryShopStorage, memoryShopStorage
+		ledgerStorage, err = memory.NewLedgerStorage(db)
+		if err != nil {
+			log.Fatalf("error in ledger storage initialization: %v\n", err)
+		}
+		tradeStorage, err = memory.NewTradeStorage(db)
+		if err != nil {
+			log.Fatalf("error in trade storage initialization: %v\n", err)
+		}
+
+		sessionStorage, err = memory.NewSessionStorage()
+		if err != nil {
+			log.Fatalf("error in session storage initialization: %v\n", err)
+		}
+
+		// there is no database to keep the keys in
+		if cfg.Auth.KeyStorage == "postgres" {
+			keyStorage, err = memory.NewKeyStorage()
+			if err != nil {
+				log.Fatalf("error in key storage initialization: %v\n", err)
+			}
+		}
+	default:
+		log.Fatalf("unknown storage %q\n", cfg.Storage)
+	}
+
+	switch cfg.Auth.KeyStorage {
+	case "postgres":
+	case "file":
+		keyStorage, err = file.NewKeyStorage(cfg.Auth.KeyFile)
+	case "memory":
+		keyStorage, err = memory.NewKeyStorage()
+	default:
+		err = fmt.Errorf("unknown key storage %q", cfg.Auth.KeyStorage)
+	}
 	if err != nil {
-		log.Fatalf("error in postgres initialization: %v\n", err)
+		log.Fatalf("error in key storage initialization: %v\n", err)
 	}
 
-	authStorage, err := postgres.NewAuthStorage(pool)
+	authService, err := services.NewAuthService(
+		authStorage,
+		keyStorage,
+		sessionStorage,
+		sugarLogger,
+		10,
+		services.PasswordHashParams{
+			Memory:      uint32(cfg.Auth.Argon2Memory),
+			Iterations:  uint32(cfg.Auth.Argon2Iterations),
+			Parallelism: uint8(cfg.Auth.Argon2Parallelism),
+		},
+		cfg.Auth.HashConcurrency,
+		cfg.Auth.SessionExpiration,
+		cfg.Auth.RefreshExpiration,
+		cfg.Auth.PasswordResetExpiration,
+		cfg.Auth.AutoSignup)
 	if err != nil {
-		log.Fatalf("error in auth storage initialization: %v\n", err)
+		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
-	shopStorage, err := postgres.NewShopStorage(pool)
+
+	ledgerService, err := services.NewLedgerService(ledgerStorage, sugarLogger)
 	if err != nil {
-		log.Fatalf("error in shop storage initialization: %v\n", err)
+		log.Fatalf("error in ledger service initialization: %v\n", err)
 	}
 
-	authService, err := services.NewAuthService(authStorage, sugarLogger, 10, sessionExpiration)
+	if len(args) > 0 {
+		cmds := &commands{
+			authService:   authService,
+			ledgerService: ledgerService,
+			migrator:      migrator,
+		}
+		err = cmds.run(context.Background(), args)
+		if err != nil {
+			log.Fatalf("error in command execution: %v\n", err)
+		}
+		return
+	}
+
+	if migrator != nil && cfg.Database.Migrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("error in database migration: %v\n", err)
+		}
+		for _, migration := range applied {
+			sugarLogger.Infof("applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
+
+	err = authService.LoadKeys(context.Background())
 	if err != nil {
-		log.Fatalf("error in auth service initialization: %v\n", err)
+		log.Fatalf("error in signing keys loading: %v\n", err)
 	}
 
-	shopService, err := services.NewShopService(shopStorage, sugarLogger)
+	keysCtx, stopKeysWatcher := context.WithCancel(context.Background())
+	defer stopKeysWatcher()
+	go authService.WatchKeys(keysCtx, time.Duration(cfg.Auth.KeysReloadInterval)*time.Second)
+
+	err = authService.LoadRevocations(context.Background())
+	if err != nil {
+		log.Fatalf("error in revoked sessions loading: %v\n", err)
+	}
+
+	revocationsCtx, stopRevocationsWatcher := context.WithCancel(context.Background())
+	defer stopRevocationsWatcher()
+	go authService.WatchRevocations(revocationsCtx, time.Duration(cfg.Auth.RevocationsReloadInterval)*time.Second)
+
+	if cfg.Shop.ReconcileInterval > 0 {
+		reconcileCtx, stopReconciliation := context.WithCancel(context.Background())
+		defer stopReconciliation()
+		go ledgerService.WatchBalances(reconcileCtx, time.Duration(cfg.Shop.ReconcileInterval)*time.Second)
+	}
+
+	shopService, err := services.NewShopService(
+		shopStorage,
+		sugarLogger,
+		time.Duration(cfg.Shop.IdempotencyTTL)*time.Second,
+		time.Duration(cfg.Shop.RefundWindow)*time.Second)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	authHandler, err := handlers.NewAuthHandler(authService, sugarLogger, sessionExpiration)
+	productService, err := services.NewProductService(productStorage, sugarLogger)
+	if err != nil {
+		log.Fatalf("error in product service initialization: %v\n", err)
+	}
+
+	tradeService, err := services.NewTradeService(
+		tradeStorage,
+		sugarLogger,
+		time.Duration(cfg.Shop.TradeTTL)*time.Second)
+	if err != nil {
+		log.Fatalf("error in trade service initialization: %v\n", err)
+	}
+
+	loginThrottle, err := services.NewLoginThrottle(
+		sugarLogger,
+		cfg.Auth.LoginAttempts,
+		cfg.Auth.ClientLoginAttempts,
+		time.Duration(cfg.Auth.MaxLockout)*time.Second)
+	if err != nil {
+		log.Fatalf("error in login throttle initialization: %v\n", err)
+	}
+
+	authHandler, err := handlers.NewAuthHandler(authService, loginThrottle, sugarLogger, cfg.Auth.SecureCookies)
 	if err != nil {
 		log.Fatalf("error in auth handler initialization: %v\n", err)
 	}
-	shopHandler, err := handlers.NewShopHandler(authService, shopService, sugarLogger)
+	shopHandler, err := handlers.NewShopHandler(shopService, sugarLogger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
+	productHandler, err := handlers.NewProductHandler(productService, sugarLogger)
+	if err != nil {
+		log.Fatalf("error in product handler initialization: %v\n", err)
+	}
+	tradeHandler, err := handlers.NewTradeHandler(tradeService, sugarLogger)
+	if err != nil {
+		log.Fatalf("error in trade handler initialization: %v\n", err)
+	}
+	authMiddleware, err := handlers.NewAuthMiddleware(authService, sugarLogger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	roleHandler, err := handlers.NewRoleHandler(authService, sugarLogger)
+	if err != nil {
+		log.Fatalf("error in role handler initialization: %v\n", err)
+	}
+
+	withPermission := func(permission string, handler http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(authMiddleware.RequirePermission(permission, handler))
+	}
 
 	router := http.NewServeMux()
 
-	router.HandleFunc("GET /api/info", shopHandler.Info)
+	router.Handle("GET /api/info", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)))
 	router.HandleFunc("POST /api/auth", authHandler.Auth)
-	router.HandleFunc("POST /api/sendCoin", shopHandler.SendCoin)
-	router.HandleFunc("GET /api/buy/{item}", shopHandler.BuyItem)
+	router.HandleFunc("POST /api/auth/refresh", authHandler.Refresh)
+	router.HandleFunc("POST /api/register", authHandler.Register)
+	router.HandleFunc("POST /api/login", authHandler.Login)
+	router.Handle("POST /api/logout", authMiddleware.Authenticate(http.HandlerFunc(authHandler.Logout)))
+	router.Handle("POST /api/password", authMiddleware.Authenticate(http.HandlerFunc(authHandler.ChangePassword)))
+	router.HandleFunc("POST /api/password/reset", authHandler.ResetPassword)
+	router.Handle("POST /api/sendCoin", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.SendCoin)))
+	router.Handle("POST /api/buy/{item}", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem)))
+	if cfg.Shop.LegacyBuyRoute {
+		router.Handle("GET /api/buy/{item}",
+			handlers.Deprecated(authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem))))
+	}
+	router.Handle("POST /api/buy", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyCart)))
+	router.Handle("POST /api/gift", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Gift)))
+	router.Handle("POST /api/purchases/{id}/refund",
+		authMiddleware.Authenticate(http.HandlerFunc(shopHandler.RefundPurchase)))
+	router.Handle("GET /api/history", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.History)))
+	router.Handle("POST /api/trades", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Propose)))
+	router.Handle("GET /api/trades", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.List)))
+	router.Handle("GET /api/trades/{id}", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Get)))
+	router.Handle("POST /api/trades/{id}/accept", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Accept)))
+	router.Handle("POST /api/trades/{id}/decline", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Decline)))
+	router.HandleFunc("GET /api/items", shopHandler.Items)
+	router.HandleFunc("GET /api/items/{name}", shopHandler.Item)
+
+	router.Handle("POST /api/admin/items",
+		withPermission(domain.PermissionManageCatalog, productHandler.Create))
+	router.Handle("PATCH /api/admin/items/{name}",
+		withPermission(domain.PermissionManageCatalog, productHandler.Update))
+	router.Handle("POST /api/admin/items/{name}/archive",
+		withPermission(domain.PermissionManageCatalog, productHandler.Archive))
+	router.Handle("PUT /api/admin/items/{name}/price",
+		withPermission(domain.PermissionManageCatalog, productHandler.Reprice))
+	router.Handle("GET /api/admin/items/{name}/audit",
+		withPermission(domain.PermissionViewCatalog, productHandler.Audit))
+
+	router.Handle("GET /api/admin/users/{name}/roles",
+		withPermission(domain.PermissionViewRoles, roleHandler.Get))
+	router.Handle("PUT /api/admin/users/{name}/roles/{role}",
+		withPermission(domain.PermissionManageRoles, roleHandler.Grant))
+	router.Handle("DELETE /api/admin/users/{name}/roles/{role}",
+		withPermission(domain.PermissionManageRoles, roleHandler.Revoke))
+	router.Handle("DELETE /api/admin/users/{name}/sessions",
+		withPermission(domain.PermissionManageSessions, authHandler.RevokeSessions))
+	router.Handle("POST /api/admin/users/{name}/password-reset",
+		withPermission(domain.PermissionResetPasswords, authHandler.CreatePasswordReset))
 
 	server := &http.Server{
 		Handler:      router,
-		Addr:         fmt.Sprintf(":%d", backEndPort),
-		ReadTimeout:  time.Second,
-		WriteTimeout: time.Second,
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
 	stopped := make(chan struct{})
@@ -117,7 +362,7 @@ func main() {
 		}
 	}()
 
-	fmt.Printf("Starting server at %s%s\n", "localhost", fmt.Sprintf(":%d", backEndPort))
+	fmt.Printf("Starting server at %s%s\n", "localhost", fmt.Sprintf(":%d", cfg.Server.Port))
 
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatal(err)
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+const configEnv = "APP_CONFIG"
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+	MaxConns int    `yaml:"max_conns"`
+	MinConns int    `yaml:"min_conns"`
+	Migrate  bool   `yaml:"migrate"`
+}
+
+type ServerConfig struct {
+	Port         int           `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+}
+
+type AuthConfig struct {
+	// SessionExpiration is the lifetime of an access token,
+	// the session itself lasts until its refresh token expires.
+	SessionExpiration  int    `yaml:"session_expiration"`
+	RefreshExpiration  int    `yaml:"refresh_expiration"`
+	KeyStorage         string `yaml:"key_storage"`
+	KeyFile            string `yaml:"key_file"`
+	KeysReloadInterval int    `yaml:"keys_reload_interval"`
+	// RevocationsReloadInterval bounds the time a token revoked on one replica
+	// is still accepted by the others.
+	RevocationsReloadInterval int `yaml:"revocations_reload_interval"`
+	// AutoSignup lets POST /api/auth create unknown users,
+	// otherwise accounts are created only by POST /api/register.
+	AutoSignup bool `yaml:"auto_signup"`
+	// SecureCookies should be disabled only for local development over plain http.
+	SecureCookies bool `yaml:"secure_cookies"`
+	// PasswordResetExpiration is the time the user has to redeem a reset token issued by an admin.
+	PasswordResetExpiration int `yaml:"password_reset_expiration"`
+	// Argon2 parameters of new password hashes, the hashes made with
+	// other parameters are upgraded when their users log in.
+	Argon2Memory      int `yaml:"argon2_memory"`
+	Argon2Iterations  int `yaml:"argon2_iterations"`
+	Argon2Parallelism int `yaml:"argon2_parallelism"`
+	// HashConcurrency is the number of passwords hashed at once,
+	// the requests that find all of them busy are rejected with 429.
+	HashConcurrency int `yaml:"hash_concurrency"`
+	// LoginAttempts and ClientLoginAttempts are the failed logins in a row allowed
+	// for a user name and for a client address before they are locked out.
+	LoginAttempts       int `yaml:"login_attempts"`
+	ClientLoginAttempts int `yaml:"client_login_attempts"`
+	MaxLockout          int `yaml:"max_lockout"`
+}
+
+type ShopConfig struct {
+	IdempotencyTTL    int `yaml:"idempotency_ttl"`
+	ReconcileInterval int `yaml:"reconcile_interval"`
+	// RefundWindow is the time after a purchase during which it can be refunded.
+	RefundWindow int `yaml:"refund_window"`
+	// TradeTTL is the time after which an unanswered trade offer expires.
+	TradeTTL int `yaml:"trade_ttl"`
+	// LegacyBuyRoute keeps serving purchases on the deprecated GET /api/buy/{item}.
+	LegacyBuyRoute bool `yaml:"legacy_buy_route"`
+}
+
+type Config struct {
+	Storage  string         `yaml:"storage"`
+	Database DatabaseConfig `yaml:"database"`
+	Server   ServerConfig   `yaml:"server"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Shop     ShopConfig     `yaml:"shop"`
+}
+
+func Default() Config {
+	return Config{
+		Storage: "postgres",
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: "root1234",
+			Name:     "shop",
+			SSLMode:  "disable",
+			MaxConns: 10,
+			MinConns: 0,
+		},
+		Server: ServerConfig{
+			Port:         8080,
+			ReadTimeout:  time.Second,
+			WriteTimeout: time.Second,
+		},
+		Auth: AuthConfig{
+			SessionExpiration:         900,
+			RefreshExpiration:         2592000,
+			PasswordResetExpiration:   86400,
+			Argon2Memory:              64 * 1024,
+			Argon2Iterations:          1,
+			Argon2Parallelism:         4,
+			HashConcurrency:           4,
+			LoginAttempts:             5,
+			ClientLoginAttempts:       50,
+			MaxLockout:                900,
+			KeyStorage:                "postgres",
+			KeyFile:                   "jwt_keys.json",
+			KeysReloadInterval:        30,
+			RevocationsReloadInterval: 5,
+			AutoSignup:                true,
+			SecureCookies:             true,
+		},
+		Shop: ShopConfig{
+			IdempotencyTTL: 86400,
+			RefundWindow:   86400,
+			TradeTTL:       86400,
+			LegacyBuyRoute: true,
+		},
+	}
+}
+
+type option struct {
+	flag  string
+	env   string
+	usage string
+	field func(cfg *Config) any
+}
+
+var options = []option{
+	{"storage", "APP_STORAGE", "storage backend (postgres or memory)",
+		func(cfg *Config) any { return &cfg.Storage }},
+	{"dbhost", "APP_DB_HOST", "database host",
+		func(cfg *Config) any { return &cfg.Database.Host }},
+	{"dbport", "APP_DB_PORT", "database port",
+		func(cfg *Config) any { return &cfg.Database.Port }},
+	{"dbuser", "APP_DB_USER", "database user",
+		func(cfg *Config) any { return &cfg.Database.User }},
+	{"dbpass", "APP_DB_PASSWORD", "database password",
+		func(cfg *Config) any { return &cfg.Database.Password }},
+	{"dbname", "APP_DB_NAME", "database name",
+		func(cfg *Config) any { return &cfg.Database.Name }},
+	{"dbsslmode", "APP_DB_SSLMODE", "database sslmode",
+		func(cfg *Config) any { return &cfg.Database.SSLMode }},
+	{"dbmaxconns", "APP_DB_MAX_CONNS", "maximum size of the database pool",
+		func(cfg *Config) any { return &cfg.Database.MaxConns }},
+	{"dbminconns", "APP_DB_MIN_CONNS", "minimum size of the database pool",
+		func(cfg *Config) any { return &cfg.Database.MinConns }},
+	{"migrate", "APP_DB_MIGRATE", "apply pending database migrations on startup",
+		func(cfg *Config) any { return &cfg.Database.Migrate }},
+	{"port", "APP_SERVER_PORT", "http server port",
+		func(cfg *Config) any { return &cfg.Server.Port }},
+	{"readtimeout", "APP_SERVER_READ_TIMEOUT", "http server read timeout",
+		func(cfg *Config) any { return &cfg.Server.ReadTimeout }},
+	{"writetimeout", "APP_SERVER_WRITE_TIMEOUT", "http server write timeout",
+		func(cfg *Config) any { return &cfg.Server.WriteTimeout }},
+	{"exp", "APP_SESSION_EXPIRATION", "access token expiration time in seconds",
+		func(cfg *Config) any { return &cfg.Auth.SessionExpiration }},
+	{"refreshexp", "APP_REFRESH_EXPIRATION", "refresh token expiration time in seconds",
+		func(cfg *Config) any { return &cfg.Auth.RefreshExpiration }},
+	{"resetexp", "APP_PASSWORD_RESET_EXPIRATION", "password reset token expiration time in seconds",
+		func(cfg *Config) any { return &cfg.Auth.PasswordResetExpiration }},
+	{"keystore", "APP_KEY_STORAGE", "jwt signing keys storage (postgres, file or memory)",
+		func(cfg *Config) any { return &cfg.Auth.KeyStorage }},
+	{"keyfile", "APP_KEY_FILE", "jwt signing keys file for file key storage",
+		func(cfg *Config) any { return &cfg.Auth.KeyFile }},
+	{"keysreload", "APP_KEYS_RELOAD_INTERVAL", "jwt signing keys reload interval in seconds",
+		func(cfg *Config) any { return &cfg.Auth.KeysReloadInterval }},
+	{"revocationsreload", "APP_REVOCATIONS_RELOAD_INTERVAL", "revoked sessions reload interval in seconds",
+		func(cfg *Config) any { return &cfg.Auth.RevocationsReloadInterval }},
+	{"autosignup", "APP_AUTO_SIGNUP", "create unknown users on POST /api/auth",
+		func(cfg *Config) any { return &cfg.Auth.AutoSignup }},
+	{"securecookies", "APP_SECURE_COOKIES", "set the Secure attribute on auth cookies",
+		func(cfg *Config) any { return &cfg.Auth.SecureCookies }},
+	{"argon2memory", "APP_ARGON2_MEMORY", "argon2id memory of new password hashes in KiB",
+		func(cfg *Config) any { return &cfg.Auth.Argon2Memory }},
+	{"argon2iterations", "APP_ARGON2_ITERATIONS", "argon2id iterations of new password hashes",
+		func(cfg *Config) any { return &cfg.Auth.Argon2Iterations }},
+	{"argon2parallelism", "APP_ARGON2_PARALLELISM", "argon2id parallelism of new password hashes",
+		func(cfg *Config) any { return &cfg.Auth.Argon2Parallelism }},
+	{"hashconcurrency", "APP_HASH_CONCURRENCY", "number of passwords hashed concurrently",
+		func(cfg *Config) any { return &cfg.Auth.HashConcurrency }},
+	{"loginattempts", "APP_LOGIN_ATTEMPTS", "failed logins allowed for a user name before lockout",
+		func(cfg *Config) any { return &cfg.Auth.LoginAttempts }},
+	{"clientloginattempts", "APP_CLIENT_LOGIN_ATTEMPTS", "failed logins allowed for a client address before lockout",
+		func(cfg *Config) any { return &cfg.Auth.ClientLoginAttempts }},
+	{"maxlockout", "APP_MAX_LOCKOUT", "maximum login lockout in seconds",
+		func(cfg *Config) any { return &cfg.Auth.MaxLockout }},
+	{"idempotencyttl", "APP_IDEMPOTENCY_TTL", "idempotency keys expiration time in seconds",
+		func(cfg *Config) any { return &cfg.Shop.IdempotencyTTL }},
+	{"reconcileinterval", "APP_RECONCILE_INTERVAL", "balance reconciliation interval in seconds, 0 disables it",
+		func(cfg *Config) any { return &cfg.Shop.ReconcileInterval }},
+	{"refundwindow", "APP_REFUND_WINDOW", "time after a purchase during which it can be refunded in seconds",
+		func(cfg *Config) any { return &cfg.Shop.RefundWindow }},
+	{"tradettl", "APP_TRADE_TTL", "trade offers expiration time in seconds",
+		func(cfg *Config) any { return &cfg.Shop.TradeTTL }},
+	{"legacybuy", "APP_LEGACY_BUY_ROUTE", "serve purchases on the deprecated GET /api/buy/{item}",
+		func(cfg *Config) any { return &cfg.Shop.LegacyBuyRoute }},
+}
+
+// Load builds the configuration from defaults, an optional yaml file, environment
+// variables and command line flags, each source overriding the previous one.
+// It returns the arguments left after the flags.
+func Load(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+
+	// the first pass only finds out the config file path, parsing errors
+	// are reported by the second one
+	var configPath string
+	fs := newFlagSet(&Config{}, &configPath)
+	fs.SetOutput(io.Discard)
+	_ = fs.Parse(args)
+	if configPath == "" {
+		configPath = getenv(configEnv)
+	}
+
+	if configPath != "" {
+		err := loadFile(&cfg, configPath)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("(config.Load): %w", err)
+		}
+	}
+
+	err := loadEnv(&cfg, getenv)
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("(config.Load): %w", err)
+	}
+
+	// flags are bound to the loaded config, so only explicitly set flags override it
+	fs = newFlagSet(&cfg, &configPath)
+	err = fs.Parse(args)
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("%w (config.Load): %w", customErrors.ErrDataNotValid, err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("(config.Load): %w", err)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w (config.Validate): "+format,
+				append([]any{customErrors.ErrDataNotValid}, args...)...))
+		}
+	}
+
+	switch cfg.Storage {
+	case "postgres":
+	case "memory":
+		check(!cfg.Database.Migrate, "migrations require postgres storage")
+	default:
+		check(false, "unknown storage %q", cfg.Storage)
+	}
+
+	check(cfg.Database.Host != "", "database host is empty")
+	check(cfg.Database.Port > 0 && cfg.Database.Port <= 65535, "invalid database port %d", cfg.Database.Port)
+	check(cfg.Database.User != "", "database user is empty")
+	check(cfg.Database.Name != "", "database name is empty")
+	switch cfg.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		check(false, "unknown database sslmode %q", cfg.Database.SSLMode)
+	}
+	check(cfg.Database.MaxConns > 0, "database max conns must be positive")
+	check(cfg.Database.MinConns >= 0 && cfg.Database.MinConns <= cfg.Database.MaxConns,
+		"database min conns must be between 0 and max conns")
+
+	check(cfg.Server.Port > 0 && cfg.Server.Port <= 65535, "invalid server port %d", cfg.Server.Port)
+	check(cfg.Server.ReadTimeout > 0, "server read timeout must be positive")
+	check(cfg.Server.WriteTimeout > 0, "server write timeout must be positive")
+
+	check(cfg.Auth.SessionExpiration > 0, "session expiration must be positive")
+	check(cfg.Auth.RefreshExpiration > cfg.Auth.SessionExpiration,
+		"refresh expiration must be greater than session expiration")
+	check(cfg.Auth.PasswordResetExpiration > 0, "password reset expiration must be positive")
+	check(cfg.Auth.Argon2Iterations > 0, "argon2 iterations must be positive")
+	check(cfg.Auth.Argon2Parallelism > 0 && cfg.Auth.Argon2Parallelism <= 255,
+		"argon2 parallelism must be between 1 and 255")
+	check(cfg.Auth.Argon2Memory >= 8*cfg.Auth.Argon2Parallelism,
+		"argon2 memory must be at least 8 KiB per thread")
+	check(cfg.Auth.HashConcurrency > 0, "hash concurrency must be positive")
+	check(cfg.Auth.LoginAttempts > 0, "login attempts must be positive")
+	check(cfg.Auth.ClientLoginAttempts > 0, "client login attempts must be positive")
+	check(cfg.Auth.MaxLockout > 0, "max lockout must be positive")
+	switch cfg.Auth.KeyStorage {
+	case "postgres", "memory":
+	case "file":
+		check(cfg.Auth.KeyFile != "", "key file is empty")
+	default:
+		check(false, "unknown key storage %q", cfg.Auth.KeyStorage)
+	}
+	check(cfg.Auth.KeysReloadInterval > 0, "keys reload interval must be positive")
+	check(cfg.Auth.RevocationsReloadInterval > 0, "revocations reload interval must be positive")
+
+	check(cfg.Shop.IdempotencyTTL > 0, "idempotency ttl must be positive")
+	check(cfg.Shop.ReconcileInterval >= 0, "reconcile interval must not be negative")
+	check(cfg.Shop.RefundWindow > 0, "refund window must be positive")
+	check(cfg.Shop.TradeTTL > 0, "trade ttl must be positive")
+
+	return errors.Join(errs...)
+}
+
+func (cfg *DatabaseConfig) ConnString() string {
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+
+	return connURL.String()
+}
+
+func newFlagSet(cfg *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.StringVar(configPath, "config", "", "path to yaml config file")
+
+	for _, opt := range options {
+		switch field := opt.field(cfg).(type) {
+		case *string:
+			fs.StringVar(field, opt.flag, *field, opt.usage)
+		case *int:
+			fs.IntVar(field, opt.flag, *field, opt.usage)
+		case *bool:
+			fs.BoolVar(field, opt.flag, *field, opt.usage)
+		case *time.Duration:
+			fs.DurationVar(field, opt.flag, *field, opt.usage)
+		}
+	}
+
+	return fs
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w (config.loadFile): %w", customErrors.ErrDataNotValid, err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w (config.loadFile): %s: %w", customErrors.ErrDataNotValid, path, err)
+	}
+
+	return nil
+}
+
+func loadEnv(cfg *Config, getenv func(string) string) error {
+	for _, opt := range options {
+		value := getenv(opt.env)
+		if value == "" {
+			continue
+		}
+
+		var err error
+		switch field := opt.field(cfg).(type) {
+		case *string:
+			*field = value
+		case *int:
+			*field, err = strconv.Atoi(value)
+		case *bool:
+			*field, err = strconv.ParseBool(value)
+		case *time.Duration:
+			*field, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return fmt.Errorf("%w (config.loadEnv): %s: %w", customErrors.ErrDataNotValid, opt.env, err)
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package domain
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
 )
@@ -17,9 +19,159 @@ func (userCredantialsLog *UserCredantials) Validate() error {
 		return fmt.Errorf("%w (Validate): incorrect name length", customErrors.ErrDataNotValid)
 	}
 
-	if len(userCredantialsLog.Password) < 6 {
-		return fmt.Errorf("%w (Validate): incorrect password length", customErrors.ErrDataNotValid)
+	return ValidatePassword(userCredantialsLog.Password)
+}
+
+func ValidatePassword(password string) error {
+	if len(password) < 6 {
+		return fmt.Errorf("%w (ValidatePassword): incorrect password length", customErrors.ErrDataNotValid)
 	}
 
 	return nil
 }
+
+type User struct {
+	Id    int
+	Name  string
+	Roles []string
+}
+
+const (
+	// RoleAdmin manages the shop and the roles of other users.
+	RoleAdmin = "admin"
+	// RoleSupport investigates user issues and can only read.
+	RoleSupport = "support"
+	// RoleService is granted to accounts of other services and automation.
+	RoleService = "service"
+)
+
+const (
+	PermissionManageCatalog  = "catalog:manage"
+	PermissionViewCatalog    = "catalog:view"
+	PermissionManageRoles    = "roles:manage"
+	PermissionViewRoles      = "roles:view"
+	PermissionManageSessions = "sessions:manage"
+	PermissionResetPasswords = "passwords:reset"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionManageCatalog,
+		PermissionViewCatalog,
+		PermissionManageRoles,
+		PermissionViewRoles,
+		PermissionManageSessions,
+		PermissionResetPasswords,
+	},
+	RoleSupport: {
+		PermissionViewCatalog,
+		PermissionViewRoles,
+	},
+	RoleService: {
+		PermissionManageCatalog,
+		PermissionViewCatalog,
+	},
+}
+
+func ValidateRole(role string) error {
+	if _, ok := rolePermissions[role]; !ok {
+		return fmt.Errorf("%w (ValidateRole): unknown role %q", customErrors.ErrDataNotValid, role)
+	}
+
+	return nil
+}
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserId    int
+	Name      string
+	Roles     []string
+	TokenId   string
+	SessionId string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// HasPermission checks whether any role of the principal grants the permission,
+// roles unknown to this version of the service grant nothing.
+func (principal *Principal) HasPermission(permission string) bool {
+	for _, role := range principal.Roles {
+		if slices.Contains(rolePermissions[role], permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tokens are issued on login and on refresh. The access token is a short-lived
+// jwt, the refresh token is an opaque string exchanged for new tokens.
+type Tokens struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// RefreshToken is the stored state of a refresh token, only its hash is kept.
+// The tokens obtained by rotating the token issued on login form a family,
+// which is the session the access tokens refer to.
+type RefreshToken struct {
+	Hash      string
+	FamilyId  string
+	UserId    int
+	UserName  string
+	ExpiresAt time.Time
+}
+
+// PasswordReset lets the user set a new password without the old one,
+// only the hash of the token given to the user is stored.
+type PasswordReset struct {
+	Hash      string
+	UserId    int
+	ExpiresAt time.Time
+}
+
+type PasswordResetToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// RevokedToken is a logged out token, it is kept until it expires.
+type RevokedToken struct {
+	Id        string
+	UserId    int
+	ExpiresAt time.Time
+}
+
+// Revocations are the revoked sessions. Besides single tokens, all the tokens
+// of a user issued before the cutoff of the user are revoked, as well as all the tokens
+// of the sessions ended at the time kept in Sessions.
+type Revocations struct {
+	Tokens   map[string]time.Time
+	Cutoffs  map[int]time.Time
+	Sessions map[string]time.Time
+}
+
+// IsRevoked checks the token of the principal. The issue time of the tokens
+// has sub-second precision, so a login right after the cutoff is not revoked.
+func (revocations *Revocations) IsRevoked(principal Principal) bool {
+	if _, ok := revocations.Tokens[principal.TokenId]; ok && principal.TokenId != "" {
+		return true
+	}
+	if _, ok := revocations.Sessions[principal.SessionId]; ok && principal.SessionId != "" {
+		return true
+	}
+
+	cutoff, ok := revocations.Cutoffs[principal.UserId]
+
+	return ok && !principal.IssuedAt.After(cutoff)
+}
+
+type SigningKey struct {
+	Id        string    `json:"kid"`
+	Secret    []byte    `json:"secret"`
+	Active    bool      `json:"active"`
+	Retired   bool      `json:"retired"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// InfoHistoryLimit caps the coin history embedded into the inventory info,
+// the full history is available page by page.
+const InfoHistoryLimit = 100
+
+const (
+	HistoryDirectionSent     = "sent"
+	HistoryDirectionReceived = "received"
+
+	DefaultHistoryLimit = 20
+	MaxHistoryLimit     = 100
+)
+
+// HistoryCursor points at the last entry of a page, entries are ordered
+// by (SentAt, Id) descending.
+type HistoryCursor struct {
+	SentAt time.Time
+	Id     int64
+}
+
+func (cursor HistoryCursor) Encode() string {
+	value := strconv.FormatInt(cursor.SentAt.UnixMicro(), 10) + ":" + strconv.FormatInt(cursor.Id, 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func DecodeHistoryCursor(encoded string) (HistoryCursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return HistoryCursor{}, fmt.Errorf("%w (DecodeHistoryCursor): %w", customErrors.ErrDataNotValid, err)
+	}
+
+	sentAt, id, found := strings.Cut(string(value), ":")
+	if !found {
+		return HistoryCursor{}, fmt.Errorf("%w (DecodeHistoryCursor): malformed cursor", customErrors.ErrDataNotValid)
+	}
+
+	micros, err := strconv.ParseInt(sentAt, 10, 64)
+	if err != nil {
+		return HistoryCursor{}, fmt.Errorf("%w (DecodeHistoryCursor): %w", customErrors.ErrDataNotValid, err)
+	}
+
+	cursor := HistoryCursor{SentAt: time.UnixMicro(micros).UTC()}
+	cursor.Id, err = strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return HistoryCursor{}, fmt.Errorf("%w (DecodeHistoryCursor): %w", customErrors.ErrDataNotValid, err)
+	}
+
+	return cursor, nil
+}
+
+// HistoryFilter selects a page of the coin history, zero values do not filter.
+// The date range includes From and excludes To.
+type HistoryFilter struct {
+	Direction    string
+	Counterparty string
+	MinAmount    *int
+	MaxAmount    *int
+	From         time.Time
+	To           time.Time
+	Cursor       *HistoryCursor
+	Limit        int
+}
+
+func (filter *HistoryFilter) Validate() error {
+	switch filter.Direction {
+	case "", HistoryDirectionSent, HistoryDirectionReceived:
+	default:
+		return fmt.Errorf("%w (Validate): unknown direction %q", customErrors.ErrDataNotValid, filter.Direction)
+	}
+
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return fmt.Errorf("%w (Validate): min amount is greater than max amount", customErrors.ErrDataNotValid)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return fmt.Errorf("%w (Validate): incorrect date range", customErrors.ErrDataNotValid)
+	}
+
+	if filter.Limit < 1 || filter.Limit > MaxHistoryLimit {
+		return fmt.Errorf("%w (Validate): limit must be between 1 and %d", customErrors.ErrDataNotValid, MaxHistoryLimit)
+	}
+
+	return nil
+}
+
+// HistoryEntry is a transfer of coins or one side of an accepted trade,
+// the items given in a trade are listed by the trade itself.
+type HistoryEntry struct {
+	Id           int64     `json:"id"`
+	Direction    string    `json:"direction"`
+	Counterparty string    `json:"counterparty"`
+	Amount       int       `json:"amount"`
+	SentAt       time.Time `json:"sentAt"`
+	TradeId      *int64    `json:"tradeId,omitempty"`
+}
+
+type HistoryPage struct {
+	Entries    []HistoryEntry `json:"entries"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"fmt"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// SignupGrant is the amount of coins every new user receives from the mint.
+const SignupGrant = 1000
+
+const (
+	LedgerAccountUser    = "user"
+	LedgerAccountRevenue = "revenue"
+	LedgerAccountMint    = "mint"
+)
+
+const (
+	LedgerEntryOpening    = "opening"
+	LedgerEntrySignup     = "signup"
+	LedgerEntryTransfer   = "transfer"
+	LedgerEntryPurchase   = "purchase"
+	LedgerEntryAdjustment = "adjustment"
+	LedgerEntryRefund     = "refund"
+)
+
+// LedgerAccount is either a user account or one of the system accounts.
+// The mint issues coins, so its balance is negative, and the shop revenue
+// account collects the coins spent on purchases.
+type LedgerAccount struct {
+	Kind   string `json:"kind"`
+	UserId int    `json:"userId,omitempty"`
+}
+
+var (
+	MintAccount    = LedgerAccount{Kind: LedgerAccountMint}
+	RevenueAccount = LedgerAccount{Kind: LedgerAccountRevenue}
+)
+
+func UserAccount(userId int) LedgerAccount {
+	return LedgerAccount{Kind: LedgerAccountUser, UserId: userId}
+}
+
+type LedgerPosting struct {
+	Account LedgerAccount
+	Amount  int
+}
+
+// LedgerEntry is a single movement of coins, its postings always sum to zero.
+type LedgerEntry struct {
+	Kind     string
+	Postings []LedgerPosting
+}
+
+// NewLedgerTransfer moves the amount of coins from one account to another.
+func NewLedgerTransfer(kind string, from LedgerAccount, to LedgerAccount, amount int) LedgerEntry {
+	return LedgerEntry{
+		Kind: kind,
+		Postings: []LedgerPosting{
+			{Account: from, Amount: -amount},
+			{Account: to, Amount: amount},
+		},
+	}
+}
+
+func (entry *LedgerEntry) Validate() error {
+	switch entry.Kind {
+	case LedgerEntryOpening, LedgerEntrySignup, LedgerEntryTransfer, LedgerEntryPurchase, LedgerEntryAdjustment,
+		LedgerEntryRefund:
+	default:
+		return fmt.Errorf("%w (Validate): unknown ledger entry kind %q", customErrors.ErrDataNotValid, entry.Kind)
+	}
+
+	if len(entry.Postings) < 2 {
+		return fmt.Errorf("%w (Validate): ledger entry needs at least two postings", customErrors.ErrDataNotValid)
+	}
+
+	sum := 0
+	for _, posting := range entry.Postings {
+		switch posting.Account.Kind {
+		case LedgerAccountUser:
+			if posting.Account.UserId == 0 {
+				return fmt.Errorf("%w (Validate): user account without user", customErrors.ErrDataNotValid)
+			}
+		case LedgerAccountRevenue, LedgerAccountMint:
+			if posting.Account.UserId != 0 {
+				return fmt.Errorf("%w (Validate): system account with user", customErrors.ErrDataNotValid)
+			}
+		default:
+			return fmt.Errorf("%w (Validate): unknown ledger account kind %q",
+				customErrors.ErrDataNotValid, posting.Account.Kind)
+		}
+
+		sum += posting.Amount
+	}
+
+	if sum != 0 {
+		return fmt.Errorf("%w (Validate): ledger entry is not balanced", customErrors.ErrDataNotValid)
+	}
+
+	return nil
+}
+
+// AccountBalance compares the balance derived from the ledger with the
+// balance cached in the user row. System accounts have no cached balance.
+type AccountBalance struct {
+	Account  LedgerAccount `json:"account"`
+	UserName string        `json:"userName,omitempty"`
+	Balance  int           `json:"balance"`
+	Cached   *int          `json:"cached,omitempty"`
+}
+
+func (balance *AccountBalance) IsConsistent() bool {
+	return balance.Cached == nil || *balance.Cached == balance.Balance
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+const (
+	ProductActionCreate  = "create"
+	ProductActionUpdate  = "update"
+	ProductActionArchive = "archive"
+	ProductActionReprice = "reprice"
+)
+
+type Product struct {
+	Name      string `json:"name"`
+	Price     int    `json:"price"`
+	Available bool   `json:"available"`
+}
+
+func (product *Product) Validate() error {
+	if err := validateProductName(product.Name); err != nil {
+		return err
+	}
+
+	return validateProductPrice(product.Price)
+}
+
+// ProductUpdate changes the given fields of a product on behalf of an admin,
+// nil fields stay as they are.
+type ProductUpdate struct {
+	Actor     string
+	Action    string
+	Name      *string
+	Price     *int
+	Available *bool
+}
+
+func (update *ProductUpdate) Validate() error {
+	switch update.Action {
+	case ProductActionUpdate, ProductActionArchive, ProductActionReprice:
+	default:
+		return fmt.Errorf("%w (Validate): unknown product action %q", customErrors.ErrDataNotValid, update.Action)
+	}
+
+	if update.Name == nil && update.Price == nil && update.Available == nil {
+		return fmt.Errorf("%w (Validate): nothing to update", customErrors.ErrDataNotValid)
+	}
+
+	if update.Name != nil {
+		if err := validateProductName(*update.Name); err != nil {
+			return err
+		}
+	}
+
+	if update.Price != nil {
+		if err := validateProductPrice(*update.Price); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (update *ProductUpdate) Apply(product Product) Product {
+	if update.Name != nil {
+		product.Name = *update.Name
+	}
+	if update.Price != nil {
+		product.Price = *update.Price
+	}
+	if update.Available != nil {
+		product.Available = *update.Available
+	}
+
+	return product
+}
+
+// ProductChange is an audit record of a catalog change, Before is empty
+// for created products.
+type ProductChange struct {
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Before    *Product  `json:"before,omitempty"`
+	After     Product   `json:"after"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// validateProductName keeps names usable as a path segment of /api/buy/{item}.
+func validateProductName(name string) error {
+	if len(name) < 1 || len(name) >= 150 {
+		return fmt.Errorf("%w (Validate): incorrect product name length", customErrors.ErrDataNotValid)
+	}
+
+	if strings.ContainsAny(name, "/?# \t\n") {
+		return fmt.Errorf("%w (Validate): product name contains forbidden characters", customErrors.ErrDataNotValid)
+	}
+
+	return nil
+}
+
+func validateProductPrice(price int) error {
+	if price < 1 {
+		return fmt.Errorf("%w (Validate): product price must be at least 1", customErrors.ErrDataNotValid)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+const (
+	// IssueLedgerMismatch means the cached balance differs from the ledger.
+	IssueLedgerMismatch = "ledger_mismatch"
+	// IssueActivityMismatch means the cached balance differs from the balance
+	// recomputed from the transfers and purchases of the user.
+	IssueActivityMismatch = "activity_mismatch"
+	// IssueUnpricedPurchases means some purchased products were deleted,
+	// so their price is not part of the recomputed balance.
+	IssueUnpricedPurchases = "unpriced_purchases"
+)
+
+// BalanceBreakdown is the balance of a user recomputed from the activity tables.
+// Purchases are priced with the price paid, or with the current product price
+// if it was not recorded, and refunded purchases are not counted. Gifts are
+// purchases of the sender and traded items stay purchases of their buyer.
+type BalanceBreakdown struct {
+	Grant             int `json:"grant"`
+	Received          int `json:"received"`
+	Sent              int `json:"sent"`
+	Purchases         int `json:"purchases"`
+	UnpricedPurchases int `json:"unpricedPurchases"`
+}
+
+func (breakdown *BalanceBreakdown) Expected() int {
+	return breakdown.Grant + breakdown.Received - breakdown.Sent - breakdown.Purchases
+}
+
+// AccountActivity is a snapshot of everything known about the balance of a user.
+type AccountActivity struct {
+	UserId    int
+	UserName  string
+	Cached    int
+	Ledger    int
+	Breakdown BalanceBreakdown
+}
+
+type DriftingAccount struct {
+	UserId    int              `json:"userId"`
+	UserName  string           `json:"userName"`
+	Cached    int              `json:"cached"`
+	Ledger    int              `json:"ledger"`
+	Expected  int              `json:"expected"`
+	Breakdown BalanceBreakdown `json:"breakdown"`
+	Issues    []string         `json:"issues"`
+}
+
+type ReconciliationReport struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Accounts  int       `json:"accounts"`
+	// LedgerTotal is the sum of all ledger accounts, anything but zero
+	// means that unbalanced postings got into the ledger.
+	LedgerTotal int               `json:"ledgerTotal"`
+	Drifting    []DriftingAccount `json:"drifting"`
+}
+
+func (report *ReconciliationReport) IsClean() bool {
+	return report.LedgerTotal == 0 && len(report.Drifting) == 0
+}
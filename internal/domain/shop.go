@@ -2,6 +2,7 @@ package domain
 
 import (
 	"fmt"
+	"time"
 
 	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
 )
@@ -30,19 +31,159 @@ func (transaction *Transaction) Validate() error {
 	return nil
 }
 
+// Gift is an item bought by From and put into the inventory of To.
+type Gift struct {
+	From string
+	To   string
+	Item string
+}
+
+func (gift *Gift) Validate() error {
+	transaction := Transaction{From: gift.From, To: gift.To}
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
+
+	if gift.From == gift.To {
+		return fmt.Errorf("%w (Validate): gift to yourself", customErrors.ErrDataNotValid)
+	}
+
+	if gift.Item == "" {
+		return fmt.Errorf("%w (Validate): empty item name", customErrors.ErrDataNotValid)
+	}
+
+	return nil
+}
+
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	ExpiresAt   time.Time
+	// Response is the result of the first request, returned again on replays.
+	Response []byte
+}
+
+func (idempotencyKey *IdempotencyKey) Validate() error {
+	if len(idempotencyKey.Key) > 255 {
+		return fmt.Errorf("%w (Validate): idempotency key is too long", customErrors.ErrDataNotValid)
+	}
+
+	for _, r := range idempotencyKey.Key {
+		if r < 0x21 || r > 0x7e {
+			return fmt.Errorf("%w (Validate): idempotency key must be printable ascii", customErrors.ErrDataNotValid)
+		}
+	}
+
+	return nil
+}
+
+// PurchaseReceipt describes a single bought item and the balance left after buying it.
+type PurchaseReceipt struct {
+	Id       int64     `json:"id"`
+	Item     string    `json:"item"`
+	Price    int       `json:"price"`
+	BoughtAt time.Time `json:"boughtAt"`
+	Balance  int       `json:"balance"`
+}
+
+// RefundReceipt describes a refunded purchase, the price paid for it is returned.
+type RefundReceipt struct {
+	PurchaseId int64     `json:"purchaseId"`
+	Item       string    `json:"item"`
+	Amount     int       `json:"amount"`
+	RefundedAt time.Time `json:"refundedAt"`
+	Balance    int       `json:"balance"`
+}
+
+// GiftReceipt describes a gift, Id is the purchase in the inventory of the recipient
+// and Balance is what is left to the sender.
+type GiftReceipt struct {
+	Id      int64     `json:"id"`
+	To      string    `json:"toUser"`
+	Item    string    `json:"item"`
+	Price   int       `json:"price"`
+	SentAt  time.Time `json:"sentAt"`
+	Balance int       `json:"balance"`
+}
+
+const (
+	MaxCartLines    = 100
+	MaxCartQuantity = 1000
+)
+
+type CartLine struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+// Cart is a set of items bought in a single purchase, every item is listed once.
+type Cart struct {
+	Lines []CartLine
+}
+
+func (cart *Cart) Validate() error {
+	if len(cart.Lines) == 0 || len(cart.Lines) > MaxCartLines {
+		return fmt.Errorf("%w (Validate): incorrect number of cart lines", customErrors.ErrDataNotValid)
+	}
+
+	items := make(map[string]struct{}, len(cart.Lines))
+	for _, line := range cart.Lines {
+		if line.Item == "" {
+			return fmt.Errorf("%w (Validate): empty item name", customErrors.ErrDataNotValid)
+		}
+
+		if line.Quantity < 1 || line.Quantity > MaxCartQuantity {
+			return fmt.Errorf("%w (Validate): incorrect quantity of %s", customErrors.ErrDataNotValid, line.Item)
+		}
+
+		if _, ok := items[line.Item]; ok {
+			return fmt.Errorf("%w (Validate): %s is listed twice", customErrors.ErrDataNotValid, line.Item)
+		}
+		items[line.Item] = struct{}{}
+	}
+
+	return nil
+}
+
+func (cart *Cart) Items() []string {
+	items := make([]string, 0, len(cart.Lines))
+	for _, line := range cart.Lines {
+		items = append(items, line.Item)
+	}
+
+	return items
+}
+
+type CartReceiptLine struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Price    int    `json:"price"`
+	Cost     int    `json:"cost"`
+}
+
+// CartReceipt lists the cost of every cart line in the order of the cart
+// and the balance left after the purchase.
+type CartReceipt struct {
+	Lines   []CartReceiptLine `json:"lines"`
+	Total   int               `json:"total"`
+	Balance int               `json:"balance"`
+}
+
 type Item struct {
 	Type     string `json:"type"`
 	Quantity int    `json:"quantity"`
 }
 
 type RecievedCoins struct {
-	From   string `json:"fromUser"`
-	Amount int    `json:"amount"`
+	From    string `json:"fromUser"`
+	Amount  int    `json:"amount"`
+	TradeId *int64 `json:"tradeId,omitempty"`
 }
 
 type SentCoins struct {
-	To     string `json:"toUser"`
-	Amount int    `json:"amount"`
+	To      string `json:"toUser"`
+	Amount  int    `json:"amount"`
+	TradeId *int64 `json:"tradeId,omitempty"`
 }
 
 type SentRecievedHistory struct {
@@ -50,8 +191,24 @@ type SentRecievedHistory struct {
 	Sent     []SentCoins     `json:"sent"`
 }
 
+type RecievedGift struct {
+	From string `json:"fromUser"`
+	Item string `json:"item"`
+}
+
+type SentGift struct {
+	To   string `json:"toUser"`
+	Item string `json:"item"`
+}
+
+type SentRecievedGifts struct {
+	Recieved []RecievedGift `json:"recieved"`
+	Sent     []SentGift     `json:"sent"`
+}
+
 type InventoryInfo struct {
 	Coins       int                 `json:"coing"`
 	Inventory   []Item              `json:"inventory"`
 	CoinHistory SentRecievedHistory `json:"coinHistory"`
+	GiftHistory SentRecievedGifts   `json:"giftHistory"`
 }
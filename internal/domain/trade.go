@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+const (
+	TradeStatusPending  = "pending"
+	TradeStatusAccepted = "accepted"
+	TradeStatusDeclined = "declined"
+	// TradeStatusExpired is reported for the pending trades past their expiration,
+	// it is never stored.
+	TradeStatusExpired = "expired"
+
+	TradeSideOffered   = "offered"
+	TradeSideRequested = "requested"
+
+	MaxTradeLines = 20
+	MaxTrades     = 100
+)
+
+type TradeItem struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+// TradeSide is what one of the users gives away in a trade.
+type TradeSide struct {
+	Items []TradeItem `json:"items"`
+	Coins int         `json:"coins"`
+}
+
+func (side *TradeSide) Validate() error {
+	if len(side.Items) > MaxTradeLines {
+		return fmt.Errorf("%w (Validate): too many items in a trade", customErrors.ErrDataNotValid)
+	}
+
+	if side.Coins < 0 {
+		return fmt.Errorf("%w (Validate): incorrect amount of coins", customErrors.ErrDataNotValid)
+	}
+
+	items := make(map[string]struct{}, len(side.Items))
+	for _, item := range side.Items {
+		if item.Item == "" {
+			return fmt.Errorf("%w (Validate): empty item name", customErrors.ErrDataNotValid)
+		}
+
+		if item.Quantity < 1 || item.Quantity > MaxCartQuantity {
+			return fmt.Errorf("%w (Validate): incorrect quantity of %s", customErrors.ErrDataNotValid, item.Item)
+		}
+
+		if _, ok := items[item.Item]; ok {
+			return fmt.Errorf("%w (Validate): %s is listed twice", customErrors.ErrDataNotValid, item.Item)
+		}
+		items[item.Item] = struct{}{}
+	}
+
+	return nil
+}
+
+func (side *TradeSide) IsEmpty() bool {
+	return len(side.Items) == 0 && side.Coins == 0
+}
+
+// TradeOffer is proposed by From to To. Once To accepts it, From gives away
+// Offered and To gives away Requested.
+type TradeOffer struct {
+	From      string
+	To        string
+	Offered   TradeSide
+	Requested TradeSide
+	ExpiresAt time.Time
+}
+
+func (offer *TradeOffer) Validate() error {
+	transaction := Transaction{From: offer.From, To: offer.To}
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
+
+	if offer.From == offer.To {
+		return fmt.Errorf("%w (Validate): trade with yourself", customErrors.ErrDataNotValid)
+	}
+
+	if err := offer.Offered.Validate(); err != nil {
+		return err
+	}
+
+	if err := offer.Requested.Validate(); err != nil {
+		return err
+	}
+
+	if offer.Offered.IsEmpty() && offer.Requested.IsEmpty() {
+		return fmt.Errorf("%w (Validate): empty trade", customErrors.ErrDataNotValid)
+	}
+
+	return nil
+}
+
+type Trade struct {
+	Id         int64      `json:"id"`
+	From       string     `json:"fromUser"`
+	To         string     `json:"toUser"`
+	Offered    TradeSide  `json:"offered"`
+	Requested  TradeSide  `json:"requested"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// CheckResolvable allows only the recipient to accept or decline a pending trade.
+func (trade *Trade) CheckResolvable(username string) error {
+	if trade.To != username {
+		return fmt.Errorf("%w (CheckResolvable): only %s can resolve trade %d", customErrors.ErrForbidden, trade.To, trade.Id)
+	}
+
+	switch trade.Status {
+	case TradeStatusPending:
+		return nil
+	case TradeStatusExpired:
+		return fmt.Errorf("%w (CheckResolvable): trade %d has expired", customErrors.ErrNotAvailable, trade.Id)
+	default:
+		return fmt.Errorf("%w (CheckResolvable): trade %d is already %s", customErrors.ErrAlreadyExists, trade.Id, trade.Status)
+	}
+}
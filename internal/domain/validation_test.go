@@ -2,7 +2,10 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
 )
@@ -130,3 +133,313 @@ func TestTransactionValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestHistoryFilterValidation(t *testing.T) {
+	minAmount, maxAmount := 20, 10
+	now := time.Now()
+
+	testData := []struct {
+		TestName string
+		Filter   HistoryFilter
+		IsValid  bool
+	}{
+		{"default filter", HistoryFilter{Limit: DefaultHistoryLimit}, true},
+		{"unknown direction", HistoryFilter{Direction: "lost", Limit: 1}, false},
+		{"inverted amounts", HistoryFilter{MinAmount: &minAmount, MaxAmount: &maxAmount, Limit: 1}, false},
+		{"inverted dates", HistoryFilter{From: now, To: now.Add(-time.Hour), Limit: 1}, false},
+		{"empty date range", HistoryFilter{From: now, To: now, Limit: 1}, false},
+		{"zero limit", HistoryFilter{Limit: 0}, false},
+		{"limit too big", HistoryFilter{Limit: MaxHistoryLimit + 1}, false},
+		{"all filters", HistoryFilter{
+			Direction:    HistoryDirectionReceived,
+			Counterparty: "test_user",
+			MinAmount:    &maxAmount,
+			MaxAmount:    &minAmount,
+			From:         now.Add(-time.Hour),
+			To:           now,
+			Limit:        MaxHistoryLimit,
+		}, true},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Filter.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %v", testCase.Filter)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %v", testCase.Filter)
+			}
+		})
+	}
+}
+
+func TestHistoryCursor(t *testing.T) {
+	cursor := HistoryCursor{SentAt: time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC), Id: 42}
+
+	decoded, err := DecodeHistoryCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.SentAt.Equal(cursor.SentAt) || decoded.Id != cursor.Id {
+		t.Errorf("got cursor %v, expected %v", decoded, cursor)
+	}
+
+	for _, encoded := range []string{"!", "MTIz", "YTpi"} {
+		if _, err = DecodeHistoryCursor(encoded); !errors.Is(err, customErrors.ErrDataNotValid) {
+			t.Errorf("missed an error on cursor %q", encoded)
+		}
+	}
+}
+
+func TestLedgerEntryValidation(t *testing.T) {
+	testData := []struct {
+		TestName string
+		Entry    LedgerEntry
+		IsValid  bool
+	}{
+		{"transfer", NewLedgerTransfer(LedgerEntryTransfer, UserAccount(1), UserAccount(2), 10), true},
+		{"purchase", NewLedgerTransfer(LedgerEntryPurchase, UserAccount(1), RevenueAccount, 10), true},
+		{"signup", NewLedgerTransfer(LedgerEntrySignup, MintAccount, UserAccount(1), SignupGrant), true},
+		{"unknown kind", NewLedgerTransfer("gift", UserAccount(1), UserAccount(2), 10), false},
+		{"single posting", LedgerEntry{
+			Kind:     LedgerEntryAdjustment,
+			Postings: []LedgerPosting{{Account: UserAccount(1), Amount: 0}},
+		}, false},
+		{"not balanced", LedgerEntry{
+			Kind: LedgerEntryAdjustment,
+			Postings: []LedgerPosting{
+				{Account: MintAccount, Amount: -10},
+				{Account: UserAccount(1), Amount: 20},
+			},
+		}, false},
+		{"user account without user", NewLedgerTransfer(LedgerEntryTransfer, UserAccount(0), UserAccount(2), 10), false},
+		{"system account with user", NewLedgerTransfer(LedgerEntryPurchase, UserAccount(1),
+			LedgerAccount{Kind: LedgerAccountRevenue, UserId: 1}, 10), false},
+		{"unknown account", NewLedgerTransfer(LedgerEntryTransfer, UserAccount(1),
+			LedgerAccount{Kind: "bank"}, 10), false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Entry.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %v", testCase.Entry)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %v", testCase.Entry)
+			}
+		})
+	}
+}
+
+func TestProductValidation(t *testing.T) {
+	name, badName, price, badPrice := "mug", "mugs/cups", 30, 0
+
+	testData := []struct {
+		TestName string
+		Validate func() error
+		IsValid  bool
+	}{
+		{"correct product", (&Product{Name: "mug", Price: 1}).Validate, true},
+		{"empty name", (&Product{Name: "", Price: 1}).Validate, false},
+		{"name with slash", (&Product{Name: badName, Price: 1}).Validate, false},
+		{"zero price", (&Product{Name: "mug", Price: 0}).Validate, false},
+		{"correct update", (&ProductUpdate{Action: ProductActionUpdate, Name: &name, Price: &price}).Validate, true},
+		{"empty update", (&ProductUpdate{Action: ProductActionUpdate}).Validate, false},
+		{"unknown action", (&ProductUpdate{Action: "delete", Price: &price}).Validate, false},
+		{"create action", (&ProductUpdate{Action: ProductActionCreate, Price: &price}).Validate, false},
+		{"update with bad name", (&ProductUpdate{Action: ProductActionUpdate, Name: &badName}).Validate, false},
+		{"reprice to zero", (&ProductUpdate{Action: ProductActionReprice, Price: &badPrice}).Validate, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %s", testCase.TestName)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestCartValidation(t *testing.T) {
+	tooManyLines := make([]CartLine, 0, MaxCartLines+1)
+	for i := 0; i <= MaxCartLines; i++ {
+		tooManyLines = append(tooManyLines, CartLine{Item: fmt.Sprintf("item-%d", i), Quantity: 1})
+	}
+
+	testData := []struct {
+		TestName string
+		Cart     Cart
+		IsValid  bool
+	}{
+		{"correct cart", Cart{Lines: []CartLine{{"pen", 5}, {"cup", 1}}}, true},
+		{"empty cart", Cart{}, false},
+		{"too many lines", Cart{Lines: tooManyLines}, false},
+		{"empty item", Cart{Lines: []CartLine{{"", 1}}}, false},
+		{"zero quantity", Cart{Lines: []CartLine{{"pen", 0}}}, false},
+		{"negative quantity", Cart{Lines: []CartLine{{"pen", -1}}}, false},
+		{"too big quantity", Cart{Lines: []CartLine{{"pen", MaxCartQuantity + 1}}}, false},
+		{"item listed twice", Cart{Lines: []CartLine{{"pen", 1}, {"pen", 2}}}, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Cart.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %s", testCase.TestName)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestGiftValidation(t *testing.T) {
+	testData := []struct {
+		TestName string
+		Gift     Gift
+		IsValid  bool
+	}{
+		{"correct gift", Gift{From: "test_user", To: "another_user", Item: "pen"}, true},
+		{"short sender name", Gift{From: "te", To: "another_user", Item: "pen"}, false},
+		{"long recipient name", Gift{From: "test_user", To: strings.Repeat("a", 150), Item: "pen"}, false},
+		{"gift to yourself", Gift{From: "test_user", To: "test_user", Item: "pen"}, false},
+		{"empty item", Gift{From: "test_user", To: "another_user"}, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Gift.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %s", testCase.TestName)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestTradeOfferValidation(t *testing.T) {
+	pen := []TradeItem{{Item: "pen", Quantity: 2}}
+
+	testData := []struct {
+		TestName string
+		Offer    TradeOffer
+		IsValid  bool
+	}{
+		{"correct trade", TradeOffer{From: "test_user", To: "another_user", Offered: TradeSide{Items: pen}, Requested: TradeSide{Coins: 10}}, true},
+		{"only coins requested", TradeOffer{From: "test_user", To: "another_user", Requested: TradeSide{Coins: 10}}, true},
+		{"short sender name", TradeOffer{From: "te", To: "another_user", Offered: TradeSide{Items: pen}}, false},
+		{"trade with yourself", TradeOffer{From: "test_user", To: "test_user", Offered: TradeSide{Items: pen}}, false},
+		{"empty trade", TradeOffer{From: "test_user", To: "another_user"}, false},
+		{"negative coins", TradeOffer{From: "test_user", To: "another_user", Offered: TradeSide{Coins: -1}, Requested: TradeSide{Items: pen}}, false},
+		{"empty item", TradeOffer{From: "test_user", To: "another_user", Offered: TradeSide{Items: []TradeItem{{Quantity: 1}}}}, false},
+		{"zero quantity", TradeOffer{From: "test_user", To: "another_user", Requested: TradeSide{Items: []TradeItem{{Item: "pen"}}}}, false},
+		{"item listed twice", TradeOffer{From: "test_user", To: "another_user", Offered: TradeSide{Items: append(pen, pen...)}}, false},
+		{"too many items", TradeOffer{From: "test_user", To: "another_user", Offered: TradeSide{Items: make([]TradeItem, MaxTradeLines+1)}}, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			err := testCase.Offer.Validate()
+			if !testCase.IsValid && !errors.Is(err, customErrors.ErrDataNotValid) {
+				t.Errorf("unexpected error on case %s", testCase.TestName)
+			} else if testCase.IsValid && err != nil {
+				t.Errorf("missed an error on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestTradeCheckResolvable(t *testing.T) {
+	testData := []struct {
+		TestName string
+		Username string
+		Status   string
+		Err      error
+	}{
+		{"pending trade", "another_user", TradeStatusPending, nil},
+		{"resolved by the proposer", "test_user", TradeStatusPending, customErrors.ErrForbidden},
+		{"expired trade", "another_user", TradeStatusExpired, customErrors.ErrNotAvailable},
+		{"accepted trade", "another_user", TradeStatusAccepted, customErrors.ErrAlreadyExists},
+		{"declined trade", "another_user", TradeStatusDeclined, customErrors.ErrAlreadyExists},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			trade := Trade{Id: 1, From: "test_user", To: "another_user", Status: testCase.Status}
+			err := trade.CheckResolvable(testCase.Username)
+			if testCase.Err == nil && err != nil {
+				t.Errorf("unexpected error on case %s", testCase.TestName)
+			} else if testCase.Err != nil && !errors.Is(err, testCase.Err) {
+				t.Errorf("missed an error on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestPrincipalPermissions(t *testing.T) {
+	testData := []struct {
+		TestName   string
+		Roles      []string
+		Permission string
+		Allowed    bool
+	}{
+		{"admin manages catalog", []string{RoleAdmin}, PermissionManageCatalog, true},
+		{"admin manages roles", []string{RoleAdmin}, PermissionManageRoles, true},
+		{"support views catalog", []string{RoleSupport}, PermissionViewCatalog, true},
+		{"support manages catalog", []string{RoleSupport}, PermissionManageCatalog, false},
+		{"service manages catalog", []string{RoleService}, PermissionManageCatalog, true},
+		{"service manages roles", []string{RoleService}, PermissionManageRoles, false},
+		{"combined roles", []string{RoleSupport, RoleService}, PermissionManageCatalog, true},
+		{"unknown role", []string{"superuser"}, PermissionManageCatalog, false},
+		{"no roles", nil, PermissionViewCatalog, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			principal := Principal{Name: "test_user", Roles: testCase.Roles}
+			if principal.HasPermission(testCase.Permission) != testCase.Allowed {
+				t.Errorf("unexpected permission check on case %s", testCase.TestName)
+			}
+		})
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 12, 0, 0, 500, time.UTC)
+
+	revocations := Revocations{
+		Tokens:   map[string]time.Time{"revoked": cutoff.Add(time.Hour)},
+		Cutoffs:  map[int]time.Time{2: cutoff},
+		Sessions: map[string]time.Time{"ended": cutoff},
+	}
+
+	testData := []struct {
+		TestName  string
+		Principal Principal
+		IsRevoked bool
+	}{
+		{"active token", Principal{UserId: 1, TokenId: "active", IssuedAt: cutoff}, false},
+		{"revoked token", Principal{UserId: 1, TokenId: "revoked", IssuedAt: cutoff}, true},
+		{"token without id", Principal{UserId: 1, IssuedAt: cutoff}, false},
+		{"ended session", Principal{UserId: 1, TokenId: "active", SessionId: "ended", IssuedAt: cutoff}, true},
+		{"other session", Principal{UserId: 1, TokenId: "active", SessionId: "family", IssuedAt: cutoff}, false},
+		{"issued before cutoff", Principal{UserId: 2, TokenId: "old", IssuedAt: cutoff.Add(-time.Minute)}, true},
+		{"issued in the cutoff second", Principal{UserId: 2, TokenId: "same", IssuedAt: cutoff.Truncate(time.Second)},
+			true},
+		{"issued after cutoff", Principal{UserId: 2, TokenId: "new", IssuedAt: cutoff.Add(time.Second)}, false},
+		{"issued right after cutoff", Principal{UserId: 2, TokenId: "next", IssuedAt: cutoff.Add(time.Millisecond)},
+			false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			if revocations.IsRevoked(testCase.Principal) != testCase.IsRevoked {
+				t.Errorf("unexpected revocation check on case %s", testCase.TestName)
+			}
+		})
+	}
+}
@@ -9,16 +9,30 @@ var (
 	ErrInternal                 = errors.New("internal service error")
 	ErrDataNotValid             = errors.New("invalid data")
 	ErrIncorrectEmailOrPassword = errors.New("incorrect email or password")
+	ErrInsufficientFunds        = errors.New("insufficient funds")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for another request")
+	ErrNotAvailable             = errors.New("not available")
+	ErrTooManyRequests          = errors.New("too many requests")
 )
 
 func ConvertToHttpErr(err error) int {
 	switch {
 	case errors.Is(err, ErrUnauthenticated):
 		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
 	case errors.Is(err, ErrIncorrectEmailOrPassword),
 		errors.Is(err, ErrDataNotValid),
+		errors.Is(err, ErrInvalidResetToken),
+		errors.Is(err, ErrInsufficientFunds),
+		errors.Is(err, ErrNotAvailable),
 		errors.Is(err, ErrDoesNotExist):
 		return http.StatusBadRequest
+	case errors.Is(err, ErrIdempotencyKeyReused),
+		errors.Is(err, ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrTooManyRequests):
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusInternalServerError
 	}
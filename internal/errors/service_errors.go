@@ -8,4 +8,9 @@ var (
 	ErrFailedToExecuteMethod = errors.New("failed to execute method")
 	ErrFailedToSignToken     = errors.New("failed to sign token")
 	ErrUnauthenticated       = errors.New("unauthenticated")
+	ErrForbidden             = errors.New("forbidden")
+	ErrNoActiveKey           = errors.New("no active signing key")
+	ErrUnknownSigningKey     = errors.New("unknown signing key")
+	ErrRefreshTokenReused    = errors.New("refresh token reused")
+	ErrInvalidResetToken     = errors.New("invalid or expired reset token")
 )
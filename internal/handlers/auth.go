@@ -3,8 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -13,29 +15,74 @@ import (
 )
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type AuthService interface {
-	LoginOrCreateUser(ctx context.Context, userCreds domain.UserCredantials) (string, error)
-	GetNameAndCheck(ctx context.Context, token string) (string, bool)
+	LoginOrCreateUser(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error)
+	Register(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error)
+	Login(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (domain.Tokens, error)
+	Authenticate(ctx context.Context, token string) (domain.Principal, error)
+	Logout(ctx context.Context, principal domain.Principal) error
+	RevokeSessions(ctx context.Context, name string) error
+	ChangePassword(ctx context.Context, principal domain.Principal, oldPassword string, newPassword string) error
+	CreatePasswordReset(ctx context.Context, principal domain.Principal, name string) (domain.PasswordResetToken, error)
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) error
+}
+
+// LoginThrottle locks out the user names and the clients guessing passwords.
+type LoginThrottle interface {
+	Allow(name string, client string) error
+	Failed(name string, client string)
+	Succeeded(name string, client string)
 }
 
 type AuthHandler struct {
-	authService       AuthService
-	logger            *zap.SugaredLogger
-	sessionExpiration int
+	authService   AuthService
+	loginThrottle LoginThrottle
+	logger        *zap.SugaredLogger
+	secureCookies bool
 }
 
-func NewAuthHandler(authService AuthService, logger *zap.SugaredLogger, sessionExpiration int) (*AuthHandler, error) {
+func NewAuthHandler(
+	authService AuthService,
+	loginThrottle LoginThrottle,
+	logger *zap.SugaredLogger,
+	secureCookies bool) (*AuthHandler, error) {
 	return &AuthHandler{
-		authService:       authService,
-		logger:            logger,
-		sessionExpiration: sessionExpiration,
+		authService:   authService,
+		loginThrottle: loginThrottle,
+		logger:        logger,
+		secureCookies: secureCookies,
 	}, nil
 }
 
+// Auth logs in the user, unknown users are signed up if auto-signup is enabled.
 func (h *AuthHandler) Auth(w http.ResponseWriter, req *http.Request) {
+	h.issueToken(w, req, http.StatusOK, true, h.authService.LoginOrCreateUser)
+}
+
+func (h *AuthHandler) Register(w http.ResponseWriter, req *http.Request) {
+	h.issueToken(w, req, http.StatusCreated, false, h.authService.Register)
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, req *http.Request) {
+	h.issueToken(w, req, http.StatusOK, true, h.authService.Login)
+}
+
+// issueToken checks the password guesses against the login throttle if throttled is set.
+func (h *AuthHandler) issueToken(
+	w http.ResponseWriter,
+	req *http.Request,
+	status int,
+	throttled bool,
+	issue func(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error)) {
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		err = WriteResponse(
@@ -87,9 +134,26 @@ func (h *AuthHandler) Auth(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	client := clientAddress(req)
+	if throttled {
+		err = h.loginThrottle.Allow(userCreds.UserName, client)
+		if err != nil {
+			h.logger.Infof("login attempt rejected (handlers.issueToken): %v", err)
+			writeErrorResponse(w, h.logger, req, "", 0, err)
+			return
+		}
+	}
+
 	ctx := context.WithValue(req.Context(), CtxSessionName, userCreds.UserName)
 
-	token, err := h.authService.LoginOrCreateUser(ctx, userCreds)
+	tokens, err := issue(ctx, userCreds)
+	if throttled {
+		if errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+			h.loginThrottle.Failed(userCreds.UserName, client)
+		} else if err == nil {
+			h.loginThrottle.Succeeded(userCreds.UserName, client)
+		}
+	}
 	if err != nil {
 		err = WriteResponse(w,
 			h.logger,
@@ -105,24 +169,140 @@ func (h *AuthHandler) Auth(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:     "token",
-		Value:    token,
-		Path:     "/api",
-		MaxAge:   h.sessionExpiration,
+	h.writeTokens(w, req, userCreds.UserName, status, tokens)
+}
+
+// Refresh rotates the refresh token taken from its cookie or from the request body,
+// a reused token ends the whole session.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, req *http.Request) {
+	var refreshToken string
+	if cookie, err := req.Cookie(refreshCookieName); err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var refreshReq RefreshRequest
+		err = json.NewDecoder(req.Body).Decode(&refreshReq)
+		if err != nil && err != io.EOF {
+			writeErrorResponse(w, h.logger, req, "", http.StatusBadRequest, err)
+			return
+		}
+		refreshToken = refreshReq.RefreshToken
+	}
+
+	tokens, err := h.authService.Refresh(req.Context(), refreshToken)
+	if err != nil {
+		h.clearCookies(w)
+		writeErrorResponse(w, h.logger, req, "", 0, err)
+		return
+	}
+
+	h.writeTokens(w, req, "", http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) writeTokens(
+	w http.ResponseWriter,
+	req *http.Request,
+	session string,
+	status int,
+	tokens domain.Tokens) {
+	http.SetCookie(w, h.cookie(tokenCookieName, "/api", tokens.AccessToken, tokens.AccessExpiresAt))
+	http.SetCookie(w, h.cookie(refreshCookieName, refreshCookiePath, tokens.RefreshToken, tokens.RefreshExpiresAt))
+
+	err := WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: session,
+			Url:     req.Pattern,
+			Status:  status,
+			Data: TokenResponse{
+				Token:        tokens.AccessToken,
+				RefreshToken: tokens.RefreshToken,
+			},
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+func (h *AuthHandler) cookie(name string, path string, value string, expiresAt time.Time) *http.Cookie {
+	maxAge := -1
+	if value != "" {
+		maxAge = max(int(time.Until(expiresAt).Seconds()), 1)
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   maxAge,
 		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func (h *AuthHandler) clearCookies(w http.ResponseWriter) {
+	http.SetCookie(w, h.cookie(tokenCookieName, "/api", "", time.Time{}))
+	http.SetCookie(w, h.cookie(refreshCookieName, refreshCookiePath, "", time.Time{}))
+}
+
+// Logout revokes the token of the request and its refresh tokens, it must be wrapped by AuthMiddleware.Authenticate.
+func (h *AuthHandler) Logout(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	err := h.authService.Logout(req.Context(), principal)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	h.clearCookies(w)
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    nil,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+// RevokeSessions logs the user out everywhere, it must be wrapped
+// by AuthMiddleware.Authenticate and AuthMiddleware.RequirePermission.
+func (h *AuthHandler) RevokeSessions(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	name := req.PathValue("name")
+
+	err := h.authService.RevokeSessions(req.Context(), name)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
 	}
 
-	http.SetCookie(w, cookie)
+	h.logger.Infof("sessions of %s revoked by %s", name, principal.Name)
 
 	err = WriteResponse(
 		w,
 		h.logger,
 		ResponseData{
-			Session: token,
+			Session: principal.Name,
 			Url:     req.Pattern,
 			Status:  http.StatusOK,
-			Data:    TokenResponse{Token: token},
+			Data:    nil,
 		})
 	if err != nil {
 		h.logger.Errorf("unable to write http response: %v", err)
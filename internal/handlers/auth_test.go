@@ -8,18 +8,38 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap/zaptest"
 
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/postgres"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/storagetest"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
 	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
 )
 
+var testTokens = domain.Tokens{
+	AccessToken:      "token",
+	AccessExpiresAt:  time.Now().Add(time.Minute),
+	RefreshToken:     "refresh",
+	RefreshExpiresAt: time.Now().Add(time.Hour),
+}
+
+func newTestLoginThrottle(t *testing.T) *services.LoginThrottle {
+	loginThrottle, err := services.NewLoginThrottle(zaptest.NewLogger(t).Sugar(), 5, 50, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loginThrottle
+}
+
 func TestAuth(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -28,9 +48,7 @@ func TestAuth(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	sessionExpiration := 60
-
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
@@ -41,7 +59,7 @@ func TestAuth(t *testing.T) {
 	}
 
 	ctx := context.WithValue(context.Background(), CtxSessionName, authData.UserName)
-	authService.EXPECT().LoginOrCreateUser(ctx, authData).Return("token", nil)
+	authService.EXPECT().LoginOrCreateUser(ctx, authData).Return(testTokens, nil)
 
 	jsonData, err := json.Marshal(authData)
 	if err != nil {
@@ -93,15 +111,172 @@ func TestAuth(t *testing.T) {
 	}
 }
 
+func TestRegisterAndLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
+	if err != nil {
+		log.Fatalf("error in auth handler initialization: %v\n", err)
+	}
+
+	newUser := domain.UserCredantials{UserName: "new_user", Password: "test_password"}
+	takenUser := domain.UserCredantials{UserName: "taken_user", Password: "test_password"}
+	wrongPassword := domain.UserCredantials{UserName: "taken_user", Password: "wrong_password"}
+
+	authService.EXPECT().Register(gomock.Any(), newUser).Return(testTokens, nil)
+	authService.EXPECT().Register(gomock.Any(), takenUser).Return(domain.Tokens{}, customErrors.ErrAlreadyExists)
+	authService.EXPECT().Login(gomock.Any(), takenUser).Return(testTokens, nil)
+	authService.EXPECT().Login(gomock.Any(), wrongPassword).Return(domain.Tokens{}, customErrors.ErrIncorrectEmailOrPassword)
+
+	testData := []struct {
+		TestName       string
+		Handler        http.HandlerFunc
+		UserCreds      domain.UserCredantials
+		ExpectedStatus int
+	}{
+		{"register", authHandler.Register, newUser, http.StatusCreated},
+		{"register taken name", authHandler.Register, takenUser, http.StatusConflict},
+		{"register invalid name", authHandler.Register, domain.UserCredantials{Password: "test_password"},
+			http.StatusBadRequest},
+		{"login", authHandler.Login, takenUser, http.StatusOK},
+		{"login with wrong password", authHandler.Login, wrongPassword, http.StatusBadRequest},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			jsonData, err := json.Marshal(testCase.UserCreds)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewReader(jsonData))
+
+			testCase.Handler(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Errorf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
+	if err != nil {
+		log.Fatalf("error in auth handler initialization: %v\n", err)
+	}
+
+	wrongPassword := domain.UserCredantials{UserName: "test_user", Password: "wrong_password"}
+
+	// the locked out attempts never reach the password check
+	authService.EXPECT().Login(gomock.Any(), wrongPassword).
+		Return(domain.Tokens{}, customErrors.ErrIncorrectEmailOrPassword).Times(6)
+
+	jsonData, err := json.Marshal(wrongPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expectedStatus := range []int{
+		http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest,
+		http.StatusBadRequest, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusTooManyRequests,
+	} {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewReader(jsonData))
+
+		authHandler.Login(wr, req)
+		if wr.Code != expectedStatus {
+			t.Errorf("attempt %d: got HTTP status code %d, expected %d", i, wr.Code, expectedStatus)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, true)
+	if err != nil {
+		log.Fatalf("error in auth handler initialization: %v\n", err)
+	}
+
+	authService.EXPECT().Refresh(gomock.Any(), "from_cookie").Return(testTokens, nil)
+	authService.EXPECT().Refresh(gomock.Any(), "from_body").Return(testTokens, nil)
+	authService.EXPECT().Refresh(gomock.Any(), "reused").
+		Return(domain.Tokens{}, fmt.Errorf("%w: %w", customErrors.ErrUnauthenticated, customErrors.ErrRefreshTokenReused))
+	authService.EXPECT().Refresh(gomock.Any(), "").Return(domain.Tokens{}, customErrors.ErrUnauthenticated)
+
+	testData := []struct {
+		TestName        string
+		Cookie          string
+		Body            string
+		ExpectedStatus  int
+		ExpectedRefresh string
+	}{
+		{"refresh token in cookie", "from_cookie", "", http.StatusOK, "refresh"},
+		{"refresh token in body", "", `{"refreshToken":"from_body"}`, http.StatusOK, "refresh"},
+		{"reused refresh token", "reused", "", http.StatusUnauthorized, ""},
+		{"no refresh token", "", "", http.StatusUnauthorized, ""},
+		{"malformed body", "", "{", http.StatusBadRequest, ""},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(testCase.Body))
+			if testCase.Cookie != "" {
+				req.AddCookie(&http.Cookie{Name: refreshCookieName, Value: testCase.Cookie})
+			}
+
+			authHandler.Refresh(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedStatus == http.StatusBadRequest {
+				return
+			}
+
+			var refreshCookie *http.Cookie
+			for _, cookie := range wr.Result().Cookies() {
+				if cookie.Name == refreshCookieName {
+					refreshCookie = cookie
+				}
+			}
+			if refreshCookie == nil {
+				t.Fatalf("refresh cookie is not set")
+			}
+			if refreshCookie.Value != testCase.ExpectedRefresh {
+				t.Errorf("got refresh cookie %q, expected %q", refreshCookie.Value, testCase.ExpectedRefresh)
+			}
+			if !refreshCookie.Secure || !refreshCookie.HttpOnly || refreshCookie.SameSite != http.SameSiteStrictMode {
+				t.Errorf("refresh cookie is not secure: %v", refreshCookie)
+			}
+			if refreshCookie.Path != refreshCookiePath {
+				t.Errorf("got refresh cookie path %q, expected %q", refreshCookie.Path, refreshCookiePath)
+			}
+		})
+	}
+}
+
 func TestAuthPostgres(t *testing.T) {
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost",
-		"5432",
-		"postgres",
-		"root1234",
-		"shop",
-	))
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
 	if err != nil {
 		log.Fatalf("error in postgres initialization: %v\n", err)
 	}
@@ -115,12 +290,22 @@ func TestAuthPostgres(t *testing.T) {
 		log.Fatalf("error in auth storage initialization: %v\n", err)
 	}
 
-	authService, err := services.NewAuthService(authStorage, logger, 10, sessionExpiration)
+	keyStorage, err := postgres.NewKeyStorage(pool)
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := postgres.NewSessionStorage(pool)
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
 	if err != nil {
 		log.Fatalf("error in auth handler initialization: %v\n", err)
 	}
@@ -1,16 +1,41 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
 )
 
 type CtxSessionKey string
 
 const CtxSessionName = CtxSessionKey("session")
 
+const (
+	tokenCookieName     = "token"
+	refreshCookieName   = "refresh_token"
+	refreshCookiePath   = "/api/auth/refresh"
+	authorizationHeader = "Authorization"
+	bearerScheme        = "Bearer"
+
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	etagHeader        = "ETag"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
 type ErrorResponse struct {
 	Errors string `json:"errors"`
 }
@@ -41,3 +66,210 @@ func WriteResponse(w http.ResponseWriter, logger *zap.SugaredLogger, responseDat
 
 	return nil
 }
+
+// writeErrorResponse writes the error with the given status, the status
+// is derived from the error if it is zero.
+func writeErrorResponse(
+	w http.ResponseWriter,
+	logger *zap.SugaredLogger,
+	req *http.Request,
+	session string,
+	status int,
+	err error) {
+	if status == 0 {
+		status = customErrors.ConvertToHttpErr(err)
+	}
+
+	err = WriteResponse(
+		w,
+		logger,
+		ResponseData{
+			Session: session,
+			Url:     req.Pattern,
+			Status:  status,
+			Data:    ErrorResponse{Errors: err.Error()},
+		})
+	if err != nil {
+		logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+// resourceStatus answers 404 for the endpoints addressing a resource by its path.
+func resourceStatus(err error) int {
+	if errors.Is(err, customErrors.ErrDoesNotExist) {
+		return http.StatusNotFound
+	}
+
+	return customErrors.ConvertToHttpErr(err)
+}
+
+// WriteCachedResponse writes the response with an ETag computed from its content
+// and answers 304 Not Modified if the client already has the same version.
+func WriteCachedResponse(
+	w http.ResponseWriter,
+	req *http.Request,
+	logger *zap.SugaredLogger,
+	responseData ResponseData) error {
+	jsonData, err := json.Marshal(responseData.Data)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(jsonData)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set(etagHeader, etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if !etagMatches(req.Header.Get(ifNoneMatchHeader), etag) {
+		return WriteResponse(w, logger, responseData)
+	}
+
+	logger.Infof("session: %s; response status: %d; url: %s",
+		responseData.Session,
+		http.StatusNotModified,
+		responseData.Url)
+	w.WriteHeader(http.StatusNotModified)
+
+	return nil
+}
+
+// etagMatches checks the If-None-Match header, which may list several
+// tags, possibly weak ones, or be "*".
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getToken extracts the session token from the Authorization header
+// ("Bearer <token>") and falls back to the token cookie used by browsers.
+func getToken(req *http.Request) (string, error) {
+	header := req.Header.Get(authorizationHeader)
+	if header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		token = strings.TrimSpace(token)
+		if !found || !strings.EqualFold(scheme, bearerScheme) || token == "" {
+			return "", fmt.Errorf("%w (handlers.getToken): malformed authorization header",
+				customErrors.ErrUnauthenticated)
+		}
+
+		return token, nil
+	}
+
+	cookie, err := req.Cookie(tokenCookieName)
+	if err != nil {
+		return "", fmt.Errorf("%w (handlers.getToken): %w", customErrors.ErrUnauthenticated, err)
+	}
+
+	return cookie.Value, nil
+}
+
+// getIdempotencyKey reads the Idempotency-Key header and fingerprints the request,
+// so that the same key sent with a different request can be rejected.
+func getIdempotencyKey(req *http.Request, body []byte) (domain.IdempotencyKey, error) {
+	idempotencyKey := domain.IdempotencyKey{
+		Key: req.Header.Get(idempotencyKeyHeader),
+	}
+	if idempotencyKey.Key == "" {
+		return idempotencyKey, nil
+	}
+
+	if err := idempotencyKey.Validate(); err != nil {
+		return domain.IdempotencyKey{}, fmt.Errorf("(handlers.getIdempotencyKey): %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	hash.Write(body)
+	idempotencyKey.RequestHash = hex.EncodeToString(hash.Sum(nil))
+
+	return idempotencyKey, nil
+}
+
+// getHistoryFilter reads the coin history filter from the query parameters.
+func getHistoryFilter(query url.Values) (domain.HistoryFilter, error) {
+	filter := domain.HistoryFilter{
+		Direction:    query.Get("direction"),
+		Counterparty: query.Get("counterparty"),
+		Limit:        domain.DefaultHistoryLimit,
+	}
+
+	intParams := []struct {
+		name  string
+		value **int
+	}{
+		{"minAmount", &filter.MinAmount},
+		{"maxAmount", &filter.MaxAmount},
+	}
+	for _, param := range intParams {
+		if !query.Has(param.name) {
+			continue
+		}
+
+		value, err := strconv.Atoi(query.Get(param.name))
+		if err != nil {
+			return domain.HistoryFilter{}, fmt.Errorf("%w (handlers.getHistoryFilter): %s: %w",
+				customErrors.ErrDataNotValid, param.name, err)
+		}
+		*param.value = &value
+	}
+
+	timeParams := []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	}
+	for _, param := range timeParams {
+		if !query.Has(param.name) {
+			continue
+		}
+
+		value, err := time.Parse(time.RFC3339, query.Get(param.name))
+		if err != nil {
+			return domain.HistoryFilter{}, fmt.Errorf("%w (handlers.getHistoryFilter): %s: %w",
+				customErrors.ErrDataNotValid, param.name, err)
+		}
+		*param.value = value.UTC()
+	}
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil {
+			return domain.HistoryFilter{}, fmt.Errorf("%w (handlers.getHistoryFilter): limit: %w",
+				customErrors.ErrDataNotValid, err)
+		}
+		filter.Limit = limit
+	}
+
+	if query.Has("cursor") {
+		cursor, err := domain.DecodeHistoryCursor(query.Get("cursor"))
+		if err != nil {
+			return domain.HistoryFilter{}, fmt.Errorf("(handlers.getHistoryFilter): %w", err)
+		}
+		filter.Cursor = &cursor
+	}
+
+	if err := filter.Validate(); err != nil {
+		return domain.HistoryFilter{}, fmt.Errorf("(handlers.getHistoryFilter): %w", err)
+	}
+
+	return filter, nil
+}
+
+// clientAddress is the address the request came from, without the port.
+func clientAddress(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestGetToken(t *testing.T) {
+	testData := []struct {
+		TestName string
+		Header   string
+		Cookie   string
+		Token    string
+		Error    error
+	}{
+		{
+			"bearer header",
+			"Bearer header_token",
+			"",
+			"header_token",
+			nil,
+		},
+		{
+			"lowercase scheme",
+			"bearer header_token",
+			"",
+			"header_token",
+			nil,
+		},
+		{
+			"header has priority over cookie",
+			"Bearer header_token",
+			"cookie_token",
+			"header_token",
+			nil,
+		},
+		{
+			"cookie only",
+			"",
+			"cookie_token",
+			"cookie_token",
+			nil,
+		},
+		{
+			"wrong scheme",
+			"Basic dXNlcjpwYXNz",
+			"cookie_token",
+			"",
+			customErrors.ErrUnauthenticated,
+		},
+		{
+			"missing token",
+			"Bearer ",
+			"",
+			"",
+			customErrors.ErrUnauthenticated,
+		},
+		{
+			"no credentials",
+			"",
+			"",
+			"",
+			customErrors.ErrUnauthenticated,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+			if testCase.Header != "" {
+				req.Header.Set("Authorization", testCase.Header)
+			}
+			if testCase.Cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "token", Value: testCase.Cookie})
+			}
+
+			token, err := getToken(req)
+			if !errors.Is(err, testCase.Error) {
+				t.Errorf("unexpected error %v", err)
+			}
+			if token != testCase.Token {
+				t.Errorf("got token %q, expected %q", token, testCase.Token)
+			}
+		})
+	}
+}
+
+func TestGetIdempotencyKey(t *testing.T) {
+	newRequest := func(path string, key string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		return req
+	}
+
+	idempotencyKey, err := getIdempotencyKey(newRequest("/api/sendCoin", ""), []byte(`{"amount":1}`))
+	if err != nil || idempotencyKey.Key != "" {
+		t.Errorf("request without a key must not be deduplicated")
+	}
+
+	_, err = getIdempotencyKey(newRequest("/api/sendCoin", "bad key"), nil)
+	if !errors.Is(err, customErrors.ErrDataNotValid) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	first, err := getIdempotencyKey(newRequest("/api/sendCoin", "key"), []byte(`{"amount":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := getIdempotencyKey(newRequest("/api/sendCoin", "key"), []byte(`{"amount":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherBody, err := getIdempotencyKey(newRequest("/api/sendCoin", "key"), []byte(`{"amount":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPath, err := getIdempotencyKey(newRequest("/api/buy/pen", "key"), []byte(`{"amount":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.RequestHash != same.RequestHash {
+		t.Errorf("same requests have different hashes")
+	}
+	if first.RequestHash == otherBody.RequestHash || first.RequestHash == otherPath.RequestHash {
+		t.Errorf("different requests have the same hash")
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type ctxPrincipalKey struct{}
+
+type AuthMiddleware struct {
+	authService AuthService
+	logger      *zap.SugaredLogger
+}
+
+func NewAuthMiddleware(authService AuthService, logger *zap.SugaredLogger) (*AuthMiddleware, error) {
+	return &AuthMiddleware{
+		authService: authService,
+		logger:      logger,
+	}, nil
+}
+
+// Authenticate lets through only requests with a valid token and passes
+// the authenticated user to the next handler in the request context.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, err := getToken(req)
+		if err != nil {
+			m.logger.Infof("request without credentials (handlers.Authenticate): %v", err)
+			writeUnauthenticated(w, m.logger, req)
+			return
+		}
+
+		principal, err := m.authService.Authenticate(req.Context(), token)
+		if err != nil {
+			m.logger.Infof("request with invalid credentials (handlers.Authenticate): %v", err)
+			writeUnauthenticated(w, m.logger, req)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(withPrincipal(req.Context(), principal)))
+	})
+}
+
+// RequirePermission lets through only the principals whose roles grant
+// the permission, it must be wrapped by Authenticate.
+func (m *AuthMiddleware) RequirePermission(permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		principal, ok := PrincipalFromContext(req.Context())
+		if !ok {
+			writeUnauthenticated(w, m.logger, req)
+			return
+		}
+
+		if !principal.HasPermission(permission) {
+			m.logger.Infof("user %s without permission %s (handlers.RequirePermission): %s",
+				principal.Name, permission, req.Pattern)
+			writeErrorResponse(w, m.logger, req, principal.Name, http.StatusForbidden, customErrors.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Deprecated marks the responses of a route kept only for the compatibility with old clients.
+func Deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		next.ServeHTTP(w, req)
+	})
+}
+
+// PrincipalFromContext returns the user authenticated by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (domain.Principal, bool) {
+	principal, ok := ctx.Value(ctxPrincipalKey{}).(domain.Principal)
+
+	return principal, ok
+}
+
+func withPrincipal(ctx context.Context, principal domain.Principal) context.Context {
+	return context.WithValue(ctx, ctxPrincipalKey{}, principal)
+}
+
+// writeUnauthenticated writes the same response for every authentication failure,
+// so that clients cannot tell a missing token from an expired or forged one.
+func writeUnauthenticated(w http.ResponseWriter, logger *zap.SugaredLogger, req *http.Request) {
+	w.Header().Set("WWW-Authenticate", bearerScheme)
+
+	err := WriteResponse(
+		w,
+		logger,
+		ResponseData{
+			Session: "",
+			Url:     req.Pattern,
+			Status:  http.StatusUnauthorized,
+			Data:    ErrorResponse{Errors: customErrors.ErrUnauthenticated.Error()},
+		})
+	if err != nil {
+		logger.Errorf("unable to write http response: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal := domain.Principal{
+		UserId:  1,
+		Name:    "test_user",
+		Roles:   []string{"user"},
+		TokenId: "test_jti",
+	}
+	authService.EXPECT().Authenticate(gomock.Any(), "valid_token").Return(principal, nil).AnyTimes()
+	authService.EXPECT().Authenticate(gomock.Any(), "invalid_token").
+		Return(domain.Principal{}, customErrors.ErrUnauthenticated).AnyTimes()
+
+	var gotPrincipal domain.Principal
+	handler := authMiddleware.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPrincipal, _ = PrincipalFromContext(req.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testData := []struct {
+		TestName       string
+		Header         string
+		Cookie         string
+		ExpectedStatus int
+	}{
+		{"bearer token", "Bearer valid_token", "", http.StatusOK},
+		{"cookie", "", "valid_token", http.StatusOK},
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"malformed header", "Basic valid_token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid_token", "", http.StatusUnauthorized},
+		{"invalid cookie", "", "invalid_token", http.StatusUnauthorized},
+	}
+
+	expectedBody := `{"errors":"` + customErrors.ErrUnauthenticated.Error() + `"}`
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			gotPrincipal = domain.Principal{}
+
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+			if testCase.Header != "" {
+				req.Header.Set("Authorization", testCase.Header)
+			}
+			if testCase.Cookie != "" {
+				req.AddCookie(&http.Cookie{Name: tokenCookieName, Value: testCase.Cookie})
+			}
+
+			handler.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedStatus == http.StatusOK {
+				if !reflect.DeepEqual(gotPrincipal, principal) {
+					t.Errorf("got principal %v, expected %v", gotPrincipal, principal)
+				}
+				return
+			}
+
+			if wr.Body.String() != expectedBody {
+				t.Errorf("got body %s, expected %s", wr.Body.String(), expectedBody)
+			}
+			if wr.Header().Get("WWW-Authenticate") != bearerScheme {
+				t.Errorf("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authService.EXPECT().Authenticate(gomock.Any(), "admin_token").
+		Return(domain.Principal{UserId: 1, Name: "admin", Roles: []string{domain.RoleAdmin}}, nil).AnyTimes()
+	authService.EXPECT().Authenticate(gomock.Any(), "support_token").
+		Return(domain.Principal{UserId: 2, Name: "support", Roles: []string{domain.RoleSupport}}, nil).AnyTimes()
+	authService.EXPECT().Authenticate(gomock.Any(), "user_token").
+		Return(domain.Principal{UserId: 3, Name: "user"}, nil).AnyTimes()
+
+	handler := authMiddleware.Authenticate(authMiddleware.RequirePermission(domain.PermissionManageCatalog,
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+
+	testData := []struct {
+		TestName       string
+		Token          string
+		ExpectedStatus int
+	}{
+		{"admin", "admin_token", http.StatusOK},
+		{"support", "support_token", http.StatusForbidden},
+		{"user", "user_token", http.StatusForbidden},
+		{"anonymous", "", http.StatusUnauthorized},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/items", nil)
+			if testCase.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+testCase.Token)
+			}
+
+			handler.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Errorf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+		})
+	}
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+
+	if _, ok := PrincipalFromContext(req.Context()); ok {
+		t.Errorf("got principal from unauthenticated request")
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user"}
+	got, ok := PrincipalFromContext(withPrincipal(req.Context(), principal))
+	if !ok || !reflect.DeepEqual(got, principal) {
+		t.Errorf("got principal %v, expected %v", got, principal)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// resetThrottlePrefix keeps the reset attempts of a client apart from the logins of the users.
+const resetThrottlePrefix = "password reset from "
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type ResetPasswordRequest struct {
+	ResetToken  string `json:"resetToken"`
+	NewPassword string `json:"newPassword"`
+}
+
+type PasswordResetResponse struct {
+	ResetToken string    `json:"resetToken"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// ChangePassword must be wrapped by AuthMiddleware.Authenticate, the user
+// has to log in again since all the sessions are revoked.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	var parsedReq ChangePasswordRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	// the old password is guessed with a stolen token as well as on login
+	client := clientAddress(req)
+	err = h.loginThrottle.Allow(principal.Name, client)
+	if err != nil {
+		h.logger.Infof("password change rejected (handlers.ChangePassword): %v", err)
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	err = h.authService.ChangePassword(req.Context(), principal, parsedReq.OldPassword, parsedReq.NewPassword)
+	if errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+		h.loginThrottle.Failed(principal.Name, client)
+	} else if err == nil {
+		h.loginThrottle.Succeeded(principal.Name, client)
+	}
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	h.clearCookies(w)
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    nil,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+// CreatePasswordReset must be wrapped by AuthMiddleware.Authenticate and
+// AuthMiddleware.RequirePermission, the token is handed to the user out of band.
+func (h *AuthHandler) CreatePasswordReset(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	name := req.PathValue("name")
+
+	resetToken, err := h.authService.CreatePasswordReset(req.Context(), principal, name)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.logger.Infof("password reset for %s issued by %s", name, principal.Name)
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusCreated,
+			Data: PasswordResetResponse{
+				ResetToken: resetToken.Token,
+				ExpiresAt:  resetToken.ExpiresAt,
+			},
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+// ResetPassword needs no login, so the clients guessing reset tokens are locked out
+// the same way as the ones guessing passwords.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, req *http.Request) {
+	var parsedReq ResetPasswordRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, "", http.StatusBadRequest, err)
+		return
+	}
+
+	// a reset token does not name its user, so the attempts are counted per client only
+	client := clientAddress(req)
+	name := resetThrottlePrefix + client
+	err = h.loginThrottle.Allow(name, client)
+	if err != nil {
+		h.logger.Infof("password reset rejected (handlers.ResetPassword): %v", err)
+		writeErrorResponse(w, h.logger, req, "", 0, err)
+		return
+	}
+
+	err = h.authService.ResetPassword(req.Context(), parsedReq.ResetToken, parsedReq.NewPassword)
+	if errors.Is(err, customErrors.ErrInvalidResetToken) {
+		h.loginThrottle.Failed(name, client)
+	} else if err == nil {
+		h.loginThrottle.Succeeded(name, client)
+	}
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, "", 0, err)
+		return
+	}
+
+	h.clearCookies(w)
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: "",
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    nil,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/memory"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
+	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
+)
+
+func TestPasswordsMemory(t *testing.T) {
+	db := memory.NewDB()
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authStorage, err := memory.NewAuthStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStorage, err := memory.NewKeyStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStorage, err := memory.NewSessionStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("POST /api/login", authHandler.Login)
+	router.Handle("POST /api/password", authMiddleware.Authenticate(http.HandlerFunc(authHandler.ChangePassword)))
+	router.HandleFunc("POST /api/password/reset", authHandler.ResetPassword)
+	router.Handle("POST /api/admin/users/{name}/password-reset", authMiddleware.Authenticate(
+		authMiddleware.RequirePermission(domain.PermissionResetPasswords, http.HandlerFunc(authHandler.CreatePasswordReset))))
+
+	serve := func(url string, token string, body any) *httptest.ResponseRecorder {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(wr, req)
+
+		return wr
+	}
+
+	login := func(name string, password string) (string, int) {
+		wr := serve("/api/login", "", domain.UserCredantials{UserName: name, Password: password})
+
+		var tokenResponse TokenResponse
+		if wr.Code == http.StatusOK {
+			err := json.Unmarshal(wr.Body.Bytes(), &tokenResponse)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return tokenResponse.Token, wr.Code
+	}
+
+	ctx := context.Background()
+	for _, name := range []string{"admin", "support", "user"} {
+		_, err = authService.Register(ctx, domain.UserCredantials{UserName: name, Password: "old_password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = authService.GrantRole(ctx, "admin", domain.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = authService.GrantRole(ctx, "support", domain.RoleSupport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminToken, _ := login("admin", "old_password")
+	supportToken, _ := login("support", "old_password")
+	userToken, _ := login("user", "old_password")
+
+	// support can only read, a reset token would let it take over any account
+	wr := serve("/api/admin/users/admin/password-reset", supportToken, nil)
+	if wr.Code != http.StatusForbidden {
+		t.Errorf("got HTTP status code %d for password reset by support, expected 403", wr.Code)
+	}
+
+	wr = serve("/api/password", userToken, ChangePasswordRequest{OldPassword: "wrong_password", NewPassword: "new_password"})
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("got HTTP status code %d for wrong old password, expected 400", wr.Code)
+	}
+
+	wr = serve("/api/password", userToken, ChangePasswordRequest{OldPassword: "old_password", NewPassword: "new_password"})
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	wr = serve("/api/password", userToken, ChangePasswordRequest{OldPassword: "new_password", NewPassword: "other_password"})
+	if wr.Code != http.StatusUnauthorized {
+		t.Errorf("got HTTP status code %d with token issued before password change, expected 401", wr.Code)
+	}
+
+	if _, code := login("user", "old_password"); code != http.StatusBadRequest {
+		t.Errorf("got HTTP status code %d for old password, expected 400", code)
+	}
+	if _, code := login("user", "new_password"); code != http.StatusOK {
+		t.Errorf("got HTTP status code %d for new password, expected 200", code)
+	}
+
+	wr = serve("/api/admin/users/unknown/password-reset", adminToken, nil)
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("got HTTP status code %d for unknown user, expected 404", wr.Code)
+	}
+
+	wr = serve("/api/admin/users/user/password-reset", adminToken, nil)
+	if wr.Code != http.StatusCreated {
+		t.Fatalf("got HTTP status code %d, expected 201", wr.Code)
+	}
+
+	var resetResponse PasswordResetResponse
+	err = json.Unmarshal(wr.Body.Bytes(), &resetResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resetReq := ResetPasswordRequest{ResetToken: resetResponse.ResetToken, NewPassword: "reset_password"}
+
+	wr = serve("/api/password/reset", "", resetReq)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	wr = serve("/api/password/reset", "", resetReq)
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("got HTTP status code %d for used reset token, expected 400", wr.Code)
+	}
+
+	if _, code := login("user", "reset_password"); code != http.StatusOK {
+		t.Errorf("got HTTP status code %d for reset password, expected 200", code)
+	}
+}
+
+func TestResetPasswordThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	loginThrottle, err := services.NewLoginThrottle(logger, 3, 50, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authHandler, err := NewAuthHandler(authService, loginThrottle, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the guesses past the allowed ones do not reach the token check
+	authService.EXPECT().ResetPassword(gomock.Any(), "guessed_token", "new_password").
+		Return(customErrors.ErrInvalidResetToken).Times(4)
+
+	body := `{"resetToken":"guessed_token","newPassword":"new_password"}`
+
+	for i := 0; i < 6; i++ {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/password/reset", bytes.NewReader([]byte(body)))
+
+		authHandler.ResetPassword(wr, req)
+
+		expectedStatus := http.StatusBadRequest
+		if i >= 4 {
+			expectedStatus = http.StatusTooManyRequests
+		}
+		if wr.Code != expectedStatus {
+			t.Fatalf("got HTTP status code %d on attempt %d, expected %d", wr.Code, i+1, expectedStatus)
+		}
+	}
+}
+
+func TestChangePasswordThrottle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	loginThrottle, err := services.NewLoginThrottle(logger, 3, 50, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authHandler, err := NewAuthHandler(authService, loginThrottle, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	// the guesses past the allowed ones do not reach the password check
+	authService.EXPECT().ChangePassword(gomock.Any(), principal, "wrong_password", "new_password").
+		Return(customErrors.ErrIncorrectEmailOrPassword).Times(4)
+
+	handler := authMiddleware.Authenticate(http.HandlerFunc(authHandler.ChangePassword))
+	body := `{"oldPassword":"wrong_password","newPassword":"new_password"}`
+
+	for i := 0; i < 6; i++ {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/password", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer token")
+
+		handler.ServeHTTP(wr, req)
+
+		expectedStatus := http.StatusBadRequest
+		if i >= 4 {
+			expectedStatus = http.StatusTooManyRequests
+		}
+		if wr.Code != expectedStatus {
+			t.Fatalf("got HTTP status code %d on attempt %d, expected %d", wr.Code, i+1, expectedStatus)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type CreateProductRequest struct {
+	Name      string `json:"name"`
+	Price     int    `json:"price"`
+	Available *bool  `json:"available"`
+}
+
+type UpdateProductRequest struct {
+	Name      *string `json:"name"`
+	Price     *int    `json:"price"`
+	Available *bool   `json:"available"`
+}
+
+type RepriceProductRequest struct {
+	Price int `json:"price"`
+}
+
+type ProductService interface {
+	CreateProduct(ctx context.Context, actor string, product domain.Product) (domain.Product, error)
+	UpdateProduct(ctx context.Context, actor string, name string, update domain.ProductUpdate) (domain.Product, error)
+	ArchiveProduct(ctx context.Context, actor string, name string) (domain.Product, error)
+	RepriceProduct(ctx context.Context, actor string, name string, price int) (domain.Product, error)
+	GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error)
+}
+
+// ProductHandler serves the admin catalog endpoints, the routes must be
+// wrapped by AuthMiddleware.Authenticate and AuthMiddleware.RequireRole.
+type ProductHandler struct {
+	productService ProductService
+	logger         *zap.SugaredLogger
+}
+
+func NewProductHandler(productService ProductService, logger *zap.SugaredLogger) (*ProductHandler, error) {
+	return &ProductHandler{
+		productService: productService,
+		logger:         logger,
+	}, nil
+}
+
+func (h *ProductHandler) Create(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	var parsedReq CreateProductRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	product := domain.Product{
+		Name:      parsedReq.Name,
+		Price:     parsedReq.Price,
+		Available: parsedReq.Available == nil || *parsedReq.Available,
+	}
+
+	product, err = h.productService.CreateProduct(req.Context(), principal.Name, product)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	h.writeProduct(w, req, principal, http.StatusCreated, product)
+}
+
+func (h *ProductHandler) Update(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	var parsedReq UpdateProductRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productService.UpdateProduct(req.Context(), principal.Name, req.PathValue("name"),
+		domain.ProductUpdate{
+			Name:      parsedReq.Name,
+			Price:     parsedReq.Price,
+			Available: parsedReq.Available,
+		})
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.writeProduct(w, req, principal, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Archive(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	product, err := h.productService.ArchiveProduct(req.Context(), principal.Name, req.PathValue("name"))
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.writeProduct(w, req, principal, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Reprice(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	var parsedReq RepriceProductRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	product, err := h.productService.RepriceProduct(req.Context(), principal.Name, req.PathValue("name"),
+		parsedReq.Price)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.writeProduct(w, req, principal, http.StatusOK, product)
+}
+
+func (h *ProductHandler) Audit(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	changes, err := h.productService.GetProductAudit(req.Context(), req.PathValue("name"))
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    changes,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+func (h *ProductHandler) writeProduct(
+	w http.ResponseWriter,
+	req *http.Request,
+	principal domain.Principal,
+	status int,
+	product domain.Product) {
+	err := WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  status,
+			Data:    product,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
+)
+
+func TestProductHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	productService := serviceMocks.NewMockProductService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	productHandler, err := NewProductHandler(productService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := domain.Principal{UserId: 1, Name: "admin", Roles: []string{domain.RoleAdmin}}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(admin, nil).AnyTimes()
+
+	router := http.NewServeMux()
+	route := func(pattern string, handler http.HandlerFunc) {
+		router.Handle(pattern, authMiddleware.Authenticate(authMiddleware.RequirePermission(domain.PermissionManageCatalog, handler)))
+	}
+	route("POST /api/admin/items", productHandler.Create)
+	route("PATCH /api/admin/items/{name}", productHandler.Update)
+	route("POST /api/admin/items/{name}/archive", productHandler.Archive)
+	route("PUT /api/admin/items/{name}/price", productHandler.Reprice)
+	route("GET /api/admin/items/{name}/audit", productHandler.Audit)
+
+	mug := domain.Product{Name: "mug", Price: 30, Available: true}
+	price := 40
+	productService.EXPECT().CreateProduct(gomock.Any(), admin.Name, mug).Return(mug, nil)
+	productService.EXPECT().CreateProduct(gomock.Any(), admin.Name, domain.Product{Name: "cup", Price: 10, Available: true}).
+		Return(domain.Product{}, customErrors.ErrAlreadyExists)
+	productService.EXPECT().UpdateProduct(gomock.Any(), admin.Name, "mug", domain.ProductUpdate{Price: &price}).
+		Return(domain.Product{Name: "mug", Price: 40, Available: true}, nil)
+	productService.EXPECT().ArchiveProduct(gomock.Any(), admin.Name, "mug").
+		Return(domain.Product{Name: "mug", Price: 30}, nil)
+	productService.EXPECT().ArchiveProduct(gomock.Any(), admin.Name, "unknown").
+		Return(domain.Product{}, customErrors.ErrDoesNotExist)
+	productService.EXPECT().RepriceProduct(gomock.Any(), admin.Name, "mug", 0).
+		Return(domain.Product{}, customErrors.ErrDataNotValid)
+	productService.EXPECT().GetProductAudit(gomock.Any(), "mug").Return([]domain.ProductChange{}, nil)
+
+	testData := []struct {
+		TestName       string
+		Method         string
+		Url            string
+		Body           string
+		ExpectedStatus int
+	}{
+		{"create", http.MethodPost, "/api/admin/items", `{"name":"mug","price":30}`, http.StatusCreated},
+		{"create duplicate", http.MethodPost, "/api/admin/items", `{"name":"cup","price":10}`, http.StatusConflict},
+		{"create malformed", http.MethodPost, "/api/admin/items", `{"name":`, http.StatusBadRequest},
+		{"update", http.MethodPatch, "/api/admin/items/mug", `{"price":40}`, http.StatusOK},
+		{"archive", http.MethodPost, "/api/admin/items/mug/archive", "", http.StatusOK},
+		{"archive unknown", http.MethodPost, "/api/admin/items/unknown/archive", "", http.StatusNotFound},
+		{"reprice to zero", http.MethodPut, "/api/admin/items/mug/price", `{"price":0}`, http.StatusBadRequest},
+		{"audit", http.MethodGet, "/api/admin/items/mug/audit", "", http.StatusOK},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.Method, testCase.Url, strings.NewReader(testCase.Body))
+			req.Header.Set("Authorization", "Bearer token")
+
+			router.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Errorf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type RolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
+type RoleService interface {
+	GetRoles(ctx context.Context, name string) ([]string, error)
+	GrantRole(ctx context.Context, name string, role string) error
+	RevokeRole(ctx context.Context, name string, role string) error
+}
+
+// RoleHandler serves the admin endpoints for user roles, the routes must be
+// wrapped by AuthMiddleware.Authenticate and AuthMiddleware.RequirePermission.
+type RoleHandler struct {
+	roleService RoleService
+	logger      *zap.SugaredLogger
+}
+
+func NewRoleHandler(roleService RoleService, logger *zap.SugaredLogger) (*RoleHandler, error) {
+	return &RoleHandler{
+		roleService: roleService,
+		logger:      logger,
+	}, nil
+}
+
+func (h *RoleHandler) Get(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	h.writeRoles(w, req, principal, req.PathValue("name"))
+}
+
+func (h *RoleHandler) Grant(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	name := req.PathValue("name")
+
+	err := h.roleService.GrantRole(req.Context(), name, req.PathValue("role"))
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.logger.Infof("role %s granted to %s by %s", req.PathValue("role"), name, principal.Name)
+
+	h.writeRoles(w, req, principal, name)
+}
+
+func (h *RoleHandler) Revoke(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	name := req.PathValue("name")
+
+	err := h.roleService.RevokeRole(req.Context(), name, req.PathValue("role"))
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.logger.Infof("role %s revoked from %s by %s", req.PathValue("role"), name, principal.Name)
+
+	h.writeRoles(w, req, principal, name)
+}
+
+func (h *RoleHandler) writeRoles(w http.ResponseWriter, req *http.Request, principal domain.Principal, name string) {
+	roles, err := h.roleService.GetRoles(req.Context(), name)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    RolesResponse{Roles: roles},
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
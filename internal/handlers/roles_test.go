@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
+)
+
+func TestRoleHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	roleService := serviceMocks.NewMockRoleService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	roleHandler, err := NewRoleHandler(roleService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authService.EXPECT().Authenticate(gomock.Any(), "admin_token").
+		Return(domain.Principal{UserId: 1, Name: "admin", Roles: []string{domain.RoleAdmin}}, nil).AnyTimes()
+	authService.EXPECT().Authenticate(gomock.Any(), "support_token").
+		Return(domain.Principal{UserId: 2, Name: "support", Roles: []string{domain.RoleSupport}}, nil).AnyTimes()
+
+	router := http.NewServeMux()
+	route := func(pattern string, permission string, handler http.HandlerFunc) {
+		router.Handle(pattern, authMiddleware.Authenticate(authMiddleware.RequirePermission(permission, handler)))
+	}
+	route("GET /api/admin/users/{name}/roles", domain.PermissionViewRoles, roleHandler.Get)
+	route("PUT /api/admin/users/{name}/roles/{role}", domain.PermissionManageRoles, roleHandler.Grant)
+	route("DELETE /api/admin/users/{name}/roles/{role}", domain.PermissionManageRoles, roleHandler.Revoke)
+
+	roleService.EXPECT().GetRoles(gomock.Any(), "user").Return([]string{domain.RoleSupport}, nil).AnyTimes()
+	roleService.EXPECT().GetRoles(gomock.Any(), "unknown").Return(nil, customErrors.ErrDoesNotExist).AnyTimes()
+	roleService.EXPECT().GrantRole(gomock.Any(), "user", domain.RoleSupport).Return(nil)
+	roleService.EXPECT().GrantRole(gomock.Any(), "user", "superuser").Return(customErrors.ErrDataNotValid)
+	roleService.EXPECT().RevokeRole(gomock.Any(), "unknown", domain.RoleSupport).Return(customErrors.ErrDoesNotExist)
+
+	testData := []struct {
+		TestName       string
+		Method         string
+		Url            string
+		Token          string
+		ExpectedStatus int
+	}{
+		{"get by support", http.MethodGet, "/api/admin/users/user/roles", "support_token", http.StatusOK},
+		{"get unknown user", http.MethodGet, "/api/admin/users/unknown/roles", "admin_token", http.StatusNotFound},
+		{"grant", http.MethodPut, "/api/admin/users/user/roles/support", "admin_token", http.StatusOK},
+		{"grant unknown role", http.MethodPut, "/api/admin/users/user/roles/superuser", "admin_token", http.StatusBadRequest},
+		{"grant by support", http.MethodPut, "/api/admin/users/user/roles/admin", "support_token", http.StatusForbidden},
+		{"revoke from unknown user", http.MethodDelete, "/api/admin/users/unknown/roles/support", "admin_token",
+			http.StatusNotFound},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.Method, testCase.Url, nil)
+			req.Header.Set("Authorization", "Bearer "+testCase.Token)
+
+			router.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Errorf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
 	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
@@ -16,72 +17,61 @@ type CoinTransactionRequest struct {
 	Amount int    `json:"amount"`
 }
 
+type BuyRequest struct {
+	Items []domain.CartLine `json:"items"`
+}
+
+type GiftRequest struct {
+	ToUser string `json:"toUser"`
+	Item   string `json:"item"`
+}
+
 type ShopService interface {
 	GetInfo(ctx context.Context, username string) (domain.InventoryInfo, error)
-	SendCoin(ctx context.Context, transaction domain.Transaction) error
-	BuyItem(ctx context.Context, username string, itemName string) error
+	SendCoin(ctx context.Context, transaction domain.Transaction, idempotencyKey domain.IdempotencyKey) error
+	BuyItem(
+		ctx context.Context,
+		username string,
+		itemName string,
+		idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error)
+	BuyCart(
+		ctx context.Context,
+		username string,
+		cart domain.Cart,
+		idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error)
+	GiftItem(ctx context.Context, gift domain.Gift, idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error)
+	RefundPurchase(ctx context.Context, username string, purchaseId int64) (domain.RefundReceipt, error)
+	GetHistory(ctx context.Context, username string, filter domain.HistoryFilter) (domain.HistoryPage, error)
+	GetProducts(ctx context.Context) ([]domain.Product, error)
+	GetProduct(ctx context.Context, name string) (domain.Product, error)
 }
 
 type ShopHandler struct {
-	authService AuthService
 	shopService ShopService
 	logger      *zap.SugaredLogger
 }
 
-func NewShopHandler(authService AuthService, shopService ShopService, logger *zap.SugaredLogger) (*ShopHandler, error) {
+func NewShopHandler(shopService ShopService, logger *zap.SugaredLogger) (*ShopHandler, error) {
 	return &ShopHandler{
-		authService: authService,
 		shopService: shopService,
 		logger:      logger,
 	}, nil
 }
 
 func (h *ShopHandler) Info(w http.ResponseWriter, req *http.Request) {
-	token, err := req.Cookie("token")
-	if err != nil {
-		err = WriteResponse(
-			w,
-			h.logger,
-			ResponseData{
-				Session: "",
-				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
-				Data:    ErrorResponse{Errors: err.Error()},
-			})
-		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
-		}
-		return
-	}
-
-	ctx := context.WithValue(req.Context(), CtxSessionName, token.Value)
-
-	name, ok := h.authService.GetNameAndCheck(ctx, token.Value)
+	principal, ok := PrincipalFromContext(req.Context())
 	if !ok {
-		err = WriteResponse(
-			w,
-			h.logger,
-			ResponseData{
-				Session: token.Value,
-				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
-				Data:    ErrorResponse{Errors: customErrors.ErrUnauthenticated.Error()},
-			})
-		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
-		}
+		writeUnauthenticated(w, h.logger, req)
 		return
 	}
 
-	ctx = context.WithValue(req.Context(), CtxSessionName, name)
-
-	info, err := h.shopService.GetInfo(ctx, name)
+	info, err := h.shopService.GetInfo(req.Context(), principal.Name)
 	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: name,
+				Session: principal.Name,
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -96,7 +86,7 @@ func (h *ShopHandler) Info(w http.ResponseWriter, req *http.Request) {
 		w,
 		h.logger,
 		ResponseData{
-			Session: name,
+			Session: principal.Name,
 			Url:     req.Pattern,
 			Status:  http.StatusOK,
 			Data:    info,
@@ -107,20 +97,9 @@ func (h *ShopHandler) Info(w http.ResponseWriter, req *http.Request) {
 }
 
 func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
-	token, err := req.Cookie("token")
-	if err != nil {
-		err = WriteResponse(
-			w,
-			h.logger,
-			ResponseData{
-				Session: "",
-				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
-				Data:    ErrorResponse{Errors: err.Error()},
-			})
-		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
-		}
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
 		return
 	}
 
@@ -130,7 +109,7 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -148,7 +127,7 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -159,27 +138,25 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	ctx := context.WithValue(req.Context(), CtxSessionName, token.Value)
-
-	name, ok := h.authService.GetNameAndCheck(ctx, token.Value)
-	if !ok {
+	idempotencyKey, err := getIdempotencyKey(req, body)
+	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
-				Data:    ErrorResponse{Errors: customErrors.ErrUnauthenticated.Error()},
+				Status:  customErrors.ConvertToHttpErr(err),
+				Data:    ErrorResponse{Errors: err.Error()},
 			})
 		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
+			h.logger.Errorf("unable to write http response: %v", err)
 		}
 		return
 	}
 
 	transaction := domain.Transaction{
-		From:   name,
+		From:   principal.Name,
 		To:     parsedReq.ToUser,
 		Amount: parsedReq.Amount,
 	}
@@ -189,7 +166,7 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -200,15 +177,13 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	ctx = context.WithValue(req.Context(), CtxSessionName, name)
-
-	err = h.shopService.SendCoin(ctx, transaction)
+	err = h.shopService.SendCoin(req.Context(), transaction, idempotencyKey)
 	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -223,7 +198,7 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 		w,
 		h.logger,
 		ResponseData{
-			Session: token.Value,
+			Session: principal.Name,
 			Url:     req.Pattern,
 			Status:  http.StatusOK,
 			Data:    nil,
@@ -233,54 +208,270 @@ func (h *ShopHandler) SendCoin(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// BuyItem buys one unit of the item. It is served both by POST and by the deprecated GET route.
 func (h *ShopHandler) BuyItem(w http.ResponseWriter, req *http.Request) {
-	token, err := req.Cookie("token")
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	itemName := req.PathValue("item")
+
+	idempotencyKey, err := getIdempotencyKey(req, nil)
 	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: "",
+				Session: principal.Name,
+				Url:     req.Pattern,
+				Status:  customErrors.ConvertToHttpErr(err),
+				Data:    ErrorResponse{Errors: err.Error()},
+			})
+		if err != nil {
+			h.logger.Errorf("unable to write http response: %v", err)
+		}
+		return
+	}
+
+	receipt, err := h.shopService.BuyItem(req.Context(), principal.Name, itemName, idempotencyKey)
+	if err != nil {
+		err = WriteResponse(
+			w,
+			h.logger,
+			ResponseData{
+				Session: principal.Name,
 				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
+				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
 			})
 		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
+			h.logger.Errorf("unable to write http response: %v", err)
 		}
 		return
 	}
 
-	ctx := context.WithValue(req.Context(), CtxSessionName, token.Value)
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    receipt,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
 
-	name, ok := h.authService.GetNameAndCheck(ctx, token.Value)
+// BuyCart buys all the listed items at once, either every line is bought or none.
+func (h *ShopHandler) BuyCart(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
 	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	var parsedReq BuyRequest
+	err = json.Unmarshal(body, &parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	idempotencyKey, err := getIdempotencyKey(req, body)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	cart := domain.Cart{Lines: parsedReq.Items}
+	if err = cart.Validate(); err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	receipt, err := h.shopService.BuyCart(req.Context(), principal.Name, cart, idempotencyKey)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    receipt,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+// Gift buys an item for another user, the sender pays for it.
+func (h *ShopHandler) Gift(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	var parsedReq GiftRequest
+	err = json.Unmarshal(body, &parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	idempotencyKey, err := getIdempotencyKey(req, body)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	gift := domain.Gift{
+		From: principal.Name,
+		To:   parsedReq.ToUser,
+		Item: parsedReq.Item,
+	}
+	if err = gift.Validate(); err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	receipt, err := h.shopService.GiftItem(req.Context(), gift, idempotencyKey)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    receipt,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+func (h *ShopHandler) RefundPurchase(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	purchaseId, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	receipt, err := h.shopService.RefundPurchase(req.Context(), principal.Name, purchaseId)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    receipt,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+func (h *ShopHandler) History(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	filter, err := getHistoryFilter(req.URL.Query())
+	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: principal.Name,
 				Url:     req.Pattern,
-				Status:  customErrors.ConvertToHttpErr(customErrors.ErrUnauthenticated),
-				Data:    ErrorResponse{Errors: customErrors.ErrUnauthenticated.Error()},
+				Status:  customErrors.ConvertToHttpErr(err),
+				Data:    ErrorResponse{Errors: err.Error()},
 			})
 		if err != nil {
-			h.logger.Errorf("unable to unmarshall http request: %v", err)
+			h.logger.Errorf("unable to write http response: %v", err)
 		}
 		return
 	}
 
-	itemName := req.PathValue("item")
+	page, err := h.shopService.GetHistory(req.Context(), principal.Name, filter)
+	if err != nil {
+		err = WriteResponse(
+			w,
+			h.logger,
+			ResponseData{
+				Session: principal.Name,
+				Url:     req.Pattern,
+				Status:  customErrors.ConvertToHttpErr(err),
+				Data:    ErrorResponse{Errors: err.Error()},
+			})
+		if err != nil {
+			h.logger.Errorf("unable to write http response: %v", err)
+		}
+		return
+	}
 
-	ctx = context.WithValue(req.Context(), CtxSessionName, name)
+	err = WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    page,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
 
-	err = h.shopService.BuyItem(ctx, name, itemName)
+func (h *ShopHandler) Items(w http.ResponseWriter, req *http.Request) {
+	products, err := h.shopService.GetProducts(req.Context())
 	if err != nil {
 		err = WriteResponse(
 			w,
 			h.logger,
 			ResponseData{
-				Session: token.Value,
+				Session: "",
 				Url:     req.Pattern,
 				Status:  customErrors.ConvertToHttpErr(err),
 				Data:    ErrorResponse{Errors: err.Error()},
@@ -291,14 +482,37 @@ func (h *ShopHandler) BuyItem(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = WriteResponse(
+	err = WriteCachedResponse(
 		w,
+		req,
 		h.logger,
 		ResponseData{
-			Session: name,
+			Session: "",
 			Url:     req.Pattern,
 			Status:  http.StatusOK,
-			Data:    nil,
+			Data:    products,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
+
+func (h *ShopHandler) Item(w http.ResponseWriter, req *http.Request) {
+	product, err := h.shopService.GetProduct(req.Context(), req.PathValue("name"))
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, "", resourceStatus(err), err)
+		return
+	}
+
+	err = WriteCachedResponse(
+		w,
+		req,
+		h.logger,
+		ResponseData{
+			Session: "",
+			Url:     req.Pattern,
+			Status:  http.StatusOK,
+			Data:    product,
 		})
 	if err != nil {
 		h.logger.Errorf("unable to write http response: %v", err)
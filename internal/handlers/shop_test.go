@@ -5,17 +5,26 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap/zaptest"
 
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/memory"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/postgres"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/storagetest"
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
 	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
 )
@@ -29,55 +38,190 @@ func TestInfo(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	sessionExpiration := 60
+	shopHandler, err := NewShopHandler(shopService, logger)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	shopService.EXPECT().GetInfo(gomock.Any(), principal.Name).Return(domain.InventoryInfo{Coins: 1000}, nil)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)).ServeHTTP(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	wr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/info", nil)
+
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)).ServeHTTP(wr, req)
+	if wr.Code != http.StatusUnauthorized {
+		t.Errorf("got HTTP status code %d, expected 401", wr.Code)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	shopService := serviceMocks.NewMockShopService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	cursor := domain.HistoryCursor{SentAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Id: 7}
+	minAmount := 10
+	shopService.EXPECT().GetHistory(gomock.Any(), principal.Name, domain.HistoryFilter{
+		Direction: domain.HistoryDirectionSent,
+		MinAmount: &minAmount,
+		From:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Cursor:    &cursor,
+		Limit:     5,
+	}).Return(domain.HistoryPage{Entries: []domain.HistoryEntry{}}, nil)
+	shopService.EXPECT().GetHistory(gomock.Any(), principal.Name, domain.HistoryFilter{
+		Limit: domain.DefaultHistoryLimit,
+	}).Return(domain.HistoryPage{Entries: []domain.HistoryEntry{}}, nil)
+
+	testData := []struct {
+		TestName       string
+		Query          string
+		ExpectedStatus int
+	}{
+		{
+			"all filters",
+			"?direction=sent&minAmount=10&from=2025-01-01T03:00:00%2B03:00&limit=5&cursor=" + cursor.Encode(),
+			http.StatusOK,
+		},
+		{"default filter", "", http.StatusOK},
+		{"unknown direction", "?direction=lost", http.StatusBadRequest},
+		{"malformed amount", "?minAmount=ten", http.StatusBadRequest},
+		{"inverted amounts", "?minAmount=20&maxAmount=10", http.StatusBadRequest},
+		{"malformed date", "?from=yesterday", http.StatusBadRequest},
+		{"malformed cursor", "?cursor=!", http.StatusBadRequest},
+		{"limit too big", "?limit=1000", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/history"+testCase.Query, nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			authMiddleware.Authenticate(http.HandlerFunc(shopHandler.History)).ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Errorf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+		})
+	}
+}
+
+func TestItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shopService := serviceMocks.NewMockShopService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
 
-	authData := domain.UserCredantials{
-		UserName: "test_user",
-		Password: "test_password",
+	router := http.NewServeMux()
+	router.HandleFunc("GET /api/items", shopHandler.Items)
+	router.HandleFunc("GET /api/items/{name}", shopHandler.Item)
+
+	products := []domain.Product{
+		{Name: "cup", Price: 20, Available: true},
+		{Name: "pen", Price: 10, Available: true},
+	}
+	shopService.EXPECT().GetProducts(gomock.Any()).Return(products, nil).Times(3)
+	shopService.EXPECT().GetProduct(gomock.Any(), "cup").Return(products[0], nil)
+	shopService.EXPECT().GetProduct(gomock.Any(), "unknown").
+		Return(domain.Product{}, customErrors.ErrDoesNotExist)
+
+	serve := func(url string, ifNoneMatch string) *httptest.ResponseRecorder {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		router.ServeHTTP(wr, req)
+
+		return wr
 	}
 
-	ctx := context.WithValue(context.Background(), CtxSessionName, authData.UserName)
-	authService.EXPECT().LoginOrCreateUser(ctx, authData).Return("token", nil)
+	wr := serve("/api/items", "")
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
 
-	jsonData, err := json.Marshal(authData)
+	var gotProducts []domain.Product
+	err = json.Unmarshal(wr.Body.Bytes(), &gotProducts)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotProducts, products) {
+		t.Errorf("got products %v, expected %v", gotProducts, products)
 	}
 
-	wr := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(jsonData))
+	etag := wr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("missing ETag header")
+	}
 
-	authHandler.Auth(wr, req)
+	wr = serve("/api/items", `"other", W/`+etag)
+	if wr.Code != http.StatusNotModified {
+		t.Errorf("got HTTP status code %d, expected 304", wr.Code)
+	}
+	if wr.Body.Len() != 0 {
+		t.Errorf("got body %s, expected empty body", wr.Body.String())
+	}
+
+	wr = serve("/api/items", `"other"`)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/api/info", nil)
-
-	shopHandler.Info(wr, req)
+	wr = serve("/api/items/cup", "")
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
+	if wr.Header().Get("ETag") == etag {
+		t.Errorf("product and catalog have the same ETag")
+	}
+
+	wr = serve("/api/items/unknown", "")
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("got HTTP status code %d, expected 404", wr.Code)
+	}
 }
 
 func TestInfoPostgres(t *testing.T) {
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost",
-		"5432",
-		"postgres",
-		"root1234",
-		"shop",
-	))
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
 	if err != nil {
 		log.Fatalf("error in postgres initialization: %v\n", err)
 	}
@@ -95,24 +239,38 @@ func TestInfoPostgres(t *testing.T) {
 		log.Fatalf("error in shop storage initialization: %v\n", err)
 	}
 
-	authService, err := services.NewAuthService(authStorage, logger, 10, sessionExpiration)
+	keyStorage, err := postgres.NewKeyStorage(pool)
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := postgres.NewSessionStorage(pool)
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	shopService, err := services.NewShopService(shopStorage, logger)
+	shopService, err := services.NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
 	if err != nil {
 		log.Fatalf("error in auth handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
 
 	authData := domain.UserCredantials{
 		UserName: "test_user",
@@ -134,7 +292,7 @@ func TestInfoPostgres(t *testing.T) {
 
 	req = httptest.NewRequest(http.MethodGet, "/api/info", nil)
 
-	shopHandler.Info(wr, req)
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)).ServeHTTP(wr, req)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
@@ -149,66 +307,42 @@ func TestSendCoin(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	sessionExpiration := 60
-
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
 	if err != nil {
-		log.Fatalf("error in shop handler initialization: %v\n", err)
-	}
-
-	authData := domain.UserCredantials{
-		UserName: "test_user",
-		Password: "test_password",
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
 	}
 
-	ctx := context.WithValue(context.Background(), CtxSessionName, authData.UserName)
-	authService.EXPECT().LoginOrCreateUser(ctx, authData).Return("token", nil)
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
 
-	jsonData, err := json.Marshal(authData)
-	if err != nil {
-		t.Error(err)
-	}
-
-	wr := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(jsonData))
-
-	authHandler.Auth(wr, req)
-	if wr.Code != http.StatusOK {
-		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
-	}
-
-	transactionData := domain.Transaction{
-		From:   "test_user",
-		To:     "test_password",
+	transaction := domain.Transaction{
+		From:   principal.Name,
+		To:     "another_user",
 		Amount: 100,
 	}
+	shopService.EXPECT().SendCoin(gomock.Any(), transaction, domain.IdempotencyKey{}).Return(nil)
 
-	jsonData, err = json.Marshal(transactionData)
+	jsonData, err := json.Marshal(CoinTransactionRequest{ToUser: transaction.To, Amount: transaction.Amount})
 	if err != nil {
 		t.Error(err)
 	}
 
-	req = httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(jsonData))
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(jsonData))
+	req.AddCookie(&http.Cookie{Name: tokenCookieName, Value: "token"})
 
-	shopHandler.SendCoin(wr, req)
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.SendCoin)).ServeHTTP(wr, req)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
 }
 
 func TestSendCoinPostgres(t *testing.T) {
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost",
-		"5432",
-		"postgres",
-		"root1234",
-		"shop",
-	))
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
 	if err != nil {
 		log.Fatalf("error in postgres initialization: %v\n", err)
 	}
@@ -226,24 +360,38 @@ func TestSendCoinPostgres(t *testing.T) {
 		log.Fatalf("error in shop storage initialization: %v\n", err)
 	}
 
-	authService, err := services.NewAuthService(authStorage, logger, 10, sessionExpiration)
+	keyStorage, err := postgres.NewKeyStorage(pool)
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := postgres.NewSessionStorage(pool)
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	shopService, err := services.NewShopService(shopStorage, logger)
+	shopService, err := services.NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
 	if err != nil {
 		log.Fatalf("error in auth handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
 
 	authData := domain.UserCredantials{
 		UserName: "test_user",
@@ -276,7 +424,7 @@ func TestSendCoinPostgres(t *testing.T) {
 
 	req = httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(jsonData))
 
-	shopHandler.SendCoin(wr, req)
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.SendCoin)).ServeHTTP(wr, req)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
@@ -291,25 +439,361 @@ func TestBuyItem(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	sessionExpiration := 60
+	shopHandler, err := NewShopHandler(shopService, logger)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	receipt := domain.PurchaseReceipt{
+		Id:       7,
+		Item:     "t-shirt",
+		Price:    80,
+		BoughtAt: time.Now().UTC().Truncate(time.Microsecond),
+		Balance:  920,
+	}
+	shopService.EXPECT().BuyItem(gomock.Any(), principal.Name, "t-shirt", domain.IdempotencyKey{}).
+		Return(receipt, nil).Times(2)
+
+	router := http.NewServeMux()
+	router.Handle("POST /api/buy/{item}", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem)))
+	router.Handle("GET /api/buy/{item}", Deprecated(authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem))))
+	router.Handle("GET /api/history", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.History)))
+	router.HandleFunc("GET /api/items", shopHandler.Items)
+	router.HandleFunc("GET /api/items/{name}", shopHandler.Item)
+
+	testData := []struct {
+		TestName   string
+		Method     string
+		Deprecated bool
+	}{
+		{"buy with post", http.MethodPost, false},
+		{"buy with deprecated get", http.MethodGet, true},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.Method, "/api/buy/t-shirt", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			router.ServeHTTP(wr, req)
+			if wr.Code != http.StatusOK {
+				t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+			}
+
+			if deprecated := wr.Header().Get("Deprecation") != ""; deprecated != testCase.Deprecated {
+				t.Errorf("got Deprecation header %q", wr.Header().Get("Deprecation"))
+			}
+
+			var gotReceipt domain.PurchaseReceipt
+			err := json.Unmarshal(wr.Body.Bytes(), &gotReceipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotReceipt != receipt {
+				t.Errorf("got receipt %v, expected %v", gotReceipt, receipt)
+			}
+		})
+	}
+}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+func TestBuyCart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	shopService := serviceMocks.NewMockShopService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	cart := domain.Cart{Lines: []domain.CartLine{{Item: "pen", Quantity: 5}, {Item: "cup", Quantity: 2}}}
+	receipt := domain.CartReceipt{
+		Lines: []domain.CartReceiptLine{
+			{Item: "pen", Quantity: 5, Price: 10, Cost: 50},
+			{Item: "cup", Quantity: 2, Price: 20, Cost: 40},
+		},
+		Total:   90,
+		Balance: 910,
+	}
+	expensiveCart := domain.Cart{Lines: []domain.CartLine{{Item: "pink-hoody", Quantity: 3}}}
+
+	shopService.EXPECT().BuyCart(gomock.Any(), principal.Name, cart, domain.IdempotencyKey{}).Return(receipt, nil)
+	shopService.EXPECT().BuyCart(gomock.Any(), principal.Name, expensiveCart, domain.IdempotencyKey{}).
+		Return(domain.CartReceipt{}, customErrors.ErrInsufficientFunds)
+
+	testData := []struct {
+		TestName        string
+		Body            string
+		ExpectedStatus  int
+		ExpectedReceipt *domain.CartReceipt
+	}{
+		{"buy cart", `{"items":[{"item":"pen","quantity":5},{"item":"cup","quantity":2}]}`, http.StatusOK, &receipt},
+		{"insufficient funds", `{"items":[{"item":"pink-hoody","quantity":3}]}`, http.StatusBadRequest, nil},
+		{"empty cart", `{"items":[]}`, http.StatusBadRequest, nil},
+		{"item listed twice", `{"items":[{"item":"pen","quantity":1},{"item":"pen","quantity":1}]}`,
+			http.StatusBadRequest, nil},
+		{"malformed body", `{"items":`, http.StatusBadRequest, nil},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/buy", strings.NewReader(testCase.Body))
+			req.Header.Set("Authorization", "Bearer token")
+
+			authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyCart)).ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedReceipt == nil {
+				return
+			}
+
+			var gotReceipt domain.CartReceipt
+			err := json.Unmarshal(wr.Body.Bytes(), &gotReceipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotReceipt, *testCase.ExpectedReceipt) {
+				t.Errorf("got receipt %v, expected %v", gotReceipt, *testCase.ExpectedReceipt)
+			}
+		})
+	}
+}
+
+func TestGift(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	shopService := serviceMocks.NewMockShopService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	gift := domain.Gift{From: principal.Name, To: "test_2_user", Item: "cup"}
+	receipt := domain.GiftReceipt{
+		Id:      7,
+		To:      gift.To,
+		Item:    gift.Item,
+		Price:   20,
+		SentAt:  time.Now().UTC().Truncate(time.Microsecond),
+		Balance: 980,
+	}
+	expensiveGift := domain.Gift{From: principal.Name, To: "test_2_user", Item: "pink-hoody"}
+
+	shopService.EXPECT().GiftItem(gomock.Any(), gift, domain.IdempotencyKey{}).Return(receipt, nil)
+	shopService.EXPECT().GiftItem(gomock.Any(), expensiveGift, domain.IdempotencyKey{}).
+		Return(domain.GiftReceipt{}, customErrors.ErrInsufficientFunds)
+
+	testData := []struct {
+		TestName        string
+		Body            string
+		ExpectedStatus  int
+		ExpectedReceipt *domain.GiftReceipt
+	}{
+		{"gift", `{"toUser":"test_2_user","item":"cup"}`, http.StatusOK, &receipt},
+		{"insufficient funds", `{"toUser":"test_2_user","item":"pink-hoody"}`, http.StatusBadRequest, nil},
+		{"gift to yourself", `{"toUser":"test_user","item":"cup"}`, http.StatusBadRequest, nil},
+		{"short recipient name", `{"toUser":"te","item":"cup"}`, http.StatusBadRequest, nil},
+		{"empty item", `{"toUser":"test_2_user"}`, http.StatusBadRequest, nil},
+		{"malformed body", `{"toUser":`, http.StatusBadRequest, nil},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/gift", strings.NewReader(testCase.Body))
+			req.Header.Set("Authorization", "Bearer token")
+
+			authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Gift)).ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedReceipt == nil {
+				return
+			}
+
+			var gotReceipt domain.GiftReceipt
+			err := json.Unmarshal(wr.Body.Bytes(), &gotReceipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotReceipt != *testCase.ExpectedReceipt {
+				t.Errorf("got receipt %v, expected %v", gotReceipt, *testCase.ExpectedReceipt)
+			}
+		})
+	}
+}
+
+func TestRefundPurchase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	shopService := serviceMocks.NewMockShopService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopHandler, err := NewShopHandler(shopService, logger)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	receipt := domain.RefundReceipt{
+		PurchaseId: 7,
+		Item:       "t-shirt",
+		Amount:     80,
+		RefundedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Balance:    1000,
+	}
+	shopService.EXPECT().RefundPurchase(gomock.Any(), principal.Name, int64(7)).Return(receipt, nil)
+	shopService.EXPECT().RefundPurchase(gomock.Any(), principal.Name, int64(8)).
+		Return(domain.RefundReceipt{}, customErrors.ErrAlreadyExists)
+	shopService.EXPECT().RefundPurchase(gomock.Any(), principal.Name, int64(9)).
+		Return(domain.RefundReceipt{}, customErrors.ErrNotAvailable)
+	shopService.EXPECT().RefundPurchase(gomock.Any(), principal.Name, int64(10)).
+		Return(domain.RefundReceipt{}, customErrors.ErrDoesNotExist)
+
+	router := http.NewServeMux()
+	router.Handle("POST /api/purchases/{id}/refund",
+		authMiddleware.Authenticate(http.HandlerFunc(shopHandler.RefundPurchase)))
+
+	testData := []struct {
+		TestName       string
+		Id             string
+		ExpectedStatus int
+	}{
+		{"refund", "7", http.StatusOK},
+		{"already refunded", "8", http.StatusConflict},
+		{"refund window is over", "9", http.StatusBadRequest},
+		{"unknown purchase", "10", http.StatusNotFound},
+		{"malformed id", "t-shirt", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/purchases/"+testCase.Id+"/refund", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			router.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedStatus != http.StatusOK {
+				return
+			}
+
+			var gotReceipt domain.RefundReceipt
+			err := json.Unmarshal(wr.Body.Bytes(), &gotReceipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotReceipt != receipt {
+				t.Errorf("got receipt %v, expected %v", gotReceipt, receipt)
+			}
+		})
+	}
+}
+
+func TestBuyItemPostgres(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
+	if err != nil {
+		log.Fatalf("error in postgres initialization: %v\n", err)
+	}
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	sessionExpiration := 60
+
+	authStorage, err := postgres.NewAuthStorage(pool)
+	if err != nil {
+		log.Fatalf("error in auth storage initialization: %v\n", err)
+	}
+	shopStorage, err := postgres.NewShopStorage(pool)
+	if err != nil {
+		log.Fatalf("error in shop storage initialization: %v\n", err)
+	}
+
+	keyStorage, err := postgres.NewKeyStorage(pool)
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := postgres.NewSessionStorage(pool)
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	shopService, err := services.NewShopService(shopStorage, logger, time.Hour, time.Hour)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
+	if err != nil {
+		log.Fatalf("error in auth handler initialization: %v\n", err)
+	}
+	shopHandler, err := NewShopHandler(shopService, logger)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
 
 	authData := domain.UserCredantials{
 		UserName: "test_user",
 		Password: "test_password",
 	}
 
-	ctx := context.WithValue(context.Background(), CtxSessionName, authData.UserName)
-	authService.EXPECT().LoginOrCreateUser(ctx, authData).Return("token", nil)
-
 	jsonData, err := json.Marshal(authData)
 	if err != nil {
 		t.Error(err)
@@ -323,23 +807,16 @@ func TestBuyItem(t *testing.T) {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/api/buy/t-shirt", bytes.NewReader(jsonData))
+	req = httptest.NewRequest(http.MethodPost, "/api/buy/t-shirt", bytes.NewReader(jsonData))
 
-	shopHandler.BuyItem(wr, req)
+	authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem)).ServeHTTP(wr, req)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
 }
 
-func TestBuyItemPostgres(t *testing.T) {
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost",
-		"5432",
-		"postgres",
-		"root1234",
-		"shop",
-	))
+func TestConcurrentShopPostgres(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
 	if err != nil {
 		log.Fatalf("error in postgres initialization: %v\n", err)
 	}
@@ -357,47 +834,363 @@ func TestBuyItemPostgres(t *testing.T) {
 		log.Fatalf("error in shop storage initialization: %v\n", err)
 	}
 
-	authService, err := services.NewAuthService(authStorage, logger, 10, sessionExpiration)
+	keyStorage, err := postgres.NewKeyStorage(pool)
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := postgres.NewSessionStorage(pool)
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
+
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	shopService, err := services.NewShopService(shopStorage, logger)
+	shopService, err := services.NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in auth service initialization: %v\n", err)
 	}
 
-	authHandler, err := NewAuthHandler(authService, logger, sessionExpiration)
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
 	if err != nil {
 		log.Fatalf("error in auth handler initialization: %v\n", err)
 	}
-	shopHandler, err := NewShopHandler(authService, shopService, logger)
+	shopHandler, err := NewShopHandler(shopService, logger)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
 
-	authData := domain.UserCredantials{
-		UserName: "test_user",
-		Password: "test_password",
+	router := http.NewServeMux()
+	router.Handle("GET /api/info", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)))
+	router.HandleFunc("POST /api/auth", authHandler.Auth)
+	router.Handle("POST /api/sendCoin", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.SendCoin)))
+	router.Handle("POST /api/buy/{item}", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem)))
+	router.Handle("GET /api/history", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.History)))
+	router.HandleFunc("GET /api/items", shopHandler.Items)
+	router.HandleFunc("GET /api/items/{name}", shopHandler.Item)
+
+	login := func(name string) string {
+		jsonData, err := json.Marshal(domain.UserCredantials{UserName: name, Password: "test_password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wr := httptest.NewRecorder()
+		router.ServeHTTP(wr, httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(jsonData)))
+		if wr.Code != http.StatusOK {
+			t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+		}
+
+		var tokenResponse TokenResponse
+		err = json.Unmarshal(wr.Body.Bytes(), &tokenResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tokenResponse.Token
 	}
 
-	jsonData, err := json.Marshal(authData)
+	getCoins := func(token string) int {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(wr, req)
+		if wr.Code != http.StatusOK {
+			t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+		}
+
+		var info domain.InventoryInfo
+		err := json.Unmarshal(wr.Body.Bytes(), &info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return info.Coins
+	}
+
+	suffix := time.Now().UnixNano()
+	sender := fmt.Sprintf("sender_%d", suffix)
+	recipient := fmt.Sprintf("recipient_%d", suffix)
+
+	senderToken := login(sender)
+	recipientToken := login(recipient)
+	initialCoins := getCoins(senderToken)
+
+	const (
+		workers    = 60
+		itemPrice  = 10
+		sendAmount = 30
+	)
+
+	var (
+		wg          sync.WaitGroup
+		boughtCount atomic.Int64
+		sentCount   atomic.Int64
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var req *http.Request
+			if i%2 == 0 {
+				req = httptest.NewRequest(http.MethodPost, "/api/buy/pen", nil)
+			} else {
+				jsonData, err := json.Marshal(CoinTransactionRequest{ToUser: recipient, Amount: sendAmount})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				req = httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(jsonData))
+			}
+			req.Header.Set("Authorization", "Bearer "+senderToken)
+
+			wr := httptest.NewRecorder()
+			router.ServeHTTP(wr, req)
+
+			switch {
+			case wr.Code == http.StatusOK && i%2 == 0:
+				boughtCount.Add(1)
+			case wr.Code == http.StatusOK:
+				sentCount.Add(1)
+			case wr.Code != http.StatusBadRequest:
+				t.Errorf("got HTTP status code %d, expected 200 or 400", wr.Code)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	spent := int(boughtCount.Load())*itemPrice + int(sentCount.Load())*sendAmount
+	if spent > initialCoins {
+		t.Errorf("spent %d coins out of %d", spent, initialCoins)
+	}
+
+	if coins := getCoins(senderToken); coins != initialCoins-spent {
+		t.Errorf("sender has %d coins, expected %d", coins, initialCoins-spent)
+	}
+
+	if coins := getCoins(recipientToken); coins != initialCoins+int(sentCount.Load())*sendAmount {
+		t.Errorf("recipient has %d coins, expected %d", coins, initialCoins+int(sentCount.Load())*sendAmount)
+	}
+}
+
+func TestShopMemory(t *testing.T) {
+	db := memory.NewDB()
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	sessionExpiration := 60
+
+	authStorage, err := memory.NewAuthStorage(db)
 	if err != nil {
-		t.Error(err)
+		log.Fatalf("error in auth storage initialization: %v\n", err)
+	}
+	shopStorage, err := memory.NewShopStorage(db)
+	if err != nil {
+		log.Fatalf("error in shop storage initialization: %v\n", err)
 	}
 
-	wr := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(jsonData))
+	keyStorage, err := memory.NewKeyStorage()
+	if err != nil {
+		log.Fatalf("error in key storage initialization: %v\n", err)
+	}
+	sessionStorage, err := memory.NewSessionStorage()
+	if err != nil {
+		log.Fatalf("error in session storage initialization: %v\n", err)
+	}
 
-	authHandler.Auth(wr, req)
+	authService, err := services.NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, services.DefaultPasswordHashParams, 4, sessionExpiration, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	shopService, err := services.NewShopService(shopStorage, logger, time.Hour, time.Hour)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	authHandler, err := NewAuthHandler(authService, newTestLoginThrottle(t), logger, false)
+	if err != nil {
+		log.Fatalf("error in auth handler initialization: %v\n", err)
+	}
+	shopHandler, err := NewShopHandler(shopService, logger)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	router := http.NewServeMux()
+	router.Handle("GET /api/info", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.Info)))
+	router.HandleFunc("POST /api/auth", authHandler.Auth)
+	router.Handle("POST /api/sendCoin", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.SendCoin)))
+	router.Handle("POST /api/buy/{item}", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.BuyItem)))
+	router.Handle("GET /api/history", authMiddleware.Authenticate(http.HandlerFunc(shopHandler.History)))
+	router.HandleFunc("GET /api/items", shopHandler.Items)
+	router.HandleFunc("GET /api/items/{name}", shopHandler.Item)
+	router.Handle("POST /api/logout", authMiddleware.Authenticate(http.HandlerFunc(authHandler.Logout)))
+	router.HandleFunc("POST /api/auth/refresh", authHandler.Refresh)
+
+	serve := func(method string, url string, token string, body any) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != nil {
+			jsonData, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader = bytes.NewReader(jsonData)
+		}
+
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, reader)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(wr, req)
+
+		return wr
+	}
+
+	login := func(name string) TokenResponse {
+		wr := serve(http.MethodPost, "/api/auth", "",
+			domain.UserCredantials{UserName: name, Password: "test_password"})
+		if wr.Code != http.StatusOK {
+			t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+		}
+
+		var tokenResponse TokenResponse
+		err := json.Unmarshal(wr.Body.Bytes(), &tokenResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tokenResponse
+	}
+
+	senderToken := login("sender").Token
+	recipientTokens := login("recipient")
+	recipientToken := recipientTokens.Token
+
+	wr := serve(http.MethodPost, "/api/sendCoin", senderToken, map[string]any{"toUser": "recipient", "amount": 100})
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
 
-	req = httptest.NewRequest(http.MethodGet, "/api/buy/t-shirt", bytes.NewReader(jsonData))
+	wr = serve(http.MethodPost, "/api/sendCoin", senderToken, map[string]any{"toUser": "unknown", "amount": 100})
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("got HTTP status code %d, expected 400", wr.Code)
+	}
 
-	shopHandler.BuyItem(wr, req)
+	wr = serve(http.MethodPost, "/api/buy/pink-hoody", recipientToken, nil)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	var receipt domain.PurchaseReceipt
+	err = json.Unmarshal(wr.Body.Bytes(), &receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.Id == 0 || receipt.Item != "pink-hoody" || receipt.Price != 500 || receipt.Balance != 600 {
+		t.Errorf("unexpected receipt %v", receipt)
+	}
+
+	wr = serve(http.MethodGet, "/api/info", recipientToken, nil)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	var info domain.InventoryInfo
+	err = json.Unmarshal(wr.Body.Bytes(), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Coins != 600 {
+		t.Errorf("got %d coins, expected 600", info.Coins)
+	}
+	if len(info.Inventory) != 1 || info.Inventory[0] != (domain.Item{Type: "pink-hoody", Quantity: 1}) {
+		t.Errorf("unexpected inventory %v", info.Inventory)
+	}
+	if len(info.CoinHistory.Recieved) != 1 || info.CoinHistory.Recieved[0].From != "sender" {
+		t.Errorf("unexpected coin history %v", info.CoinHistory)
+	}
+
+	wr = serve(http.MethodGet, "/api/history?direction=received", recipientToken, nil)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	var page domain.HistoryPage
+	err = json.Unmarshal(wr.Body.Bytes(), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Entries) != 1 || page.Entries[0].Counterparty != "sender" || page.Entries[0].Amount != 100 {
+		t.Errorf("unexpected history page %v", page)
+	}
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: refreshToken})
+	}
+
+	wr = refresh(recipientTokens.RefreshToken)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("got HTTP status code %d, expected 200", wr.Code)
+	}
+
+	var refreshed TokenResponse
+	err = json.Unmarshal(wr.Body.Bytes(), &refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wr = serve(http.MethodGet, "/api/info", refreshed.Token, nil)
+	if wr.Code != http.StatusOK {
+		t.Errorf("got HTTP status code %d with refreshed token, expected 200", wr.Code)
+	}
+
+	wr = refresh(recipientTokens.RefreshToken)
+	if wr.Code != http.StatusUnauthorized {
+		t.Errorf("got HTTP status code %d for reused refresh token, expected 401", wr.Code)
+	}
+
+	wr = refresh(refreshed.RefreshToken)
+	if wr.Code != http.StatusUnauthorized {
+		t.Errorf("got HTTP status code %d for refresh token of revoked family, expected 401", wr.Code)
+	}
+
+	// the reuse ends the session, so its access tokens stop working as well
+	for _, token := range []string{recipientToken, refreshed.Token} {
+		wr = serve(http.MethodGet, "/api/info", token, nil)
+		if wr.Code != http.StatusUnauthorized {
+			t.Errorf("got HTTP status code %d with access token of revoked session, expected 401", wr.Code)
+		}
+	}
+
+	recipientToken = login("recipient").Token
+
+	wr = serve(http.MethodPost, "/api/logout", recipientToken, nil)
 	if wr.Code != http.StatusOK {
 		t.Errorf("got HTTP status code %d, expected 200", wr.Code)
 	}
+
+	wr = serve(http.MethodGet, "/api/info", recipientToken, nil)
+	if wr.Code != http.StatusUnauthorized {
+		t.Errorf("got HTTP status code %d after logout, expected 401", wr.Code)
+	}
 }
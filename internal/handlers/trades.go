@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type TradeRequest struct {
+	ToUser    string           `json:"toUser"`
+	Offered   domain.TradeSide `json:"offered"`
+	Requested domain.TradeSide `json:"requested"`
+}
+
+type TradeService interface {
+	ProposeTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error)
+	AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+	DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+	GetTrades(ctx context.Context, username string) ([]domain.Trade, error)
+	GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+}
+
+// TradeHandler serves the trade endpoints, the routes must be wrapped by AuthMiddleware.Authenticate.
+type TradeHandler struct {
+	tradeService TradeService
+	logger       *zap.SugaredLogger
+}
+
+func NewTradeHandler(tradeService TradeService, logger *zap.SugaredLogger) (*TradeHandler, error) {
+	return &TradeHandler{
+		tradeService: tradeService,
+		logger:       logger,
+	}, nil
+}
+
+func (h *TradeHandler) Propose(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	var parsedReq TradeRequest
+	err := json.NewDecoder(req.Body).Decode(&parsedReq)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	offer := domain.TradeOffer{
+		From:      principal.Name,
+		To:        parsedReq.ToUser,
+		Offered:   parsedReq.Offered,
+		Requested: parsedReq.Requested,
+	}
+	if err = offer.Validate(); err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	trade, err := h.tradeService.ProposeTrade(req.Context(), offer)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	h.writeData(w, req, principal, http.StatusCreated, trade)
+}
+
+func (h *TradeHandler) List(w http.ResponseWriter, req *http.Request) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	trades, err := h.tradeService.GetTrades(req.Context(), principal.Name)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, 0, err)
+		return
+	}
+
+	h.writeData(w, req, principal, http.StatusOK, trades)
+}
+
+func (h *TradeHandler) Get(w http.ResponseWriter, req *http.Request) {
+	h.serveTrade(w, req, h.tradeService.GetTrade)
+}
+
+func (h *TradeHandler) Accept(w http.ResponseWriter, req *http.Request) {
+	h.serveTrade(w, req, h.tradeService.AcceptTrade)
+}
+
+func (h *TradeHandler) Decline(w http.ResponseWriter, req *http.Request) {
+	h.serveTrade(w, req, h.tradeService.DeclineTrade)
+}
+
+// serveTrade handles the routes acting on the trade from the id path value.
+func (h *TradeHandler) serveTrade(
+	w http.ResponseWriter,
+	req *http.Request,
+	action func(ctx context.Context, username string, tradeId int64) (domain.Trade, error)) {
+	principal, ok := PrincipalFromContext(req.Context())
+	if !ok {
+		writeUnauthenticated(w, h.logger, req)
+		return
+	}
+
+	tradeId, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, http.StatusBadRequest, err)
+		return
+	}
+
+	trade, err := action(req.Context(), principal.Name, tradeId)
+	if err != nil {
+		writeErrorResponse(w, h.logger, req, principal.Name, resourceStatus(err), err)
+		return
+	}
+
+	h.writeData(w, req, principal, http.StatusOK, trade)
+}
+
+func (h *TradeHandler) writeData(
+	w http.ResponseWriter,
+	req *http.Request,
+	principal domain.Principal,
+	status int,
+	data any) {
+	err := WriteResponse(
+		w,
+		h.logger,
+		ResponseData{
+			Session: principal.Name,
+			Url:     req.Pattern,
+			Status:  status,
+			Data:    data,
+		})
+	if err != nil {
+		h.logger.Errorf("unable to write http response: %v", err)
+	}
+}
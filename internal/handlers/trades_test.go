@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	serviceMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/services/mocks"
+)
+
+func TestProposeTrade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	tradeService := serviceMocks.NewMockTradeService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	tradeHandler, err := NewTradeHandler(tradeService, logger)
+	if err != nil {
+		log.Fatalf("error in trade handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 1, Name: "test_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	offer := domain.TradeOffer{
+		From:      principal.Name,
+		To:        "test_2_user",
+		Offered:   domain.TradeSide{Items: []domain.TradeItem{{Item: "pen", Quantity: 2}}},
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+	}
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	trade := domain.Trade{
+		Id:        3,
+		From:      offer.From,
+		To:        offer.To,
+		Offered:   offer.Offered,
+		Requested: offer.Requested,
+		Status:    domain.TradeStatusPending,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(time.Hour),
+	}
+	richOffer := domain.TradeOffer{From: principal.Name, To: "test_2_user", Offered: domain.TradeSide{Coins: 5000}}
+
+	tradeService.EXPECT().ProposeTrade(gomock.Any(), offer).Return(trade, nil)
+	tradeService.EXPECT().ProposeTrade(gomock.Any(), richOffer).
+		Return(domain.Trade{}, customErrors.ErrInsufficientFunds)
+
+	testData := []struct {
+		TestName       string
+		Body           string
+		ExpectedStatus int
+		ExpectedTrade  *domain.Trade
+	}{
+		{
+			"trade",
+			`{"toUser":"test_2_user","offered":{"items":[{"item":"pen","quantity":2}]},"requested":{"items":[{"item":"cup","quantity":1}]}}`,
+			http.StatusCreated,
+			&trade,
+		},
+		{"insufficient funds", `{"toUser":"test_2_user","offered":{"coins":5000}}`, http.StatusBadRequest, nil},
+		{"trade with yourself", `{"toUser":"test_user","offered":{"coins":10}}`, http.StatusBadRequest, nil},
+		{"empty trade", `{"toUser":"test_2_user"}`, http.StatusBadRequest, nil},
+		{"negative coins", `{"toUser":"test_2_user","requested":{"coins":-10}}`, http.StatusBadRequest, nil},
+		{"malformed body", `{"toUser":`, http.StatusBadRequest, nil},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/trades", strings.NewReader(testCase.Body))
+			req.Header.Set("Authorization", "Bearer token")
+
+			authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Propose)).ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedTrade == nil {
+				return
+			}
+
+			var gotTrade domain.Trade
+			err := json.Unmarshal(wr.Body.Bytes(), &gotTrade)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotTrade, *testCase.ExpectedTrade) {
+				t.Errorf("got trade %v, expected %v", gotTrade, *testCase.ExpectedTrade)
+			}
+		})
+	}
+}
+
+func TestResolveTrade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authService := serviceMocks.NewMockAuthService(ctrl)
+	tradeService := serviceMocks.NewMockTradeService(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	tradeHandler, err := NewTradeHandler(tradeService, logger)
+	if err != nil {
+		log.Fatalf("error in trade handler initialization: %v\n", err)
+	}
+	authMiddleware, err := NewAuthMiddleware(authService, logger)
+	if err != nil {
+		log.Fatalf("error in auth middleware initialization: %v\n", err)
+	}
+
+	principal := domain.Principal{UserId: 2, Name: "test_2_user", TokenId: "test_jti"}
+	authService.EXPECT().Authenticate(gomock.Any(), "token").Return(principal, nil).AnyTimes()
+
+	tradeService.EXPECT().AcceptTrade(gomock.Any(), principal.Name, int64(3)).
+		Return(domain.Trade{Id: 3, Status: domain.TradeStatusAccepted}, nil)
+	tradeService.EXPECT().AcceptTrade(gomock.Any(), principal.Name, int64(4)).
+		Return(domain.Trade{}, customErrors.ErrAlreadyExists)
+	tradeService.EXPECT().AcceptTrade(gomock.Any(), principal.Name, int64(5)).
+		Return(domain.Trade{}, customErrors.ErrNotAvailable)
+	tradeService.EXPECT().AcceptTrade(gomock.Any(), principal.Name, int64(6)).
+		Return(domain.Trade{}, customErrors.ErrForbidden)
+	tradeService.EXPECT().AcceptTrade(gomock.Any(), principal.Name, int64(7)).
+		Return(domain.Trade{}, customErrors.ErrDoesNotExist)
+	tradeService.EXPECT().DeclineTrade(gomock.Any(), principal.Name, int64(3)).
+		Return(domain.Trade{Id: 3, Status: domain.TradeStatusDeclined}, nil)
+	tradeService.EXPECT().GetTrade(gomock.Any(), principal.Name, int64(3)).
+		Return(domain.Trade{Id: 3, Status: domain.TradeStatusExpired}, nil)
+	tradeService.EXPECT().GetTrade(gomock.Any(), principal.Name, int64(7)).
+		Return(domain.Trade{}, customErrors.ErrDoesNotExist)
+
+	router := http.NewServeMux()
+	router.Handle("GET /api/trades/{id}", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Get)))
+	router.Handle("POST /api/trades/{id}/accept", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Accept)))
+	router.Handle("POST /api/trades/{id}/decline", authMiddleware.Authenticate(http.HandlerFunc(tradeHandler.Decline)))
+
+	testData := []struct {
+		TestName       string
+		Method         string
+		Url            string
+		ExpectedStatus int
+		TradeStatus    string
+	}{
+		{"accept", http.MethodPost, "/api/trades/3/accept", http.StatusOK, domain.TradeStatusAccepted},
+		{"already resolved", http.MethodPost, "/api/trades/4/accept", http.StatusConflict, ""},
+		{"expired or items are gone", http.MethodPost, "/api/trades/5/accept", http.StatusBadRequest, ""},
+		{"accepted by the proposer", http.MethodPost, "/api/trades/6/accept", http.StatusForbidden, ""},
+		{"unknown trade", http.MethodPost, "/api/trades/7/accept", http.StatusNotFound, ""},
+		{"malformed id", http.MethodPost, "/api/trades/cup/accept", http.StatusBadRequest, ""},
+		{"decline", http.MethodPost, "/api/trades/3/decline", http.StatusOK, domain.TradeStatusDeclined},
+		{"get", http.MethodGet, "/api/trades/3", http.StatusOK, domain.TradeStatusExpired},
+		{"get unknown trade", http.MethodGet, "/api/trades/7", http.StatusNotFound, ""},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.Method, testCase.Url, nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			router.ServeHTTP(wr, req)
+			if wr.Code != testCase.ExpectedStatus {
+				t.Fatalf("got HTTP status code %d, expected %d", wr.Code, testCase.ExpectedStatus)
+			}
+
+			if testCase.ExpectedStatus != http.StatusOK {
+				return
+			}
+
+			var gotTrade domain.Trade
+			err := json.Unmarshal(wr.Body.Bytes(), &gotTrade)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotTrade.Status != testCase.TradeStatus {
+				t.Errorf("got trade status %s, expected %s", gotTrade.Status, testCase.TradeStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type keysFile struct {
+	Keys []domain.SigningKey `json:"keys"`
+}
+
+type KeyStorage struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewKeyStorage(path string) (*KeyStorage, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w (file.NewKeyStorage): empty key file path", customErrors.ErrDataNotValid)
+	}
+
+	return &KeyStorage{
+		path: path,
+	}, nil
+}
+
+func (keyStorage *KeyStorage) GetKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	data, err := keyStorage.read()
+	if err != nil {
+		return nil, fmt.Errorf("(file.GetKeys): %w", err)
+	}
+
+	return data.Keys, nil
+}
+
+func (keyStorage *KeyStorage) AddActiveKey(ctx context.Context, key domain.SigningKey) error {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	data, err := keyStorage.read()
+	if err != nil {
+		return fmt.Errorf("(file.AddActiveKey): %w", err)
+	}
+
+	for i := range data.Keys {
+		if data.Keys[i].Id == key.Id {
+			return fmt.Errorf("%w (file.AddActiveKey): %s", customErrors.ErrAlreadyExists, key.Id)
+		}
+		data.Keys[i].Active = false
+	}
+
+	key.Active = true
+	data.Keys = append(data.Keys, key)
+
+	err = keyStorage.write(data)
+	if err != nil {
+		return fmt.Errorf("(file.AddActiveKey): %w", err)
+	}
+
+	return nil
+}
+
+func (keyStorage *KeyStorage) AddInitialKey(ctx context.Context, key domain.SigningKey) (bool, error) {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	data, err := keyStorage.read()
+	if err != nil {
+		return false, fmt.Errorf("(file.AddInitialKey): %w", err)
+	}
+
+	for i := range data.Keys {
+		if data.Keys[i].Active {
+			return false, nil
+		}
+	}
+
+	key.Active = true
+	data.Keys = append(data.Keys, key)
+
+	err = keyStorage.write(data)
+	if err != nil {
+		return false, fmt.Errorf("(file.AddInitialKey): %w", err)
+	}
+
+	return true, nil
+}
+
+func (keyStorage *KeyStorage) RetireKey(ctx context.Context, kid string) error {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	data, err := keyStorage.read()
+	if err != nil {
+		return fmt.Errorf("(file.RetireKey): %w", err)
+	}
+
+	found := false
+	for i := range data.Keys {
+		if data.Keys[i].Id == kid && !data.Keys[i].Active && !data.Keys[i].Retired {
+			data.Keys[i].Retired = true
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w (file.RetireKey): %s", customErrors.ErrDoesNotExist, kid)
+	}
+
+	err = keyStorage.write(data)
+	if err != nil {
+		return fmt.Errorf("(file.RetireKey): %w", err)
+	}
+
+	return nil
+}
+
+func (keyStorage *KeyStorage) read() (keysFile, error) {
+	var data keysFile
+
+	content, err := os.ReadFile(keyStorage.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return data, nil
+		}
+
+		return data, fmt.Errorf("%w (file.read): %w", customErrors.ErrInternal, err)
+	}
+
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		return data, fmt.Errorf("%w (file.read): %w", customErrors.ErrDataNotValid, err)
+	}
+
+	return data, nil
+}
+
+// write replaces the key file atomically so that concurrent readers
+// never observe a partially written file.
+func (keyStorage *KeyStorage) write(data keysFile) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(keyStorage.path), filepath.Base(keyStorage.path)+".*")
+	if err != nil {
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+
+	err = tmp.Chmod(0o600)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+
+	err = os.Rename(tmp.Name(), keyStorage.path)
+	if err != nil {
+		return fmt.Errorf("%w (file.write): %w", customErrors.ErrInternal, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package file
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestKeyStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_keys.json")
+
+	storage, err := NewKeyStorage(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	keys, err := storage.GetKeys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	oldKey := domain.SigningKey{
+		Id:        "old_kid",
+		Secret:    []byte("old_secret"),
+		CreatedAt: time.Now().UTC(),
+	}
+	newKey := domain.SigningKey{
+		Id:        "new_kid",
+		Secret:    []byte("new_secret"),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	added, err := storage.AddInitialKey(ctx, oldKey)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	added, err = storage.AddInitialKey(ctx, newKey)
+	require.NoError(t, err)
+	require.False(t, added)
+
+	err = storage.AddActiveKey(ctx, newKey)
+	require.NoError(t, err)
+
+	err = storage.AddActiveKey(ctx, newKey)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	err = storage.RetireKey(ctx, newKey.Id)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = storage.RetireKey(ctx, oldKey.Id)
+	require.NoError(t, err)
+
+	reopened, err := NewKeyStorage(path)
+	require.NoError(t, err)
+
+	keys, err = reopened.GetKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, oldKey.Id, keys[0].Id)
+	require.False(t, keys[0].Active)
+	require.True(t, keys[0].Retired)
+	require.Equal(t, newKey.Id, keys[1].Id)
+	require.Equal(t, newKey.Secret, keys[1].Secret)
+	require.True(t, keys[1].Active)
+	require.False(t, keys[1].Retired)
+}
@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type AuthStorage struct {
+	db *DB
+}
+
+func NewAuthStorage(db *DB) (*AuthStorage, error) {
+	return &AuthStorage{
+		db: db,
+	}, nil
+}
+
+func (authStorage *AuthStorage) CreateUser(ctx context.Context, userCreds domain.UserCredantials) error {
+	if len(userCreds.UserName) < 3 || len(userCreds.UserName) >= 150 {
+		return fmt.Errorf("%w (memory.CreateUser): incorrect name length", customErrors.ErrDataNotValid)
+	}
+
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	if _, ok := authStorage.db.users[userCreds.UserName]; ok {
+		return fmt.Errorf("%w (memory.CreateUser)", customErrors.ErrAlreadyExists)
+	}
+
+	authStorage.db.lastUserId++
+	newUser := &user{
+		id:           authStorage.db.lastUserId,
+		name:         userCreds.UserName,
+		password:     userCreds.Password,
+		registeredAt: time.Now(),
+	}
+	authStorage.db.users[newUser.name] = newUser
+	authStorage.db.usersById[newUser.id] = newUser
+
+	err := authStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntrySignup,
+		domain.MintAccount,
+		domain.UserAccount(newUser.id),
+		domain.SignupGrant))
+	if err != nil {
+		return fmt.Errorf("(memory.CreateUser): %w", err)
+	}
+
+	return nil
+}
+
+func (authStorage *AuthStorage) GetPassword(ctx context.Context, name string) (string, error) {
+	authStorage.db.mu.RLock()
+	defer authStorage.db.mu.RUnlock()
+
+	user, ok := authStorage.db.users[name]
+	if !ok {
+		return "", fmt.Errorf("%w (memory.GetPassword): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	return user.password, nil
+}
+
+func (authStorage *AuthStorage) HasUser(ctx context.Context, name string) (bool, error) {
+	authStorage.db.mu.RLock()
+	defer authStorage.db.mu.RUnlock()
+
+	_, ok := authStorage.db.users[name]
+
+	return ok, nil
+}
+
+func (authStorage *AuthStorage) GetUser(ctx context.Context, name string) (domain.User, error) {
+	authStorage.db.mu.RLock()
+	defer authStorage.db.mu.RUnlock()
+
+	user, ok := authStorage.db.users[name]
+	if !ok {
+		return domain.User{}, fmt.Errorf("%w (memory.GetUser): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	return domain.User{
+		Id:    user.id,
+		Name:  user.name,
+		Roles: append([]string{}, user.roles...),
+	}, nil
+}
+
+func (authStorage *AuthStorage) GrantRole(ctx context.Context, name string, role string) error {
+	if err := domain.ValidateRole(role); err != nil {
+		return fmt.Errorf("(memory.GrantRole): %w", err)
+	}
+
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	user, ok := authStorage.db.users[name]
+	if !ok {
+		return fmt.Errorf("%w (memory.GrantRole): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	if !slices.Contains(user.roles, role) {
+		user.roles = append(user.roles, role)
+		slices.Sort(user.roles)
+	}
+
+	return nil
+}
+
+func (authStorage *AuthStorage) RevokeRole(ctx context.Context, name string, role string) error {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	user, ok := authStorage.db.users[name]
+	if !ok {
+		return fmt.Errorf("%w (memory.RevokeRole): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	user.roles = slices.DeleteFunc(user.roles, func(granted string) bool {
+		return granted == role
+	})
+
+	return nil
+}
+
+func (authStorage *AuthStorage) UpdatePassword(ctx context.Context, name string, password string) error {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	user, ok := authStorage.db.users[name]
+	if !ok {
+		return fmt.Errorf("%w (memory.UpdatePassword): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	user.password = password
+
+	return nil
+}
+
+func (authStorage *AuthStorage) RehashPassword(
+	ctx context.Context,
+	name string,
+	oldPassword string,
+	newPassword string) error {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	user, ok := authStorage.db.users[name]
+	if ok && user.password == oldPassword {
+		user.password = newPassword
+	}
+
+	return nil
+}
+
+func (authStorage *AuthStorage) CreatePasswordReset(ctx context.Context, reset domain.PasswordReset) error {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	maps.DeleteFunc(authStorage.db.passwordResets, func(hash string, issued domain.PasswordReset) bool {
+		return issued.UserId == reset.UserId
+	})
+	authStorage.db.passwordResets[reset.Hash] = reset
+
+	return nil
+}
+
+func (authStorage *AuthStorage) GetPasswordReset(ctx context.Context, hash string) (domain.PasswordReset, error) {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	reset, ok := authStorage.db.passwordResets[hash]
+	if !ok || !reset.ExpiresAt.After(time.Now()) {
+		return domain.PasswordReset{}, fmt.Errorf("%w (memory.GetPasswordReset): invalid or expired reset token",
+			customErrors.ErrDataNotValid)
+	}
+
+	return reset, nil
+}
+
+func (authStorage *AuthStorage) ResetPassword(ctx context.Context, hash string, password string) (domain.User, error) {
+	authStorage.db.mu.Lock()
+	defer authStorage.db.mu.Unlock()
+
+	reset, ok := authStorage.db.passwordResets[hash]
+	if !ok || !reset.ExpiresAt.After(time.Now()) {
+		return domain.User{}, fmt.Errorf("%w (memory.ResetPassword): invalid or expired reset token",
+			customErrors.ErrDataNotValid)
+	}
+	delete(authStorage.db.passwordResets, hash)
+
+	user, ok := authStorage.db.usersById[reset.UserId]
+	if !ok {
+		return domain.User{}, fmt.Errorf("%w (memory.ResetPassword): invalid or expired reset token",
+			customErrors.ErrDataNotValid)
+	}
+
+	user.password = password
+
+	return domain.User{Id: user.id, Name: user.name}, nil
+}
@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func (shopStorage *ShopStorage) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	shopStorage.db.mu.RLock()
+	defer shopStorage.db.mu.RUnlock()
+
+	products := make([]domain.Product, 0, len(shopStorage.db.products))
+	for _, product := range shopStorage.db.products {
+		products = append(products, product.toDomain())
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].Name < products[j].Name
+	})
+
+	return products, nil
+}
+
+func (shopStorage *ShopStorage) GetProduct(ctx context.Context, name string) (domain.Product, error) {
+	shopStorage.db.mu.RLock()
+	defer shopStorage.db.mu.RUnlock()
+
+	product, ok := shopStorage.db.products[name]
+	if !ok {
+		return domain.Product{}, fmt.Errorf("%w (memory.GetProduct): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	return product.toDomain(), nil
+}
+
+func (shopStorage *ShopStorage) CreateProduct(
+	ctx context.Context,
+	actor string,
+	newProduct domain.Product) (domain.Product, error) {
+	if err := newProduct.Validate(); err != nil {
+		return domain.Product{}, fmt.Errorf("(memory.CreateProduct): %w", err)
+	}
+
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	if _, ok := shopStorage.db.products[newProduct.Name]; ok {
+		return domain.Product{}, fmt.Errorf("%w (memory.CreateProduct): %s", customErrors.ErrAlreadyExists, newProduct.Name)
+	}
+
+	shopStorage.db.lastProductId++
+	saved := product{
+		id:        shopStorage.db.lastProductId,
+		name:      newProduct.Name,
+		price:     newProduct.Price,
+		available: newProduct.Available,
+	}
+	shopStorage.db.products[saved.name] = saved
+	shopStorage.db.productsById[saved.id] = saved
+
+	shopStorage.auditProductChange(saved.id, domain.ProductChange{
+		Actor:  actor,
+		Action: domain.ProductActionCreate,
+		After:  newProduct,
+	})
+
+	return newProduct, nil
+}
+
+func (shopStorage *ShopStorage) UpdateProduct(
+	ctx context.Context,
+	name string,
+	update domain.ProductUpdate) (domain.Product, error) {
+	if err := update.Validate(); err != nil {
+		return domain.Product{}, fmt.Errorf("(memory.UpdateProduct): %w", err)
+	}
+
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	saved, ok := shopStorage.db.products[name]
+	if !ok {
+		return domain.Product{}, fmt.Errorf("%w (memory.UpdateProduct): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	before := saved.toDomain()
+	after := update.Apply(before)
+
+	if _, ok := shopStorage.db.products[after.Name]; ok && after.Name != name {
+		return domain.Product{}, fmt.Errorf("%w (memory.UpdateProduct): %s", customErrors.ErrAlreadyExists, after.Name)
+	}
+
+	delete(shopStorage.db.products, name)
+	saved.name = after.Name
+	saved.price = after.Price
+	saved.available = after.Available
+	shopStorage.db.products[saved.name] = saved
+	shopStorage.db.productsById[saved.id] = saved
+
+	shopStorage.auditProductChange(saved.id, domain.ProductChange{
+		Actor:  update.Actor,
+		Action: update.Action,
+		Before: &before,
+		After:  after,
+	})
+
+	return after, nil
+}
+
+func (shopStorage *ShopStorage) GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error) {
+	shopStorage.db.mu.RLock()
+	defer shopStorage.db.mu.RUnlock()
+
+	saved, ok := shopStorage.db.products[name]
+	if !ok {
+		return nil, fmt.Errorf("%w (memory.GetProductAudit): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	changes := make([]domain.ProductChange, 0)
+	for i := len(shopStorage.db.productChanges) - 1; i >= 0; i-- {
+		if shopStorage.db.productChanges[i].productId == saved.id {
+			changes = append(changes, shopStorage.db.productChanges[i].change)
+		}
+	}
+
+	return changes, nil
+}
+
+// auditProductChange records the change, the caller must hold the write lock.
+func (shopStorage *ShopStorage) auditProductChange(productId int, change domain.ProductChange) {
+	change.ChangedAt = time.Now().UTC()
+	shopStorage.db.productChanges = append(shopStorage.db.productChanges, productChange{
+		productId: productId,
+		change:    change,
+	})
+}
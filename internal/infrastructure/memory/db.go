@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type user struct {
+	id           int
+	name         string
+	password     string
+	money        int
+	roles        []string
+	registeredAt time.Time
+}
+
+type product struct {
+	id        int
+	name      string
+	price     int
+	available bool
+}
+
+func (p product) toDomain() domain.Product {
+	return domain.Product{
+		Name:      p.name,
+		Price:     p.price,
+		Available: p.available,
+	}
+}
+
+type purchase struct {
+	id         int64
+	userId     int
+	productId  int
+	price      int
+	boughtAt   time.Time
+	refundedAt time.Time
+	// giftFrom is the user who paid for a gift, zero for own purchases
+	giftFrom int
+	// boughtFor is the user the item was bought or gifted for,
+	// set once the item changes hands in a trade
+	boughtFor int
+}
+
+func (p purchase) firstOwner() int {
+	if p.boughtFor != 0 {
+		return p.boughtFor
+	}
+
+	return p.userId
+}
+
+func (p purchase) paidBy() int {
+	if p.giftFrom != 0 {
+		return p.giftFrom
+	}
+
+	return p.firstOwner()
+}
+
+type transfer struct {
+	id       int64
+	userFrom int
+	userTo   int
+	money    int
+	sentAt   time.Time
+	// tradeId is the trade the transfer is one side of, zero for plain transfers
+	tradeId int64
+}
+
+func (t transfer) domainTradeId() *int64 {
+	if t.tradeId == 0 {
+		return nil
+	}
+
+	tradeId := t.tradeId
+	return &tradeId
+}
+
+type tradeItem struct {
+	productId int
+	quantity  int
+}
+
+type tradeSide struct {
+	items []tradeItem
+	coins int
+}
+
+type trade struct {
+	id         int64
+	userFrom   int
+	userTo     int
+	offered    tradeSide
+	requested  tradeSide
+	status     string
+	createdAt  time.Time
+	expiresAt  time.Time
+	resolvedAt time.Time
+}
+
+type productChange struct {
+	productId int
+	change    domain.ProductChange
+}
+
+type ledgerEntry struct {
+	id        int64
+	kind      string
+	postings  []domain.LedgerPosting
+	createdAt time.Time
+}
+
+type idempotencyKeyId struct {
+	userId int
+	key    string
+}
+
+// DB keeps the state shared by the in-memory storages, the same way
+// the postgres storages share one database.
+type DB struct {
+	mu sync.RWMutex
+
+	users           map[string]*user
+	usersById       map[int]*user
+	products        map[string]product
+	productsById    map[int]product
+	productChanges  []productChange
+	purchases       []purchase
+	transfers       []transfer
+	trades          []trade
+	ledger          []ledgerEntry
+	idempotencyKeys map[idempotencyKeyId]domain.IdempotencyKey
+	passwordResets  map[string]domain.PasswordReset
+
+	lastUserId        int
+	lastProductId     int
+	lastTransferId    int64
+	lastPurchaseId    int64
+	lastTradeId       int64
+	lastLedgerEntryId int64
+}
+
+func NewDB() *DB {
+	db := &DB{
+		users:           make(map[string]*user),
+		usersById:       make(map[int]*user),
+		products:        make(map[string]product),
+		productsById:    make(map[int]product),
+		productChanges:  make([]productChange, 0),
+		purchases:       make([]purchase, 0),
+		transfers:       make([]transfer, 0),
+		trades:          make([]trade, 0),
+		ledger:          make([]ledgerEntry, 0),
+		idempotencyKeys: make(map[idempotencyKeyId]domain.IdempotencyKey),
+		passwordResets:  make(map[string]domain.PasswordReset),
+	}
+
+	catalog := []struct {
+		name  string
+		price int
+	}{
+		{"t-shirt", 80},
+		{"cup", 20},
+		{"book", 50},
+		{"pen", 10},
+		{"powerbank", 200},
+		{"hoody", 300},
+		{"umbrella", 200},
+		{"socks", 10},
+		{"wallet", 50},
+		{"pink-hoody", 500},
+	}
+	for i, item := range catalog {
+		newProduct := product{
+			id:        i + 1,
+			name:      item.name,
+			price:     item.price,
+			available: true,
+		}
+		db.products[newProduct.name] = newProduct
+		db.productsById[newProduct.id] = newProduct
+	}
+	db.lastProductId = len(catalog)
+
+	return db
+}
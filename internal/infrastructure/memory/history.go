@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func (shopStorage *ShopStorage) GetHistory(
+	ctx context.Context,
+	username string,
+	filter domain.HistoryFilter) (domain.HistoryPage, error) {
+	shopStorage.db.mu.RLock()
+	defer shopStorage.db.mu.RUnlock()
+
+	user, ok := shopStorage.db.users[username]
+	if !ok {
+		return domain.HistoryPage{}, fmt.Errorf("%w (memory.GetHistory): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	entries := make([]domain.HistoryEntry, 0)
+	for _, transfer := range shopStorage.db.transfers {
+		entry := domain.HistoryEntry{
+			Id:      transfer.id,
+			Amount:  transfer.money,
+			SentAt:  transfer.sentAt,
+			TradeId: transfer.domainTradeId(),
+		}
+
+		// transfers to oneself are listed once, as sent
+		switch {
+		case transfer.userFrom == user.id && filter.Direction != domain.HistoryDirectionReceived:
+			entry.Direction = domain.HistoryDirectionSent
+			entry.Counterparty = shopStorage.db.usersById[transfer.userTo].name
+		case transfer.userTo == user.id && filter.Direction != domain.HistoryDirectionSent &&
+			(transfer.userFrom != user.id || filter.Direction == domain.HistoryDirectionReceived):
+			entry.Direction = domain.HistoryDirectionReceived
+			entry.Counterparty = shopStorage.db.usersById[transfer.userFrom].name
+		default:
+			continue
+		}
+
+		if matchesHistoryFilter(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].SentAt.Equal(entries[j].SentAt) {
+			return entries[i].SentAt.After(entries[j].SentAt)
+		}
+		return entries[i].Id > entries[j].Id
+	})
+
+	page := domain.HistoryPage{
+		Entries: entries,
+	}
+	if len(page.Entries) > filter.Limit {
+		page.Entries = page.Entries[:filter.Limit]
+		last := page.Entries[len(page.Entries)-1]
+		page.NextCursor = domain.HistoryCursor{SentAt: last.SentAt, Id: last.Id}.Encode()
+	}
+
+	return page, nil
+}
+
+func matchesHistoryFilter(entry domain.HistoryEntry, filter domain.HistoryFilter) bool {
+	switch {
+	case filter.Counterparty != "" && entry.Counterparty != filter.Counterparty,
+		filter.MinAmount != nil && entry.Amount < *filter.MinAmount,
+		filter.MaxAmount != nil && entry.Amount > *filter.MaxAmount,
+		!filter.From.IsZero() && entry.SentAt.Before(filter.From),
+		!filter.To.IsZero() && !entry.SentAt.Before(filter.To):
+		return false
+	}
+
+	if filter.Cursor != nil {
+		if entry.SentAt.After(filter.Cursor.SentAt) {
+			return false
+		}
+		if entry.SentAt.Equal(filter.Cursor.SentAt) && entry.Id >= filter.Cursor.Id {
+			return false
+		}
+	}
+
+	return true
+}
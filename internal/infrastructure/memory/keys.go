@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// KeyStorage keeps signing keys for the lifetime of the process only,
+// so tokens do not survive a restart.
+type KeyStorage struct {
+	mu   sync.Mutex
+	keys []domain.SigningKey
+}
+
+func NewKeyStorage() (*KeyStorage, error) {
+	return &KeyStorage{
+		keys: make([]domain.SigningKey, 0),
+	}, nil
+}
+
+func (keyStorage *KeyStorage) GetKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	return append([]domain.SigningKey(nil), keyStorage.keys...), nil
+}
+
+func (keyStorage *KeyStorage) AddActiveKey(ctx context.Context, key domain.SigningKey) error {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	for i := range keyStorage.keys {
+		if keyStorage.keys[i].Id == key.Id {
+			return fmt.Errorf("%w (memory.AddActiveKey): %s", customErrors.ErrAlreadyExists, key.Id)
+		}
+	}
+	for i := range keyStorage.keys {
+		keyStorage.keys[i].Active = false
+	}
+
+	key.Active = true
+	keyStorage.keys = append(keyStorage.keys, key)
+
+	return nil
+}
+
+func (keyStorage *KeyStorage) AddInitialKey(ctx context.Context, key domain.SigningKey) (bool, error) {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	for i := range keyStorage.keys {
+		if keyStorage.keys[i].Active {
+			return false, nil
+		}
+	}
+
+	key.Active = true
+	keyStorage.keys = append(keyStorage.keys, key)
+
+	return true, nil
+}
+
+func (keyStorage *KeyStorage) RetireKey(ctx context.Context, kid string) error {
+	keyStorage.mu.Lock()
+	defer keyStorage.mu.Unlock()
+
+	for i := range keyStorage.keys {
+		if keyStorage.keys[i].Id == kid && !keyStorage.keys[i].Active && !keyStorage.keys[i].Retired {
+			keyStorage.keys[i].Retired = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w (memory.RetireKey): %s", customErrors.ErrDoesNotExist, kid)
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type LedgerStorage struct {
+	db *DB
+}
+
+func NewLedgerStorage(db *DB) (*LedgerStorage, error) {
+	return &LedgerStorage{
+		db: db,
+	}, nil
+}
+
+func (ledgerStorage *LedgerStorage) GetBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	ledgerStorage.db.mu.RLock()
+	defer ledgerStorage.db.mu.RUnlock()
+
+	sums := make(map[domain.LedgerAccount]int)
+	for _, entry := range ledgerStorage.db.ledger {
+		for _, posting := range entry.postings {
+			sums[posting.Account] += posting.Amount
+		}
+	}
+
+	balances := []domain.AccountBalance{
+		{Account: domain.MintAccount, Balance: sums[domain.MintAccount]},
+		{Account: domain.RevenueAccount, Balance: sums[domain.RevenueAccount]},
+	}
+
+	userIds := make([]int, 0, len(ledgerStorage.db.usersById))
+	for id := range ledgerStorage.db.usersById {
+		userIds = append(userIds, id)
+	}
+	sort.Ints(userIds)
+
+	for _, id := range userIds {
+		user := ledgerStorage.db.usersById[id]
+		money := user.money
+
+		account := domain.UserAccount(id)
+		balances = append(balances, domain.AccountBalance{
+			Account:  account,
+			UserName: user.name,
+			Balance:  sums[account],
+			Cached:   &money,
+		})
+	}
+
+	return balances, nil
+}
+
+func (ledgerStorage *LedgerStorage) GetActivity(ctx context.Context) ([]domain.AccountActivity, error) {
+	ledgerStorage.db.mu.RLock()
+	defer ledgerStorage.db.mu.RUnlock()
+
+	activity := make(map[int]*domain.AccountActivity, len(ledgerStorage.db.usersById))
+	for id, user := range ledgerStorage.db.usersById {
+		activity[id] = &domain.AccountActivity{
+			UserId:    id,
+			UserName:  user.name,
+			Cached:    user.money,
+			Breakdown: domain.BalanceBreakdown{Grant: domain.SignupGrant},
+		}
+	}
+
+	for _, entry := range ledgerStorage.db.ledger {
+		for _, posting := range entry.postings {
+			if account, ok := activity[posting.Account.UserId]; ok && posting.Account.Kind == domain.LedgerAccountUser {
+				account.Ledger += posting.Amount
+			}
+		}
+	}
+
+	for _, transfer := range ledgerStorage.db.transfers {
+		if account, ok := activity[transfer.userTo]; ok {
+			account.Breakdown.Received += transfer.money
+		}
+		if account, ok := activity[transfer.userFrom]; ok {
+			account.Breakdown.Sent += transfer.money
+		}
+	}
+
+	for _, purchase := range ledgerStorage.db.purchases {
+		account, ok := activity[purchase.paidBy()]
+		if !ok || !purchase.refundedAt.IsZero() {
+			continue
+		}
+
+		account.Breakdown.Purchases += purchase.price
+	}
+
+	result := make([]domain.AccountActivity, 0, len(activity))
+	for _, account := range activity {
+		result = append(result, *account)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UserId < result[j].UserId
+	})
+
+	return result, nil
+}
+
+// postLedgerEntry records the entry and applies its postings to the cached
+// balances of the users. The caller must hold the write lock.
+func (db *DB) postLedgerEntry(entry domain.LedgerEntry) error {
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("(memory.postLedgerEntry): %w", err)
+	}
+
+	changes := make(map[*user]int)
+	for _, posting := range entry.Postings {
+		if posting.Account.Kind != domain.LedgerAccountUser {
+			continue
+		}
+
+		user, ok := db.usersById[posting.Account.UserId]
+		if !ok {
+			return fmt.Errorf("%w (memory.postLedgerEntry): ledger account %v",
+				customErrors.ErrDoesNotExist, posting.Account)
+		}
+
+		changes[user] += posting.Amount
+	}
+
+	for user, change := range changes {
+		if user.money+change < 0 {
+			return fmt.Errorf("%w (memory.postLedgerEntry)", customErrors.ErrInsufficientFunds)
+		}
+	}
+
+	for user, change := range changes {
+		user.money += change
+	}
+
+	db.lastLedgerEntryId++
+	db.ledger = append(db.ledger, ledgerEntry{
+		id:        db.lastLedgerEntryId,
+		kind:      entry.Kind,
+		postings:  append([]domain.LedgerPosting(nil), entry.Postings...),
+		createdAt: time.Now(),
+	})
+
+	return nil
+}
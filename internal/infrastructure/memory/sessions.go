@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// SessionStorage keeps revoked sessions for the lifetime of the process only,
+// the same as KeyStorage keeps the keys the tokens are signed with.
+type SessionStorage struct {
+	mu            sync.Mutex
+	revocations   domain.Revocations
+	refreshTokens map[string]*refreshToken
+}
+
+type refreshToken struct {
+	domain.RefreshToken
+	rotated bool
+	revoked bool
+}
+
+func NewSessionStorage() (*SessionStorage, error) {
+	return &SessionStorage{
+		revocations: domain.Revocations{
+			Tokens:   make(map[string]time.Time),
+			Cutoffs:  make(map[int]time.Time),
+			Sessions: make(map[string]time.Time),
+		},
+		refreshTokens: make(map[string]*refreshToken),
+	}, nil
+}
+
+func (sessionStorage *SessionStorage) RevokeToken(ctx context.Context, token domain.RevokedToken) error {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	sessionStorage.revocations.Tokens[token.Id] = token.ExpiresAt
+
+	return nil
+}
+
+func (sessionStorage *SessionStorage) RevokeUserTokens(ctx context.Context, userId int, before time.Time) error {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	if cutoff, ok := sessionStorage.revocations.Cutoffs[userId]; !ok || cutoff.Before(before) {
+		sessionStorage.revocations.Cutoffs[userId] = before
+	}
+
+	for _, token := range sessionStorage.refreshTokens {
+		if token.UserId == userId {
+			token.revoked = true
+		}
+	}
+
+	return nil
+}
+
+func (sessionStorage *SessionStorage) GetRevocations(ctx context.Context) (domain.Revocations, error) {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	now := time.Now()
+	revocations := domain.Revocations{
+		Tokens:   make(map[string]time.Time, len(sessionStorage.revocations.Tokens)),
+		Cutoffs:  maps.Clone(sessionStorage.revocations.Cutoffs),
+		Sessions: maps.Clone(sessionStorage.revocations.Sessions),
+	}
+	for tokenId, expiresAt := range sessionStorage.revocations.Tokens {
+		if expiresAt.After(now) {
+			revocations.Tokens[tokenId] = expiresAt
+		}
+	}
+
+	return revocations, nil
+}
+
+func (sessionStorage *SessionStorage) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	sessionStorage.refreshTokens[token.Hash] = &refreshToken{RefreshToken: token}
+
+	return nil
+}
+
+func (sessionStorage *SessionStorage) RotateRefreshToken(
+	ctx context.Context,
+	hash string,
+	next domain.RefreshToken) (domain.RefreshToken, error) {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	token, ok := sessionStorage.refreshTokens[hash]
+	if !ok {
+		return domain.RefreshToken{}, fmt.Errorf("%w (memory.RotateRefreshToken): unknown refresh token",
+			customErrors.ErrUnauthenticated)
+	}
+
+	if token.revoked || !token.ExpiresAt.After(time.Now()) {
+		return domain.RefreshToken{}, fmt.Errorf("%w (memory.RotateRefreshToken): refresh token expired or revoked",
+			customErrors.ErrUnauthenticated)
+	}
+
+	if token.rotated {
+		sessionStorage.revokeRefreshFamily(token.FamilyId)
+		// the access tokens already issued to the session are revoked too
+		sessionStorage.revocations.Sessions[token.FamilyId] = time.Now()
+
+		return domain.RefreshToken{}, fmt.Errorf("%w (memory.RotateRefreshToken): %w",
+			customErrors.ErrUnauthenticated, customErrors.ErrRefreshTokenReused)
+	}
+
+	token.rotated = true
+
+	next.FamilyId = token.FamilyId
+	next.UserId = token.UserId
+	next.UserName = token.UserName
+	sessionStorage.refreshTokens[next.Hash] = &refreshToken{RefreshToken: next}
+
+	return next, nil
+}
+
+func (sessionStorage *SessionStorage) RevokeRefreshFamily(ctx context.Context, familyId string) error {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	sessionStorage.revokeRefreshFamily(familyId)
+
+	return nil
+}
+
+func (sessionStorage *SessionStorage) revokeRefreshFamily(familyId string) {
+	for _, token := range sessionStorage.refreshTokens {
+		if token.FamilyId == familyId {
+			token.revoked = true
+		}
+	}
+}
+
+func (sessionStorage *SessionStorage) DeleteExpiredTokens(ctx context.Context, cutoffsBefore time.Time) error {
+	sessionStorage.mu.Lock()
+	defer sessionStorage.mu.Unlock()
+
+	now := time.Now()
+	maps.DeleteFunc(sessionStorage.revocations.Tokens, func(tokenId string, expiresAt time.Time) bool {
+		return !expiresAt.After(now)
+	})
+	maps.DeleteFunc(sessionStorage.revocations.Cutoffs, func(userId int, cutoff time.Time) bool {
+		return cutoff.Before(cutoffsBefore)
+	})
+	maps.DeleteFunc(sessionStorage.revocations.Sessions, func(sessionId string, revokedAt time.Time) bool {
+		return revokedAt.Before(cutoffsBefore)
+	})
+	maps.DeleteFunc(sessionStorage.refreshTokens, func(hash string, token *refreshToken) bool {
+		return !token.ExpiresAt.After(now)
+	})
+
+	return nil
+}
@@ -0,0 +1,521 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type ShopStorage struct {
+	db *DB
+}
+
+func NewShopStorage(db *DB) (*ShopStorage, error) {
+	return &ShopStorage{
+		db: db,
+	}, nil
+}
+
+func (shopStorage *ShopStorage) GetInfo(ctx context.Context, username string) (domain.InventoryInfo, error) {
+	shopStorage.db.mu.RLock()
+	defer shopStorage.db.mu.RUnlock()
+
+	user, ok := shopStorage.db.users[username]
+	if !ok {
+		return domain.InventoryInfo{}, fmt.Errorf("%w (memory.GetInfo): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	return domain.InventoryInfo{
+		Coins:     user.money,
+		Inventory: shopStorage.getInventory(user.id),
+		CoinHistory: domain.SentRecievedHistory{
+			Recieved: shopStorage.getRecievedCoins(user.id),
+			Sent:     shopStorage.getSentCoins(user.id),
+		},
+		GiftHistory: domain.SentRecievedGifts{
+			Recieved: shopStorage.getRecievedGifts(user.id),
+			Sent:     shopStorage.getSentGifts(user.id),
+		},
+	}, nil
+}
+
+func (shopStorage *ShopStorage) SendCoin(
+	ctx context.Context,
+	transaction domain.Transaction,
+	idempotencyKey domain.IdempotencyKey) error {
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	fromUser, ok := shopStorage.db.users[transaction.From]
+	if !ok {
+		return fmt.Errorf("%w (memory.SendCoin): %s", customErrors.ErrDoesNotExist, transaction.From)
+	}
+
+	toUser, ok := shopStorage.db.users[transaction.To]
+	if !ok {
+		return fmt.Errorf("%w (memory.SendCoin): %s", customErrors.ErrDoesNotExist, transaction.To)
+	}
+
+	if transaction.Amount < 0 {
+		return fmt.Errorf("%w (memory.SendCoin): incorrect amount of coins", customErrors.ErrDataNotValid)
+	}
+
+	replay, err := shopStorage.checkIdempotencyKey(fromUser.id, idempotencyKey)
+	if err != nil {
+		return fmt.Errorf("(memory.SendCoin): %w", err)
+	}
+	if replay {
+		return nil
+	}
+
+	err = shopStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntryTransfer,
+		domain.UserAccount(fromUser.id),
+		domain.UserAccount(toUser.id),
+		transaction.Amount))
+	if err != nil {
+		return fmt.Errorf("(memory.SendCoin): %w", err)
+	}
+
+	shopStorage.db.lastTransferId++
+	shopStorage.db.transfers = append(shopStorage.db.transfers, transfer{
+		id:       shopStorage.db.lastTransferId,
+		userFrom: fromUser.id,
+		userTo:   toUser.id,
+		money:    transaction.Amount,
+		// the same precision as postgres timestamps, so that history cursors match exactly
+		sentAt: time.Now().UTC().Truncate(time.Microsecond),
+	})
+	shopStorage.saveIdempotencyKey(fromUser.id, idempotencyKey)
+
+	return nil
+}
+
+func (shopStorage *ShopStorage) BuyItem(
+	ctx context.Context,
+	username string,
+	itemName string,
+	idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	user, ok := shopStorage.db.users[username]
+	if !ok {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (memory.BuyItem): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	replay, err := shopStorage.checkIdempotencyKey(user.id, idempotencyKey)
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(memory.BuyItem): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.PurchaseReceipt
+		err = shopStorage.getIdempotentResponse(user.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.PurchaseReceipt{}, fmt.Errorf("(memory.BuyItem): %w", err)
+		}
+
+		return savedReceipt, nil
+	}
+
+	item, ok := shopStorage.db.products[itemName]
+	if !ok {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (memory.BuyItem): %s", customErrors.ErrDoesNotExist, itemName)
+	}
+	if !item.available {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (memory.BuyItem): %s", customErrors.ErrNotAvailable, itemName)
+	}
+
+	err = shopStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(user.id),
+		domain.RevenueAccount,
+		item.price))
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(memory.BuyItem): %w", err)
+	}
+
+	bought := shopStorage.addPurchase(user.id, item.id, item.price, time.Now())
+	receipt := domain.PurchaseReceipt{
+		Id:       bought.id,
+		Item:     itemName,
+		Price:    item.price,
+		BoughtAt: bought.boughtAt,
+		Balance:  user.money,
+	}
+
+	idempotencyKey.Response, err = json.Marshal(receipt)
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(memory.BuyItem): %w", err)
+	}
+	shopStorage.saveIdempotencyKey(user.id, idempotencyKey)
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) BuyCart(
+	ctx context.Context,
+	username string,
+	cart domain.Cart,
+	idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	user, ok := shopStorage.db.users[username]
+	if !ok {
+		return domain.CartReceipt{}, fmt.Errorf("%w (memory.BuyCart): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	replay, err := shopStorage.checkIdempotencyKey(user.id, idempotencyKey)
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(memory.BuyCart): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.CartReceipt
+		err = shopStorage.getIdempotentResponse(user.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.CartReceipt{}, fmt.Errorf("(memory.BuyCart): %w", err)
+		}
+
+		return savedReceipt, nil
+	}
+
+	receipt := domain.CartReceipt{
+		Lines: make([]domain.CartReceiptLine, 0, len(cart.Lines)),
+	}
+	for _, line := range cart.Lines {
+		item, ok := shopStorage.db.products[line.Item]
+		if !ok {
+			return domain.CartReceipt{}, fmt.Errorf("%w (memory.BuyCart): %s", customErrors.ErrDoesNotExist, line.Item)
+		}
+		if !item.available {
+			return domain.CartReceipt{}, fmt.Errorf("%w (memory.BuyCart): %s", customErrors.ErrNotAvailable, line.Item)
+		}
+
+		receipt.Lines = append(receipt.Lines, domain.CartReceiptLine{
+			Item:     line.Item,
+			Quantity: line.Quantity,
+			Price:    item.price,
+			Cost:     item.price * line.Quantity,
+		})
+		receipt.Total += item.price * line.Quantity
+	}
+
+	if user.money-receipt.Total < 0 {
+		return domain.CartReceipt{}, fmt.Errorf("%w (memory.BuyCart)", customErrors.ErrInsufficientFunds)
+	}
+	receipt.Balance = user.money - receipt.Total
+
+	idempotencyKey.Response, err = json.Marshal(receipt)
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(memory.BuyCart): %w", err)
+	}
+
+	err = shopStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(user.id),
+		domain.RevenueAccount,
+		receipt.Total))
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(memory.BuyCart): %w", err)
+	}
+
+	now := time.Now()
+	for _, line := range cart.Lines {
+		for i := 0; i < line.Quantity; i++ {
+			item := shopStorage.db.products[line.Item]
+			shopStorage.addPurchase(user.id, item.id, item.price, now)
+		}
+	}
+	shopStorage.saveIdempotencyKey(user.id, idempotencyKey)
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) GiftItem(
+	ctx context.Context,
+	gift domain.Gift,
+	idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	fromUser, ok := shopStorage.db.users[gift.From]
+	if !ok {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (memory.GiftItem): %s", customErrors.ErrDoesNotExist, gift.From)
+	}
+
+	toUser, ok := shopStorage.db.users[gift.To]
+	if !ok {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (memory.GiftItem): %s", customErrors.ErrDoesNotExist, gift.To)
+	}
+
+	replay, err := shopStorage.checkIdempotencyKey(fromUser.id, idempotencyKey)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(memory.GiftItem): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.GiftReceipt
+		err = shopStorage.getIdempotentResponse(fromUser.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.GiftReceipt{}, fmt.Errorf("(memory.GiftItem): %w", err)
+		}
+
+		return savedReceipt, nil
+	}
+
+	item, ok := shopStorage.db.products[gift.Item]
+	if !ok {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (memory.GiftItem): %s", customErrors.ErrDoesNotExist, gift.Item)
+	}
+	if !item.available {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (memory.GiftItem): %s", customErrors.ErrNotAvailable, gift.Item)
+	}
+
+	err = shopStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(fromUser.id),
+		domain.RevenueAccount,
+		item.price))
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(memory.GiftItem): %w", err)
+	}
+
+	bought := shopStorage.addPurchase(toUser.id, item.id, item.price, time.Now())
+	shopStorage.db.purchases[len(shopStorage.db.purchases)-1].giftFrom = fromUser.id
+	receipt := domain.GiftReceipt{
+		Id:      bought.id,
+		To:      gift.To,
+		Item:    gift.Item,
+		Price:   item.price,
+		SentAt:  bought.boughtAt,
+		Balance: fromUser.money,
+	}
+
+	idempotencyKey.Response, err = json.Marshal(receipt)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(memory.GiftItem): %w", err)
+	}
+	shopStorage.saveIdempotencyKey(fromUser.id, idempotencyKey)
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) RefundPurchase(
+	ctx context.Context,
+	username string,
+	purchaseId int64,
+	boughtAfter time.Time) (domain.RefundReceipt, error) {
+	shopStorage.db.mu.Lock()
+	defer shopStorage.db.mu.Unlock()
+
+	user, ok := shopStorage.db.users[username]
+	if !ok {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (memory.RefundPurchase): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	position := -1
+	for i, bought := range shopStorage.db.purchases {
+		if bought.id == purchaseId && bought.userId == user.id {
+			position = i
+			break
+		}
+	}
+	if position < 0 {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (memory.RefundPurchase): purchase %d",
+			customErrors.ErrDoesNotExist, purchaseId)
+	}
+
+	bought := &shopStorage.db.purchases[position]
+	if !bought.refundedAt.IsZero() {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (memory.RefundPurchase): purchase %d is already refunded",
+			customErrors.ErrAlreadyExists, purchaseId)
+	}
+	// gifts and traded items have not been paid for by their owner
+	if bought.giftFrom != 0 || bought.boughtFor != 0 || bought.boughtAt.Before(boughtAfter) {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (memory.RefundPurchase): purchase %d can not be refunded",
+			customErrors.ErrNotAvailable, purchaseId)
+	}
+
+	err := shopStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+		domain.LedgerEntryRefund,
+		domain.RevenueAccount,
+		domain.UserAccount(user.id),
+		bought.price))
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("(memory.RefundPurchase): %w", err)
+	}
+
+	bought.refundedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return domain.RefundReceipt{
+		PurchaseId: purchaseId,
+		Item:       shopStorage.db.productsById[bought.productId].name,
+		Amount:     bought.price,
+		RefundedAt: bought.refundedAt,
+		Balance:    user.money,
+	}, nil
+}
+
+func (shopStorage *ShopStorage) addPurchase(userId int, productId int, price int, boughtAt time.Time) purchase {
+	shopStorage.db.lastPurchaseId++
+	bought := purchase{
+		id:        shopStorage.db.lastPurchaseId,
+		userId:    userId,
+		productId: productId,
+		price:     price,
+		// the same precision as postgres timestamps
+		boughtAt: boughtAt.UTC().Truncate(time.Microsecond),
+	}
+	shopStorage.db.purchases = append(shopStorage.db.purchases, bought)
+
+	return bought
+}
+
+// checkIdempotencyKey reports true if the same request has already been processed.
+// The key itself is saved by saveIdempotencyKey only after the operation succeeds.
+func (shopStorage *ShopStorage) checkIdempotencyKey(userId int, idempotencyKey domain.IdempotencyKey) (bool, error) {
+	if idempotencyKey.Key == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+	for id, key := range shopStorage.db.idempotencyKeys {
+		if id.userId == userId && key.ExpiresAt.Before(now) {
+			delete(shopStorage.db.idempotencyKeys, id)
+		}
+	}
+
+	savedKey, ok := shopStorage.db.idempotencyKeys[idempotencyKeyId{userId: userId, key: idempotencyKey.Key}]
+	if !ok {
+		return false, nil
+	}
+
+	if savedKey.RequestHash != idempotencyKey.RequestHash {
+		return false, fmt.Errorf("%w (memory.checkIdempotencyKey): %s",
+			customErrors.ErrIdempotencyKeyReused, idempotencyKey.Key)
+	}
+
+	return true, nil
+}
+
+func (shopStorage *ShopStorage) saveIdempotencyKey(userId int, idempotencyKey domain.IdempotencyKey) {
+	if idempotencyKey.Key == "" {
+		return
+	}
+
+	shopStorage.db.idempotencyKeys[idempotencyKeyId{userId: userId, key: idempotencyKey.Key}] = idempotencyKey
+}
+
+// getIdempotentResponse reads the result saved along with the key of a replayed request.
+func (shopStorage *ShopStorage) getIdempotentResponse(
+	userId int,
+	idempotencyKey domain.IdempotencyKey,
+	response any) error {
+	savedKey := shopStorage.db.idempotencyKeys[idempotencyKeyId{userId: userId, key: idempotencyKey.Key}]
+
+	err := json.Unmarshal(savedKey.Response, response)
+	if err != nil {
+		return fmt.Errorf("(memory.getIdempotentResponse): %w", err)
+	}
+
+	return nil
+}
+
+// getInventory groups purchases by product, the most recently bought first.
+func (shopStorage *ShopStorage) getInventory(userId int) []domain.Item {
+	inventory := make([]domain.Item, 0)
+	positions := make(map[int]int)
+	for i := len(shopStorage.db.purchases) - 1; i >= 0; i-- {
+		purchase := shopStorage.db.purchases[i]
+		if purchase.userId != userId || !purchase.refundedAt.IsZero() {
+			continue
+		}
+
+		position, ok := positions[purchase.productId]
+		if !ok {
+			position = len(inventory)
+			positions[purchase.productId] = position
+			inventory = append(inventory, domain.Item{
+				Type: shopStorage.db.productsById[purchase.productId].name,
+			})
+		}
+
+		inventory[position].Quantity++
+	}
+
+	return inventory
+}
+
+func (shopStorage *ShopStorage) getRecievedCoins(userId int) []domain.RecievedCoins {
+	recievedCoins := make([]domain.RecievedCoins, 0)
+	for i := len(shopStorage.db.transfers) - 1; i >= 0 && len(recievedCoins) < domain.InfoHistoryLimit; i-- {
+		transfer := shopStorage.db.transfers[i]
+		if transfer.userTo != userId {
+			continue
+		}
+
+		recievedCoins = append(recievedCoins, domain.RecievedCoins{
+			From:    shopStorage.db.usersById[transfer.userFrom].name,
+			Amount:  transfer.money,
+			TradeId: transfer.domainTradeId(),
+		})
+	}
+
+	return recievedCoins
+}
+
+func (shopStorage *ShopStorage) getSentCoins(userId int) []domain.SentCoins {
+	sentCoins := make([]domain.SentCoins, 0)
+	for i := len(shopStorage.db.transfers) - 1; i >= 0 && len(sentCoins) < domain.InfoHistoryLimit; i-- {
+		transfer := shopStorage.db.transfers[i]
+		if transfer.userFrom != userId {
+			continue
+		}
+
+		sentCoins = append(sentCoins, domain.SentCoins{
+			To:      shopStorage.db.usersById[transfer.userTo].name,
+			Amount:  transfer.money,
+			TradeId: transfer.domainTradeId(),
+		})
+	}
+
+	return sentCoins
+}
+
+func (shopStorage *ShopStorage) getRecievedGifts(userId int) []domain.RecievedGift {
+	recievedGifts := make([]domain.RecievedGift, 0)
+	for i := len(shopStorage.db.purchases) - 1; i >= 0 && len(recievedGifts) < domain.InfoHistoryLimit; i-- {
+		purchase := shopStorage.db.purchases[i]
+		if purchase.giftFrom == 0 || purchase.firstOwner() != userId {
+			continue
+		}
+
+		recievedGifts = append(recievedGifts, domain.RecievedGift{
+			From: shopStorage.db.usersById[purchase.giftFrom].name,
+			Item: shopStorage.db.productsById[purchase.productId].name,
+		})
+	}
+
+	return recievedGifts
+}
+
+func (shopStorage *ShopStorage) getSentGifts(userId int) []domain.SentGift {
+	sentGifts := make([]domain.SentGift, 0)
+	for i := len(shopStorage.db.purchases) - 1; i >= 0 && len(sentGifts) < domain.InfoHistoryLimit; i-- {
+		purchase := shopStorage.db.purchases[i]
+		if purchase.giftFrom != userId {
+			continue
+		}
+
+		sentGifts = append(sentGifts, domain.SentGift{
+			To:   shopStorage.db.usersById[purchase.firstOwner()].name,
+			Item: shopStorage.db.productsById[purchase.productId].name,
+		})
+	}
+
+	return sentGifts
+}
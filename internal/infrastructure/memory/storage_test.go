@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	db := NewDB()
+
+	authStorage, err := NewAuthStorage(db)
+	require.NoError(t, err)
+	shopStorage, err := NewShopStorage(db)
+	require.NoError(t, err)
+	ledgerStorage, err := NewLedgerStorage(db)
+	require.NoError(t, err)
+	tradeStorage, err := NewTradeStorage(db)
+	require.NoError(t, err)
+
+	storagetest.Run(t, storagetest.Storages{
+		Auth:     authStorage,
+		Shop:     shopStorage,
+		Products: shopStorage,
+		Ledger:   ledgerStorage,
+		Trades:   tradeStorage,
+	})
+}
+
+func TestExpiredIdempotencyKey(t *testing.T) {
+	db := NewDB()
+
+	authStorage, err := NewAuthStorage(db)
+	require.NoError(t, err)
+	shopStorage, err := NewShopStorage(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	err = authStorage.CreateUser(ctx, domain.UserCredantials{UserName: "test_user", Password: "test_password"})
+	require.NoError(t, err)
+
+	key := domain.IdempotencyKey{
+		Key:         "test_key",
+		RequestHash: "test_hash",
+		ExpiresAt:   time.Now().Add(-time.Second),
+	}
+	for i := 0; i < 2; i++ {
+		_, err = shopStorage.BuyItem(ctx, "test_user", "pen", key)
+		require.NoError(t, err)
+	}
+
+	info, err := shopStorage.GetInfo(ctx, "test_user")
+	require.NoError(t, err)
+	require.Equal(t, 980, info.Coins)
+}
+
+func TestKeyStorage(t *testing.T) {
+	keyStorage, err := NewKeyStorage()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	added, err := keyStorage.AddInitialKey(ctx, domain.SigningKey{Id: "old_kid", Secret: []byte("old_kid")})
+	require.NoError(t, err)
+	require.True(t, added)
+
+	added, err = keyStorage.AddInitialKey(ctx, domain.SigningKey{Id: "other_kid"})
+	require.NoError(t, err)
+	require.False(t, added)
+
+	err = keyStorage.AddActiveKey(ctx, domain.SigningKey{Id: "new_kid", Secret: []byte("new_kid")})
+	require.NoError(t, err)
+
+	err = keyStorage.AddActiveKey(ctx, domain.SigningKey{Id: "new_kid"})
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	err = keyStorage.RetireKey(ctx, "new_kid")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = keyStorage.RetireKey(ctx, "old_kid")
+	require.NoError(t, err)
+
+	keys, err := keyStorage.GetKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []domain.SigningKey{
+		{Id: "old_kid", Secret: []byte("old_kid"), Retired: true},
+		{Id: "new_kid", Secret: []byte("new_kid"), Active: true},
+	}, keys)
+}
+
+func TestDeleteExpiredTokens(t *testing.T) {
+	sessionStorage, err := NewSessionStorage()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	err = sessionStorage.RevokeToken(ctx, domain.RevokedToken{Id: "expired", ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	err = sessionStorage.RevokeToken(ctx, domain.RevokedToken{Id: "active", ExpiresAt: now.Add(time.Minute)})
+	require.NoError(t, err)
+	err = sessionStorage.RevokeUserTokens(ctx, 1, now.Add(-time.Hour))
+	require.NoError(t, err)
+	err = sessionStorage.RevokeUserTokens(ctx, 2, now)
+	require.NoError(t, err)
+
+	err = sessionStorage.DeleteExpiredTokens(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+
+	require.Equal(t, domain.Revocations{
+		Tokens:   map[string]time.Time{"active": now.Add(time.Minute)},
+		Cutoffs:  map[int]time.Time{2: now},
+		Sessions: map[string]time.Time{},
+	}, sessionStorage.revocations)
+}
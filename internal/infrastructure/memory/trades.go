@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type TradeStorage struct {
+	db *DB
+}
+
+func NewTradeStorage(db *DB) (*TradeStorage, error) {
+	return &TradeStorage{
+		db: db,
+	}, nil
+}
+
+func (tradeStorage *TradeStorage) CreateTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	tradeStorage.db.mu.Lock()
+	defer tradeStorage.db.mu.Unlock()
+
+	offered, err := tradeStorage.fromDomainSide(offer.Offered)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.CreateTrade): %w", err)
+	}
+
+	requested, err := tradeStorage.fromDomainSide(offer.Requested)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.CreateTrade): %w", err)
+	}
+
+	fromUser, ok := tradeStorage.db.users[offer.From]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (memory.CreateTrade): %s", customErrors.ErrDoesNotExist, offer.From)
+	}
+
+	toUser, ok := tradeStorage.db.users[offer.To]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (memory.CreateTrade): %s", customErrors.ErrDoesNotExist, offer.To)
+	}
+
+	// nothing is reserved for the trade, the offer is checked once more on acceptance
+	_, err = tradeStorage.pickTradeSide(fromUser, offered)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.CreateTrade): %w", err)
+	}
+
+	tradeStorage.db.lastTradeId++
+	created := trade{
+		id:        tradeStorage.db.lastTradeId,
+		userFrom:  fromUser.id,
+		userTo:    toUser.id,
+		offered:   offered,
+		requested: requested,
+		status:    domain.TradeStatusPending,
+		// the same precision as postgres timestamps
+		createdAt: time.Now().UTC().Truncate(time.Microsecond),
+		expiresAt: offer.ExpiresAt.UTC().Truncate(time.Microsecond),
+	}
+	tradeStorage.db.trades = append(tradeStorage.db.trades, created)
+
+	return tradeStorage.toDomain(created), nil
+}
+
+func (tradeStorage *TradeStorage) AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tradeStorage.db.mu.Lock()
+	defer tradeStorage.db.mu.Unlock()
+
+	accepted, err := tradeStorage.getTrade(username, tradeId)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	trade := tradeStorage.toDomain(*accepted)
+	err = trade.CheckResolvable(username)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	fromUser := tradeStorage.db.usersById[accepted.userFrom]
+	toUser := tradeStorage.db.usersById[accepted.userTo]
+
+	// everything is checked before anything is moved, so the trade is applied either fully or not at all
+	offeredItems, err := tradeStorage.pickTradeSide(fromUser, accepted.offered)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	requestedItems, err := tradeStorage.pickTradeSide(toUser, accepted.requested)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	err = tradeStorage.giveTradeSide(accepted.id, fromUser, toUser, accepted.offered.coins, offeredItems)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	err = tradeStorage.giveTradeSide(accepted.id, toUser, fromUser, accepted.requested.coins, requestedItems)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.AcceptTrade): %w", err)
+	}
+
+	accepted.status = domain.TradeStatusAccepted
+	accepted.resolvedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return tradeStorage.toDomain(*accepted), nil
+}
+
+func (tradeStorage *TradeStorage) DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tradeStorage.db.mu.Lock()
+	defer tradeStorage.db.mu.Unlock()
+
+	declined, err := tradeStorage.getTrade(username, tradeId)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.DeclineTrade): %w", err)
+	}
+
+	trade := tradeStorage.toDomain(*declined)
+	err = trade.CheckResolvable(username)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.DeclineTrade): %w", err)
+	}
+
+	declined.status = domain.TradeStatusDeclined
+	declined.resolvedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return tradeStorage.toDomain(*declined), nil
+}
+
+func (tradeStorage *TradeStorage) GetTrades(ctx context.Context, username string) ([]domain.Trade, error) {
+	tradeStorage.db.mu.RLock()
+	defer tradeStorage.db.mu.RUnlock()
+
+	trades := make([]domain.Trade, 0)
+	user, ok := tradeStorage.db.users[username]
+	if !ok {
+		return trades, nil
+	}
+
+	for i := len(tradeStorage.db.trades) - 1; i >= 0 && len(trades) < domain.MaxTrades; i-- {
+		trade := tradeStorage.db.trades[i]
+		if trade.userFrom != user.id && trade.userTo != user.id {
+			continue
+		}
+
+		trades = append(trades, tradeStorage.toDomain(trade))
+	}
+
+	return trades, nil
+}
+
+func (tradeStorage *TradeStorage) GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tradeStorage.db.mu.RLock()
+	defer tradeStorage.db.mu.RUnlock()
+
+	trade, err := tradeStorage.getTrade(username, tradeId)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(memory.GetTrade): %w", err)
+	}
+
+	return tradeStorage.toDomain(*trade), nil
+}
+
+// getTrade finds a trade of the user, the trades between other users are reported as missing.
+func (tradeStorage *TradeStorage) getTrade(username string, tradeId int64) (*trade, error) {
+	user, ok := tradeStorage.db.users[username]
+	if ok {
+		for i := range tradeStorage.db.trades {
+			trade := &tradeStorage.db.trades[i]
+			if trade.id == tradeId && (trade.userFrom == user.id || trade.userTo == user.id) {
+				return trade, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w (memory.getTrade): trade %d", customErrors.ErrDoesNotExist, tradeId)
+}
+
+func (tradeStorage *TradeStorage) toDomain(stored trade) domain.Trade {
+	trade := domain.Trade{
+		Id:        stored.id,
+		From:      tradeStorage.db.usersById[stored.userFrom].name,
+		To:        tradeStorage.db.usersById[stored.userTo].name,
+		Offered:   tradeStorage.toDomainSide(stored.offered),
+		Requested: tradeStorage.toDomainSide(stored.requested),
+		Status:    stored.status,
+		CreatedAt: stored.createdAt,
+		ExpiresAt: stored.expiresAt,
+	}
+
+	if trade.Status == domain.TradeStatusPending && !time.Now().Before(stored.expiresAt) {
+		trade.Status = domain.TradeStatusExpired
+	}
+
+	if !stored.resolvedAt.IsZero() {
+		resolvedAt := stored.resolvedAt
+		trade.ResolvedAt = &resolvedAt
+	}
+
+	return trade
+}
+
+// fromDomainSide refers to the products by id, so that the trade survives renaming them.
+func (tradeStorage *TradeStorage) fromDomainSide(side domain.TradeSide) (tradeSide, error) {
+	stored := tradeSide{
+		items: make([]tradeItem, 0, len(side.Items)),
+		coins: side.Coins,
+	}
+	for _, item := range side.Items {
+		product, ok := tradeStorage.db.products[item.Item]
+		if !ok {
+			return tradeSide{}, fmt.Errorf("%w (memory.fromDomainSide): %s", customErrors.ErrDoesNotExist, item.Item)
+		}
+
+		stored.items = append(stored.items, tradeItem{productId: product.id, quantity: item.Quantity})
+	}
+
+	return stored, nil
+}
+
+func (tradeStorage *TradeStorage) toDomainSide(stored tradeSide) domain.TradeSide {
+	side := domain.TradeSide{
+		Items: make([]domain.TradeItem, 0, len(stored.items)),
+		Coins: stored.coins,
+	}
+	for _, item := range stored.items {
+		side.Items = append(side.Items, domain.TradeItem{
+			Item:     tradeStorage.db.productsById[item.productId].name,
+			Quantity: item.quantity,
+		})
+	}
+
+	return side
+}
+
+// pickTradeSide verifies that the user has the coins and the items given away
+// and returns the positions of the earliest bought items.
+func (tradeStorage *TradeStorage) pickTradeSide(owner *user, side tradeSide) ([]int, error) {
+	if owner.money-side.coins < 0 {
+		return nil, fmt.Errorf("%w (memory.pickTradeSide)", customErrors.ErrInsufficientFunds)
+	}
+
+	positions := make([]int, 0)
+	for _, item := range side.items {
+		picked := 0
+		for i := 0; i < len(tradeStorage.db.purchases) && picked < item.quantity; i++ {
+			purchase := tradeStorage.db.purchases[i]
+			if purchase.userId != owner.id || purchase.productId != item.productId || !purchase.refundedAt.IsZero() {
+				continue
+			}
+
+			positions = append(positions, i)
+			picked++
+		}
+
+		if picked < item.quantity {
+			return nil, fmt.Errorf("%w (memory.pickTradeSide): not enough %s",
+				customErrors.ErrNotAvailable, tradeStorage.db.productsById[item.productId].name)
+		}
+	}
+
+	return positions, nil
+}
+
+// giveTradeSide moves the coins and the items of the side from one user to another,
+// a side that gives anything is recorded in the history of both users, even with no coins.
+func (tradeStorage *TradeStorage) giveTradeSide(
+	tradeId int64,
+	fromUser *user,
+	toUser *user,
+	coins int,
+	positions []int) error {
+	if coins > 0 {
+		err := tradeStorage.db.postLedgerEntry(domain.NewLedgerTransfer(
+			domain.LedgerEntryTransfer,
+			domain.UserAccount(fromUser.id),
+			domain.UserAccount(toUser.id),
+			coins))
+		if err != nil {
+			return fmt.Errorf("(memory.giveTradeSide): %w", err)
+		}
+	}
+
+	if coins > 0 || len(positions) > 0 {
+		tradeStorage.db.lastTransferId++
+		tradeStorage.db.transfers = append(tradeStorage.db.transfers, transfer{
+			id:       tradeStorage.db.lastTransferId,
+			userFrom: fromUser.id,
+			userTo:   toUser.id,
+			money:    coins,
+			sentAt:   time.Now().UTC().Truncate(time.Microsecond),
+			tradeId:  tradeId,
+		})
+	}
+
+	for _, position := range positions {
+		purchase := &tradeStorage.db.purchases[position]
+		purchase.boughtFor = purchase.firstOwner()
+		purchase.userId = toUser.id
+	}
+
+	return nil
+}
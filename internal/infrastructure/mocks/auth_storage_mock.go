@@ -35,6 +35,20 @@ func (m *MockAuthStorage) EXPECT() *MockAuthStorageMockRecorder {
 	return m.recorder
 }
 
+// CreatePasswordReset mocks base method.
+func (m *MockAuthStorage) CreatePasswordReset(ctx context.Context, reset domain.PasswordReset) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, reset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockAuthStorageMockRecorder) CreatePasswordReset(ctx, reset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockAuthStorage)(nil).CreatePasswordReset), ctx, reset)
+}
+
 // CreateUser mocks base method.
 func (m *MockAuthStorage) CreateUser(ctx context.Context, userCreds domain.UserCredantials) error {
 	m.ctrl.T.Helper()
@@ -64,6 +78,50 @@ func (mr *MockAuthStorageMockRecorder) GetPassword(ctx, email interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPassword", reflect.TypeOf((*MockAuthStorage)(nil).GetPassword), ctx, email)
 }
 
+// GetPasswordReset mocks base method.
+func (m *MockAuthStorage) GetPasswordReset(ctx context.Context, hash string) (domain.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordReset", ctx, hash)
+	ret0, _ := ret[0].(domain.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordReset indicates an expected call of GetPasswordReset.
+func (mr *MockAuthStorageMockRecorder) GetPasswordReset(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordReset", reflect.TypeOf((*MockAuthStorage)(nil).GetPasswordReset), ctx, hash)
+}
+
+// GetUser mocks base method.
+func (m *MockAuthStorage) GetUser(ctx context.Context, name string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, name)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockAuthStorageMockRecorder) GetUser(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAuthStorage)(nil).GetUser), ctx, name)
+}
+
+// GrantRole mocks base method.
+func (m *MockAuthStorage) GrantRole(ctx context.Context, name, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrantRole", ctx, name, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GrantRole indicates an expected call of GrantRole.
+func (mr *MockAuthStorageMockRecorder) GrantRole(ctx, name, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrantRole", reflect.TypeOf((*MockAuthStorage)(nil).GrantRole), ctx, name, role)
+}
+
 // HasUser mocks base method.
 func (m *MockAuthStorage) HasUser(ctx context.Context, name string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -78,3 +136,60 @@ func (mr *MockAuthStorageMockRecorder) HasUser(ctx, name interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasUser", reflect.TypeOf((*MockAuthStorage)(nil).HasUser), ctx, name)
 }
+
+// RehashPassword mocks base method.
+func (m *MockAuthStorage) RehashPassword(ctx context.Context, name, oldPassword, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashPassword", ctx, name, oldPassword, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RehashPassword indicates an expected call of RehashPassword.
+func (mr *MockAuthStorageMockRecorder) RehashPassword(ctx, name, oldPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashPassword", reflect.TypeOf((*MockAuthStorage)(nil).RehashPassword), ctx, name, oldPassword, newPassword)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuthStorage) ResetPassword(ctx context.Context, hash, password string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, hash, password)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthStorageMockRecorder) ResetPassword(ctx, hash, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuthStorage)(nil).ResetPassword), ctx, hash, password)
+}
+
+// RevokeRole mocks base method.
+func (m *MockAuthStorage) RevokeRole(ctx context.Context, name, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", ctx, name, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockAuthStorageMockRecorder) RevokeRole(ctx, name, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockAuthStorage)(nil).RevokeRole), ctx, name, role)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockAuthStorage) UpdatePassword(ctx context.Context, name, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, name, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockAuthStorageMockRecorder) UpdatePassword(ctx, name, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockAuthStorage)(nil).UpdatePassword), ctx, name, password)
+}
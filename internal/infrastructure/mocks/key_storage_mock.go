@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/keys.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockKeyStorage is a mock of KeyStorage interface.
+type MockKeyStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockKeyStorageMockRecorder
+}
+
+// MockKeyStorageMockRecorder is the mock recorder for MockKeyStorage.
+type MockKeyStorageMockRecorder struct {
+	mock *MockKeyStorage
+}
+
+// NewMockKeyStorage creates a new mock instance.
+func NewMockKeyStorage(ctrl *gomock.Controller) *MockKeyStorage {
+	mock := &MockKeyStorage{ctrl: ctrl}
+	mock.recorder = &MockKeyStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockKeyStorage) EXPECT() *MockKeyStorageMockRecorder {
+	return m.recorder
+}
+
+// AddActiveKey mocks base method.
+func (m *MockKeyStorage) AddActiveKey(ctx context.Context, key domain.SigningKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddActiveKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddActiveKey indicates an expected call of AddActiveKey.
+func (mr *MockKeyStorageMockRecorder) AddActiveKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddActiveKey", reflect.TypeOf((*MockKeyStorage)(nil).AddActiveKey), ctx, key)
+}
+
+// AddInitialKey mocks base method.
+func (m *MockKeyStorage) AddInitialKey(ctx context.Context, key domain.SigningKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddInitialKey", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddInitialKey indicates an expected call of AddInitialKey.
+func (mr *MockKeyStorageMockRecorder) AddInitialKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddInitialKey", reflect.TypeOf((*MockKeyStorage)(nil).AddInitialKey), ctx, key)
+}
+
+// GetKeys mocks base method.
+func (m *MockKeyStorage) GetKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKeys", ctx)
+	ret0, _ := ret[0].([]domain.SigningKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKeys indicates an expected call of GetKeys.
+func (mr *MockKeyStorageMockRecorder) GetKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKeys", reflect.TypeOf((*MockKeyStorage)(nil).GetKeys), ctx)
+}
+
+// RetireKey mocks base method.
+func (m *MockKeyStorage) RetireKey(ctx context.Context, kid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetireKey", ctx, kid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetireKey indicates an expected call of RetireKey.
+func (mr *MockKeyStorageMockRecorder) RetireKey(ctx, kid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetireKey", reflect.TypeOf((*MockKeyStorage)(nil).RetireKey), ctx, kid)
+}
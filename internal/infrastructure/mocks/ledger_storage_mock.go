@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/ledger.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLedgerStorage is a mock of LedgerStorage interface.
+type MockLedgerStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerStorageMockRecorder
+}
+
+// MockLedgerStorageMockRecorder is the mock recorder for MockLedgerStorage.
+type MockLedgerStorageMockRecorder struct {
+	mock *MockLedgerStorage
+}
+
+// NewMockLedgerStorage creates a new mock instance.
+func NewMockLedgerStorage(ctrl *gomock.Controller) *MockLedgerStorage {
+	mock := &MockLedgerStorage{ctrl: ctrl}
+	mock.recorder = &MockLedgerStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedgerStorage) EXPECT() *MockLedgerStorageMockRecorder {
+	return m.recorder
+}
+
+// GetActivity mocks base method.
+func (m *MockLedgerStorage) GetActivity(ctx context.Context) ([]domain.AccountActivity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActivity", ctx)
+	ret0, _ := ret[0].([]domain.AccountActivity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActivity indicates an expected call of GetActivity.
+func (mr *MockLedgerStorageMockRecorder) GetActivity(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActivity", reflect.TypeOf((*MockLedgerStorage)(nil).GetActivity), ctx)
+}
+
+// GetBalances mocks base method.
+func (m *MockLedgerStorage) GetBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalances", ctx)
+	ret0, _ := ret[0].([]domain.AccountBalance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalances indicates an expected call of GetBalances.
+func (mr *MockLedgerStorageMockRecorder) GetBalances(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalances", reflect.TypeOf((*MockLedgerStorage)(nil).GetBalances), ctx)
+}
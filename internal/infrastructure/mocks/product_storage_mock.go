@@ -0,0 +1,81 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/products.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockProductStorage is a mock of ProductStorage interface.
+type MockProductStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductStorageMockRecorder
+}
+
+// MockProductStorageMockRecorder is the mock recorder for MockProductStorage.
+type MockProductStorageMockRecorder struct {
+	mock *MockProductStorage
+}
+
+// NewMockProductStorage creates a new mock instance.
+func NewMockProductStorage(ctrl *gomock.Controller) *MockProductStorage {
+	mock := &MockProductStorage{ctrl: ctrl}
+	mock.recorder = &MockProductStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProductStorage) EXPECT() *MockProductStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateProduct mocks base method.
+func (m *MockProductStorage) CreateProduct(ctx context.Context, actor string, product domain.Product) (domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateProduct", ctx, actor, product)
+	ret0, _ := ret[0].(domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateProduct indicates an expected call of CreateProduct.
+func (mr *MockProductStorageMockRecorder) CreateProduct(ctx, actor, product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateProduct", reflect.TypeOf((*MockProductStorage)(nil).CreateProduct), ctx, actor, product)
+}
+
+// GetProductAudit mocks base method.
+func (m *MockProductStorage) GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProductAudit", ctx, name)
+	ret0, _ := ret[0].([]domain.ProductChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProductAudit indicates an expected call of GetProductAudit.
+func (mr *MockProductStorageMockRecorder) GetProductAudit(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProductAudit", reflect.TypeOf((*MockProductStorage)(nil).GetProductAudit), ctx, name)
+}
+
+// UpdateProduct mocks base method.
+func (m *MockProductStorage) UpdateProduct(ctx context.Context, name string, update domain.ProductUpdate) (domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateProduct", ctx, name, update)
+	ret0, _ := ret[0].(domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateProduct indicates an expected call of UpdateProduct.
+func (mr *MockProductStorageMockRecorder) UpdateProduct(ctx, name, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProduct", reflect.TypeOf((*MockProductStorage)(nil).UpdateProduct), ctx, name, update)
+}
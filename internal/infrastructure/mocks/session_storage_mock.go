@@ -0,0 +1,137 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/sessions.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSessionStorage is a mock of SessionStorage interface.
+type MockSessionStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionStorageMockRecorder
+}
+
+// MockSessionStorageMockRecorder is the mock recorder for MockSessionStorage.
+type MockSessionStorageMockRecorder struct {
+	mock *MockSessionStorage
+}
+
+// NewMockSessionStorage creates a new mock instance.
+func NewMockSessionStorage(ctrl *gomock.Controller) *MockSessionStorage {
+	mock := &MockSessionStorage{ctrl: ctrl}
+	mock.recorder = &MockSessionStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionStorage) EXPECT() *MockSessionStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockSessionStorage) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockSessionStorageMockRecorder) CreateRefreshToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockSessionStorage)(nil).CreateRefreshToken), ctx, token)
+}
+
+// DeleteExpiredTokens mocks base method.
+func (m *MockSessionStorage) DeleteExpiredTokens(ctx context.Context, cutoffsBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokens", ctx, cutoffsBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokens indicates an expected call of DeleteExpiredTokens.
+func (mr *MockSessionStorageMockRecorder) DeleteExpiredTokens(ctx, cutoffsBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokens", reflect.TypeOf((*MockSessionStorage)(nil).DeleteExpiredTokens), ctx, cutoffsBefore)
+}
+
+// GetRevocations mocks base method.
+func (m *MockSessionStorage) GetRevocations(ctx context.Context) (domain.Revocations, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevocations", ctx)
+	ret0, _ := ret[0].(domain.Revocations)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevocations indicates an expected call of GetRevocations.
+func (mr *MockSessionStorageMockRecorder) GetRevocations(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevocations", reflect.TypeOf((*MockSessionStorage)(nil).GetRevocations), ctx)
+}
+
+// RevokeRefreshFamily mocks base method.
+func (m *MockSessionStorage) RevokeRefreshFamily(ctx context.Context, familyId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshFamily", ctx, familyId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshFamily indicates an expected call of RevokeRefreshFamily.
+func (mr *MockSessionStorageMockRecorder) RevokeRefreshFamily(ctx, familyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshFamily", reflect.TypeOf((*MockSessionStorage)(nil).RevokeRefreshFamily), ctx, familyId)
+}
+
+// RevokeToken mocks base method.
+func (m *MockSessionStorage) RevokeToken(ctx context.Context, token domain.RevokedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockSessionStorageMockRecorder) RevokeToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockSessionStorage)(nil).RevokeToken), ctx, token)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockSessionStorage) RevokeUserTokens(ctx context.Context, userId int, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", ctx, userId, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockSessionStorageMockRecorder) RevokeUserTokens(ctx, userId, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockSessionStorage)(nil).RevokeUserTokens), ctx, userId, before)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockSessionStorage) RotateRefreshToken(ctx context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, hash, next)
+	ret0, _ := ret[0].(domain.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockSessionStorageMockRecorder) RotateRefreshToken(ctx, hash, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockSessionStorage)(nil).RotateRefreshToken), ctx, hash, next)
+}
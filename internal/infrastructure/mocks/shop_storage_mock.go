@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
 	gomock "github.com/golang/mock/gomock"
@@ -35,18 +36,49 @@ func (m *MockShopStorage) EXPECT() *MockShopStorageMockRecorder {
 	return m.recorder
 }
 
+// BuyCart mocks base method.
+func (m *MockShopStorage) BuyCart(ctx context.Context, username string, cart domain.Cart, idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BuyCart", ctx, username, cart, idempotencyKey)
+	ret0, _ := ret[0].(domain.CartReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BuyCart indicates an expected call of BuyCart.
+func (mr *MockShopStorageMockRecorder) BuyCart(ctx, username, cart, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyCart", reflect.TypeOf((*MockShopStorage)(nil).BuyCart), ctx, username, cart, idempotencyKey)
+}
+
 // BuyItem mocks base method.
-func (m *MockShopStorage) BuyItem(ctx context.Context, username, itemName string) error {
+func (m *MockShopStorage) BuyItem(ctx context.Context, username, itemName string, idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuyItem", ctx, username, itemName)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "BuyItem", ctx, username, itemName, idempotencyKey)
+	ret0, _ := ret[0].(domain.PurchaseReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // BuyItem indicates an expected call of BuyItem.
-func (mr *MockShopStorageMockRecorder) BuyItem(ctx, username, itemName interface{}) *gomock.Call {
+func (mr *MockShopStorageMockRecorder) BuyItem(ctx, username, itemName, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShopStorage)(nil).BuyItem), ctx, username, itemName, idempotencyKey)
+}
+
+// GetHistory mocks base method.
+func (m *MockShopStorage) GetHistory(ctx context.Context, username string, filter domain.HistoryFilter) (domain.HistoryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", ctx, username, filter)
+	ret0, _ := ret[0].(domain.HistoryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockShopStorageMockRecorder) GetHistory(ctx, username, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShopStorage)(nil).BuyItem), ctx, username, itemName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockShopStorage)(nil).GetHistory), ctx, username, filter)
 }
 
 // GetInfo mocks base method.
@@ -64,16 +96,76 @@ func (mr *MockShopStorageMockRecorder) GetInfo(ctx, username interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInfo", reflect.TypeOf((*MockShopStorage)(nil).GetInfo), ctx, username)
 }
 
+// GetProduct mocks base method.
+func (m *MockShopStorage) GetProduct(ctx context.Context, name string) (domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProduct", ctx, name)
+	ret0, _ := ret[0].(domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProduct indicates an expected call of GetProduct.
+func (mr *MockShopStorageMockRecorder) GetProduct(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockShopStorage)(nil).GetProduct), ctx, name)
+}
+
+// GetProducts mocks base method.
+func (m *MockShopStorage) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProducts", ctx)
+	ret0, _ := ret[0].([]domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProducts indicates an expected call of GetProducts.
+func (mr *MockShopStorageMockRecorder) GetProducts(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProducts", reflect.TypeOf((*MockShopStorage)(nil).GetProducts), ctx)
+}
+
+// GiftItem mocks base method.
+func (m *MockShopStorage) GiftItem(ctx context.Context, gift domain.Gift, idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GiftItem", ctx, gift, idempotencyKey)
+	ret0, _ := ret[0].(domain.GiftReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GiftItem indicates an expected call of GiftItem.
+func (mr *MockShopStorageMockRecorder) GiftItem(ctx, gift, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GiftItem", reflect.TypeOf((*MockShopStorage)(nil).GiftItem), ctx, gift, idempotencyKey)
+}
+
+// RefundPurchase mocks base method.
+func (m *MockShopStorage) RefundPurchase(ctx context.Context, username string, purchaseId int64, boughtAfter time.Time) (domain.RefundReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundPurchase", ctx, username, purchaseId, boughtAfter)
+	ret0, _ := ret[0].(domain.RefundReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundPurchase indicates an expected call of RefundPurchase.
+func (mr *MockShopStorageMockRecorder) RefundPurchase(ctx, username, purchaseId, boughtAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundPurchase", reflect.TypeOf((*MockShopStorage)(nil).RefundPurchase), ctx, username, purchaseId, boughtAfter)
+}
+
 // SendCoin mocks base method.
-func (m *MockShopStorage) SendCoin(ctx context.Context, transaction domain.Transaction) error {
+func (m *MockShopStorage) SendCoin(ctx context.Context, transaction domain.Transaction, idempotencyKey domain.IdempotencyKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendCoin", ctx, transaction)
+	ret := m.ctrl.Call(m, "SendCoin", ctx, transaction, idempotencyKey)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendCoin indicates an expected call of SendCoin.
-func (mr *MockShopStorageMockRecorder) SendCoin(ctx, transaction interface{}) *gomock.Call {
+func (mr *MockShopStorageMockRecorder) SendCoin(ctx, transaction, idempotencyKey interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockShopStorage)(nil).SendCoin), ctx, transaction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockShopStorage)(nil).SendCoin), ctx, transaction, idempotencyKey)
 }
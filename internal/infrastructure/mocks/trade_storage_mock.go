@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/services/trades.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTradeStorage is a mock of TradeStorage interface.
+type MockTradeStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeStorageMockRecorder
+}
+
+// MockTradeStorageMockRecorder is the mock recorder for MockTradeStorage.
+type MockTradeStorageMockRecorder struct {
+	mock *MockTradeStorage
+}
+
+// NewMockTradeStorage creates a new mock instance.
+func NewMockTradeStorage(ctrl *gomock.Controller) *MockTradeStorage {
+	mock := &MockTradeStorage{ctrl: ctrl}
+	mock.recorder = &MockTradeStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeStorage) EXPECT() *MockTradeStorageMockRecorder {
+	return m.recorder
+}
+
+// AcceptTrade mocks base method.
+func (m *MockTradeStorage) AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptTrade indicates an expected call of AcceptTrade.
+func (mr *MockTradeStorageMockRecorder) AcceptTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptTrade", reflect.TypeOf((*MockTradeStorage)(nil).AcceptTrade), ctx, username, tradeId)
+}
+
+// CreateTrade mocks base method.
+func (m *MockTradeStorage) CreateTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTrade", ctx, offer)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTrade indicates an expected call of CreateTrade.
+func (mr *MockTradeStorageMockRecorder) CreateTrade(ctx, offer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTrade", reflect.TypeOf((*MockTradeStorage)(nil).CreateTrade), ctx, offer)
+}
+
+// DeclineTrade mocks base method.
+func (m *MockTradeStorage) DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclineTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeclineTrade indicates an expected call of DeclineTrade.
+func (mr *MockTradeStorageMockRecorder) DeclineTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclineTrade", reflect.TypeOf((*MockTradeStorage)(nil).DeclineTrade), ctx, username, tradeId)
+}
+
+// GetTrade mocks base method.
+func (m *MockTradeStorage) GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrade indicates an expected call of GetTrade.
+func (mr *MockTradeStorageMockRecorder) GetTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrade", reflect.TypeOf((*MockTradeStorage)(nil).GetTrade), ctx, username, tradeId)
+}
+
+// GetTrades mocks base method.
+func (m *MockTradeStorage) GetTrades(ctx context.Context, username string) ([]domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrades", ctx, username)
+	ret0, _ := ret[0].([]domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrades indicates an expected call of GetTrades.
+func (mr *MockTradeStorageMockRecorder) GetTrades(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrades", reflect.TypeOf((*MockTradeStorage)(nil).GetTrades), ctx, username)
+}
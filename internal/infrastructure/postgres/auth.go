@@ -21,22 +21,57 @@ func NewAuthStorage(pool PgxPool) (*AuthStorage, error) {
 	}, nil
 }
 
+// CreateUser relies on the unique constraint on the user name,
+// so concurrent signups with the same name cannot both succeed.
 func (authStorage *AuthStorage) CreateUser(ctx context.Context, userCreds domain.UserCredantials) error {
-	hasUser, err := authStorage.HasUser(ctx, userCreds.UserName)
+	tx, err := authStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrInternal, err)
+		return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrFailedToBeginTx, err)
 	}
-	if hasUser {
-		return fmt.Errorf("%w (postgres.CreateUser)", customErrors.ErrAlreadyExists)
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.CreateUser): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	var userId int
+	err = tx.QueryRow(ctx, `
+		insert into users(name, password) values ($1, $2)
+		returning id;
+	`, userCreds.UserName, userCreds.Password).Scan(&userId)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrAlreadyExists, err)
+		}
+		if isCheckViolation(err) {
+			return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrDataNotValid, err)
+		}
+
+		return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrFailedToExecuteQuery, err)
 	}
 
-	_, err = authStorage.pool.Exec(ctx, `
-		insert into users(name, password) values ($1, $2);
-	`, userCreds.UserName, userCreds.Password)
+	_, err = tx.Exec(ctx, `
+		insert into ledger_account(kind, user_id) values ($1, $2);
+	`, domain.LedgerAccountUser, userId)
 	if err != nil {
 		return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrFailedToExecuteQuery, err)
 	}
 
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntrySignup,
+		domain.MintAccount,
+		domain.UserAccount(userId),
+		domain.SignupGrant))
+	if err != nil {
+		return fmt.Errorf("(postgres.CreateUser): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreateUser): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
 	return nil
 }
 
@@ -75,3 +110,201 @@ func (authStorage *AuthStorage) HasUser(ctx context.Context, name string) (bool,
 
 	return true, nil
 }
+
+func (authStorage *AuthStorage) GetUser(ctx context.Context, name string) (domain.User, error) {
+	var user domain.User
+
+	err := authStorage.pool.QueryRow(ctx, `
+		select u.id, u.name, coalesce(array_agg(r.role order by r.role) filter (where r.role is not null), '{}')
+		from users u
+		left join user_role r on r.user_id = u.id
+		where u.name = $1
+		group by u.id;
+	`, name).Scan(&user.Id, &user.Name, &user.Roles)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.User{}, fmt.Errorf("%w (postgres.GetUser): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return domain.User{}, fmt.Errorf("%w (postgres.GetUser): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return user, nil
+}
+
+// GrantRole does nothing if the user already has the role.
+func (authStorage *AuthStorage) GrantRole(ctx context.Context, name string, role string) error {
+	tag, err := authStorage.pool.Exec(ctx, `
+		insert into user_role(user_id, role)
+		select id, $2
+		from users
+		where name = $1
+		on conflict do nothing;
+	`, name, role)
+	if err != nil {
+		if isCheckViolation(err) {
+			return fmt.Errorf("%w (postgres.GrantRole): %w", customErrors.ErrDataNotValid, err)
+		}
+
+		return fmt.Errorf("%w (postgres.GrantRole): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return authStorage.checkUserExists(ctx, name)
+	}
+
+	return nil
+}
+
+// RevokeRole does nothing if the user does not have the role.
+func (authStorage *AuthStorage) RevokeRole(ctx context.Context, name string, role string) error {
+	tag, err := authStorage.pool.Exec(ctx, `
+		delete from user_role r
+		using users u
+		where u.id = r.user_id and u.name = $1 and r.role = $2;
+	`, name, role)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeRole): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return authStorage.checkUserExists(ctx, name)
+	}
+
+	return nil
+}
+
+func (authStorage *AuthStorage) UpdatePassword(ctx context.Context, name string, password string) error {
+	tag, err := authStorage.pool.Exec(ctx, `
+		update users
+		set password = $2
+		where name = $1;
+	`, name, password)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.UpdatePassword): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w (postgres.UpdatePassword): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	return nil
+}
+
+// RehashPassword replaces the hash only if the password was not changed meanwhile.
+func (authStorage *AuthStorage) RehashPassword(
+	ctx context.Context,
+	name string,
+	oldPassword string,
+	newPassword string) error {
+	_, err := authStorage.pool.Exec(ctx, `
+		update users
+		set password = $3
+		where name = $1 and password = $2;
+	`, name, oldPassword, newPassword)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RehashPassword): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+// CreatePasswordReset replaces the resets issued to the user before.
+func (authStorage *AuthStorage) CreatePasswordReset(ctx context.Context, reset domain.PasswordReset) error {
+	tx, err := authStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreatePasswordReset): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.CreatePasswordReset): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		delete from password_reset
+		where user_id = $1;
+	`, reset.UserId)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreatePasswordReset): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into password_reset(token_hash, user_id, expires_at)
+		values ($1, $2, $3);
+	`, reset.Hash, reset.UserId, reset.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreatePasswordReset): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreatePasswordReset): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return nil
+}
+
+// GetPasswordReset returns the reset with the given hash unless it has expired.
+func (authStorage *AuthStorage) GetPasswordReset(ctx context.Context, hash string) (domain.PasswordReset, error) {
+	reset := domain.PasswordReset{Hash: hash}
+
+	err := authStorage.pool.QueryRow(ctx, `
+		select user_id, expires_at
+		from password_reset
+		where token_hash = $1 and expires_at > now();
+	`, hash).Scan(&reset.UserId, &reset.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.PasswordReset{}, fmt.Errorf("%w (postgres.GetPasswordReset): invalid or expired reset token",
+				customErrors.ErrDataNotValid)
+		}
+
+		return domain.PasswordReset{}, fmt.Errorf("%w (postgres.GetPasswordReset): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return reset, nil
+}
+
+// ResetPassword consumes the reset with the given hash and sets the password of its user,
+// the reset is deleted by the same statement so it cannot be used twice.
+func (authStorage *AuthStorage) ResetPassword(ctx context.Context, hash string, password string) (domain.User, error) {
+	var user domain.User
+
+	err := authStorage.pool.QueryRow(ctx, `
+		with reset as (
+			delete from password_reset
+			where token_hash = $1 and expires_at > now()
+			returning user_id
+		)
+		update users u
+		set password = $2
+		from reset
+		where u.id = reset.user_id
+		returning u.id, u.name;
+	`, hash, password).Scan(&user.Id, &user.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.User{}, fmt.Errorf("%w (postgres.ResetPassword): invalid or expired reset token",
+				customErrors.ErrDataNotValid)
+		}
+
+		return domain.User{}, fmt.Errorf("%w (postgres.ResetPassword): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return user, nil
+}
+
+func (authStorage *AuthStorage) checkUserExists(ctx context.Context, name string) error {
+	ok, err := authStorage.HasUser(ctx, name)
+	if err != nil {
+		return fmt.Errorf("(postgres.checkUserExists): %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w (postgres.checkUserExists): %s", customErrors.ErrDoesNotExist, name)
+	}
+
+	return nil
+}
@@ -3,7 +3,10 @@ package postgres
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v3"
 	"github.com/stretchr/testify/require"
 
@@ -24,19 +27,43 @@ func TestCreateUser(t *testing.T) {
 		Password: "test_password",
 	}
 
-	mock.ExpectQuery("select").
-		WithArgs(userCreds.UserName).
-		WillReturnRows(pgxmock.NewRows([]string{}))
+	userId := 1
 
-	mock.ExpectExec("insert").
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectQuery("insert").
 		WithArgs(userCreds.UserName, userCreds.Password).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(userId))
+
+	mock.ExpectExec("insert into ledger_account").
+		WithArgs(domain.LedgerAccountUser, userId).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntrySignup,
+		domain.MintAccount,
+		domain.UserAccount(userId),
+		domain.SignupGrant))
+
+	mock.ExpectCommit()
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectQuery("insert").
+		WithArgs(userCreds.UserName, userCreds.Password).
+		WillReturnError(&pgconn.PgError{Code: uniqueViolationCode})
+
+	mock.ExpectRollback()
+
 	err = storage.CreateUser(context.Background(), userCreds)
 	require.NoError(t, err)
 
 	err = storage.CreateUser(context.Background(), userCreds)
-	require.Error(t, err, customErrors.ErrAlreadyExists)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
 
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
@@ -101,3 +128,159 @@ func TestHasUser(t *testing.T) {
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
 }
+
+func TestGetUser(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewAuthStorage(mock)
+	require.NoError(t, err)
+
+	userName := "test_user"
+
+	mock.ExpectQuery("select").
+		WithArgs(userName).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "roles"}).
+			AddRow(1, userName, []string{domain.RoleAdmin}))
+
+	user, err := storage.GetUser(context.Background(), userName)
+	require.NoError(t, err)
+	require.Equal(t, domain.User{Id: 1, Name: userName, Roles: []string{domain.RoleAdmin}}, user)
+
+	userName = "unknown_user"
+
+	mock.ExpectQuery("select").
+		WithArgs(userName).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "roles"}))
+
+	_, err = storage.GetUser(context.Background(), userName)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGrantRole(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewAuthStorage(mock)
+	require.NoError(t, err)
+
+	mock.ExpectExec("insert into user_role").
+		WithArgs("test_user", domain.RoleAdmin).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec("insert into user_role").
+		WithArgs("test_user", domain.RoleAdmin).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectQuery("select").
+		WithArgs("test_user").
+		WillReturnRows(pgxmock.NewRows([]string{}).AddRow())
+
+	mock.ExpectExec("insert into user_role").
+		WithArgs("unknown_user", domain.RoleAdmin).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectQuery("select").
+		WithArgs("unknown_user").
+		WillReturnError(pgx.ErrNoRows)
+
+	err = storage.GrantRole(context.Background(), "test_user", domain.RoleAdmin)
+	require.NoError(t, err)
+
+	err = storage.GrantRole(context.Background(), "test_user", domain.RoleAdmin)
+	require.NoError(t, err)
+
+	err = storage.GrantRole(context.Background(), "unknown_user", domain.RoleAdmin)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestRevokeRole(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewAuthStorage(mock)
+	require.NoError(t, err)
+
+	mock.ExpectExec("delete from user_role").
+		WithArgs("test_user", domain.RoleSupport).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	mock.ExpectExec("delete from user_role").
+		WithArgs("unknown_user", domain.RoleSupport).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectQuery("select").
+		WithArgs("unknown_user").
+		WillReturnError(pgx.ErrNoRows)
+
+	err = storage.RevokeRole(context.Background(), "test_user", domain.RoleSupport)
+	require.NoError(t, err)
+
+	err = storage.RevokeRole(context.Background(), "unknown_user", domain.RoleSupport)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetPasswordReset(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewAuthStorage(mock)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectQuery("select (.+) from password_reset").
+		WithArgs("hash").
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "expires_at"}).AddRow(1, expiresAt))
+
+	mock.ExpectQuery("select (.+) from password_reset").
+		WithArgs("unknown_hash").
+		WillReturnError(pgx.ErrNoRows)
+
+	reset, err := storage.GetPasswordReset(context.Background(), "hash")
+	require.NoError(t, err)
+	require.Equal(t, domain.PasswordReset{Hash: "hash", UserId: 1, ExpiresAt: expiresAt}, reset)
+
+	_, err = storage.GetPasswordReset(context.Background(), "unknown_hash")
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestResetPassword(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewAuthStorage(mock)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("delete from password_reset").
+		WithArgs("hash", "password").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name"}).AddRow(1, "test_user"))
+
+	mock.ExpectQuery("delete from password_reset").
+		WithArgs("hash", "password").
+		WillReturnError(pgx.ErrNoRows)
+
+	user, err := storage.ResetPassword(context.Background(), "hash", "password")
+	require.NoError(t, err)
+	require.Equal(t, domain.User{Id: 1, Name: "test_user"}, user)
+
+	_, err = storage.ResetPassword(context.Background(), "hash", "password")
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
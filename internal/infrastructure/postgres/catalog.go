@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func (shopStorage *ShopStorage) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	rows, err := shopStorage.pool.Query(ctx, `
+		select name, price, available
+		from product
+		order by name;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	products := make([]domain.Product, 0)
+	for rows.Next() {
+		var product domain.Product
+
+		err = rows.Scan(&product.Name, &product.Price, &product.Available)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.GetProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return products, nil
+}
+
+func (shopStorage *ShopStorage) GetProduct(ctx context.Context, name string) (domain.Product, error) {
+	var product domain.Product
+
+	err := shopStorage.pool.QueryRow(ctx, `
+		select name, price, available
+		from product
+		where name = $1;
+	`, name).Scan(&product.Name, &product.Price, &product.Available)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Product{}, fmt.Errorf("%w (postgres.GetProduct): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return domain.Product{}, fmt.Errorf("%w (postgres.GetProduct): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return product, nil
+}
+
+func (shopStorage *ShopStorage) CreateProduct(
+	ctx context.Context,
+	actor string,
+	product domain.Product) (domain.Product, error) {
+	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("%w (postgres.CreateProduct): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.CreateProduct): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	var productId int
+	err = tx.QueryRow(ctx, `
+		insert into product(name, price, available)
+		values ($1, $2, $3)
+		returning id;
+	`, product.Name, product.Price, product.Available).Scan(&productId)
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("(postgres.CreateProduct): %w", convertProductError(err))
+	}
+
+	err = auditProductChange(ctx, tx, productId, domain.ProductChange{
+		Actor:  actor,
+		Action: domain.ProductActionCreate,
+		After:  product,
+	})
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("(postgres.CreateProduct): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("%w (postgres.CreateProduct): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return product, nil
+}
+
+func (shopStorage *ShopStorage) UpdateProduct(
+	ctx context.Context,
+	name string,
+	update domain.ProductUpdate) (domain.Product, error) {
+	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("%w (postgres.UpdateProduct): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.UpdateProduct): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	var (
+		productId int
+		before    domain.Product
+	)
+	err = tx.QueryRow(ctx, `
+		select id, name, price, available
+		from product
+		where name = $1
+		for update;
+	`, name).Scan(&productId, &before.Name, &before.Price, &before.Available)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Product{}, fmt.Errorf("%w (postgres.UpdateProduct): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return domain.Product{}, fmt.Errorf("%w (postgres.UpdateProduct): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	after := update.Apply(before)
+
+	_, err = tx.Exec(ctx, `
+		update product
+		set name = $1, price = $2, available = $3
+		where id = $4;
+	`, after.Name, after.Price, after.Available, productId)
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("(postgres.UpdateProduct): %w", convertProductError(err))
+	}
+
+	err = auditProductChange(ctx, tx, productId, domain.ProductChange{
+		Actor:  update.Actor,
+		Action: update.Action,
+		Before: &before,
+		After:  after,
+	})
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("(postgres.UpdateProduct): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Product{}, fmt.Errorf("%w (postgres.UpdateProduct): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return after, nil
+}
+
+func (shopStorage *ShopStorage) GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error) {
+	var productId int
+	err := shopStorage.pool.QueryRow(ctx, `
+		select id
+		from product
+		where name = $1;
+	`, name).Scan(&productId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w (postgres.GetProductAudit): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return nil, fmt.Errorf("%w (postgres.GetProductAudit): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	rows, err := shopStorage.pool.Query(ctx, `
+		select actor, action, before, after, changed_at
+		from product_audit
+		where product_id = $1
+		order by changed_at desc, id desc;
+	`, productId)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetProductAudit): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	changes := make([]domain.ProductChange, 0)
+	for rows.Next() {
+		var change domain.ProductChange
+
+		err = rows.Scan(&change.Actor, &change.Action, &change.Before, &change.After, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.GetProductAudit): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetProductAudit): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return changes, nil
+}
+
+func auditProductChange(ctx context.Context, tx pgx.Tx, productId int, change domain.ProductChange) error {
+	_, err := tx.Exec(ctx, `
+		insert into product_audit(product_id, actor, action, before, after)
+		values ($1, $2, $3, $4, $5);
+	`, productId, change.Actor, change.Action, change.Before, change.After)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.auditProductChange): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+func convertProductError(err error) error {
+	switch {
+	case isUniqueViolation(err):
+		return fmt.Errorf("%w (postgres.convertProductError): %w", customErrors.ErrAlreadyExists, err)
+	case isCheckViolation(err):
+		return fmt.Errorf("%w (postgres.convertProductError): %w", customErrors.ErrDataNotValid, err)
+	default:
+		return fmt.Errorf("%w (postgres.convertProductError): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+}
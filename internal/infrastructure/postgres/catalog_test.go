@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestGetProducts(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	mockRows := pgxmock.NewRows([]string{"name", "price", "available"}).
+		AddRow("cup", 20, true).
+		AddRow("pen", 10, false)
+
+	mock.ExpectQuery("select").
+		WillReturnRows(mockRows)
+
+	products, err := storage.GetProducts(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.Product{
+		{Name: "cup", Price: 20, Available: true},
+		{Name: "pen", Price: 10, Available: false},
+	}, products)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetProduct(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	mockRows := pgxmock.NewRows([]string{"name", "price", "available"}).AddRow("cup", 20, true)
+
+	mock.ExpectQuery("select").
+		WithArgs("cup").
+		WillReturnRows(mockRows)
+
+	mock.ExpectQuery("select").
+		WithArgs("unknown").
+		WillReturnError(pgx.ErrNoRows)
+
+	product, err := storage.GetProduct(context.Background(), "cup")
+	require.NoError(t, err)
+	require.Equal(t, domain.Product{Name: "cup", Price: 20, Available: true}, product)
+
+	_, err = storage.GetProduct(context.Background(), "unknown")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestCreateProduct(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	mug := domain.Product{Name: "mug", Price: 30, Available: true}
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("insert into product").
+		WithArgs(mug.Name, mug.Price, mug.Available).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("insert into product_audit").
+		WithArgs(7, "admin", domain.ProductActionCreate, (*domain.Product)(nil), mug).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("insert into product").
+		WithArgs(mug.Name, mug.Price, mug.Available).
+		WillReturnError(&pgconn.PgError{Code: uniqueViolationCode})
+	mock.ExpectRollback()
+
+	product, err := storage.CreateProduct(context.Background(), "admin", mug)
+	require.NoError(t, err)
+	require.Equal(t, mug, product)
+
+	_, err = storage.CreateProduct(context.Background(), "admin", mug)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestUpdateProduct(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	price := 40
+	update := domain.ProductUpdate{Actor: "admin", Action: domain.ProductActionReprice, Price: &price}
+	before := domain.Product{Name: "mug", Price: 30, Available: true}
+	after := domain.Product{Name: "mug", Price: 40, Available: true}
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("select").
+		WithArgs("mug").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "price", "available"}).AddRow(7, "mug", 30, true))
+	mock.ExpectExec("update product").
+		WithArgs(after.Name, after.Price, after.Available, 7).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("insert into product_audit").
+		WithArgs(7, "admin", domain.ProductActionReprice, &before, after).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("select").
+		WithArgs("unknown").
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	product, err := storage.UpdateProduct(context.Background(), "mug", update)
+	require.NoError(t, err)
+	require.Equal(t, after, product)
+
+	_, err = storage.UpdateProduct(context.Background(), "unknown", update)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/storagetest"
+)
+
+func TestConformancePostgres(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), storagetest.PostgresDSN(t))
+	require.NoError(t, err)
+	defer pool.Close()
+
+	authStorage, err := NewAuthStorage(pool)
+	require.NoError(t, err)
+	shopStorage, err := NewShopStorage(pool)
+	require.NoError(t, err)
+	ledgerStorage, err := NewLedgerStorage(pool)
+	require.NoError(t, err)
+	tradeStorage, err := NewTradeStorage(pool)
+	require.NoError(t, err)
+
+	storagetest.Run(t, storagetest.Storages{
+		Auth:     authStorage,
+		Shop:     shopStorage,
+		Products: shopStorage,
+		Ledger:   ledgerStorage,
+		Trades:   tradeStorage,
+	})
+}
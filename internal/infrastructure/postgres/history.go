@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func (shopStorage *ShopStorage) GetHistory(
+	ctx context.Context,
+	username string,
+	filter domain.HistoryFilter) (domain.HistoryPage, error) {
+	var userId int
+	err := shopStorage.pool.QueryRow(ctx, `
+		select id
+		from users
+		where name = $1;
+	`, username).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.HistoryPage{}, fmt.Errorf("%w (postgres.GetHistory): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return domain.HistoryPage{}, fmt.Errorf("%w (postgres.GetHistory): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	query, args := historyQuery(userId, filter)
+	rows, err := shopStorage.pool.Query(ctx, query, args...)
+	if err != nil {
+		return domain.HistoryPage{}, fmt.Errorf("%w (postgres.GetHistory): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	page := domain.HistoryPage{
+		Entries: make([]domain.HistoryEntry, 0, filter.Limit),
+	}
+	for rows.Next() {
+		var entry domain.HistoryEntry
+
+		err = rows.Scan(&entry.Id, &entry.Direction, &entry.Counterparty, &entry.Amount, &entry.SentAt, &entry.TradeId)
+		if err != nil {
+			return domain.HistoryPage{}, fmt.Errorf("%w (postgres.GetHistory): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		page.Entries = append(page.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return domain.HistoryPage{}, fmt.Errorf("%w (postgres.GetHistory): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	// one extra row is fetched to find out whether there is a next page
+	if len(page.Entries) > filter.Limit {
+		page.Entries = page.Entries[:filter.Limit]
+		last := page.Entries[len(page.Entries)-1]
+		page.NextCursor = domain.HistoryCursor{SentAt: last.SentAt, Id: last.Id}.Encode()
+	}
+
+	return page, nil
+}
+
+// historyQuery builds a separate keyset query for each direction, so that each of them
+// is served by the (user_from, sent_at) or (user_to, sent_at) index.
+func historyQuery(userId int, filter domain.HistoryFilter) (string, []any) {
+	args := []any{userId}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conditions := make([]string, 0)
+	if filter.Counterparty != "" {
+		conditions = append(conditions, "u.name = "+arg(filter.Counterparty))
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "ut.money >= "+arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "ut.money <= "+arg(*filter.MaxAmount))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "ut.sent_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "ut.sent_at < "+arg(filter.To))
+	}
+	if filter.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(ut.sent_at, ut.id) < (%s, %s)",
+			arg(filter.Cursor.SentAt), arg(filter.Cursor.Id)))
+	}
+	limit := arg(filter.Limit + 1)
+
+	subquery := func(direction string, userColumn string, counterpartyColumn string, extra ...string) string {
+		where := append([]string{"ut." + userColumn + " = $1"}, conditions...)
+		where = append(where, extra...)
+
+		return fmt.Sprintf(`
+			select ut.id, '%s', u.name, ut.money, ut.sent_at, ut.trade_id
+			from user_transaction ut
+			join users u on u.id = ut.%s
+			where %s
+			order by ut.sent_at desc, ut.id desc
+			limit %s`, direction, counterpartyColumn, strings.Join(where, " and "), limit)
+	}
+
+	switch filter.Direction {
+	case domain.HistoryDirectionSent:
+		return subquery(domain.HistoryDirectionSent, "user_from", "user_to") + ";", args
+	case domain.HistoryDirectionReceived:
+		return subquery(domain.HistoryDirectionReceived, "user_to", "user_from") + ";", args
+	default:
+		// transfers to oneself are listed once, as sent
+		return fmt.Sprintf(`
+			select *
+			from ((%s) union all (%s)) history
+			order by sent_at desc, id desc
+			limit %s;
+		`,
+			subquery(domain.HistoryDirectionSent, "user_from", "user_to"),
+			subquery(domain.HistoryDirectionReceived, "user_to", "user_from", "ut.user_from <> $1"),
+			limit), args
+	}
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+func TestGetHistory(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	userName := "test_user"
+	userId := 1
+	sentAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	minAmount := 10
+
+	mock.ExpectQuery("select").
+		WithArgs(userName).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(userId))
+
+	tradeId := int64(5)
+	mockRows := pgxmock.NewRows([]string{"id", "direction", "name", "money", "sent_at", "trade_id"}).
+		AddRow(int64(3), domain.HistoryDirectionSent, "test_2_user", 30, sentAt, &tradeId).
+		AddRow(int64(2), domain.HistoryDirectionReceived, "test_2_user", 20, sentAt, nil).
+		AddRow(int64(1), domain.HistoryDirectionSent, "test_3_user", 10, sentAt, nil)
+
+	mock.ExpectQuery("union all").
+		WithArgs(userId, minAmount, 3).
+		WillReturnRows(mockRows)
+
+	page, err := storage.GetHistory(context.Background(), userName, domain.HistoryFilter{
+		MinAmount: &minAmount,
+		Limit:     2,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	require.Equal(t, &tradeId, page.Entries[0].TradeId)
+	require.Nil(t, page.Entries[1].TradeId)
+	require.Equal(t, domain.HistoryCursor{SentAt: sentAt, Id: 2}.Encode(), page.NextCursor)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type KeyStorage struct {
+	pool PgxPool
+}
+
+func NewKeyStorage(pool PgxPool) (*KeyStorage, error) {
+	return &KeyStorage{
+		pool: pool,
+	}, nil
+}
+
+func (keyStorage *KeyStorage) GetKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys := make([]domain.SigningKey, 0)
+	rows, err := keyStorage.pool.Query(ctx, `
+		select kid, secret, active, retired_at is not null, created_at
+		from jwt_key
+		order by created_at;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetKeys): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key domain.SigningKey
+
+		err = rows.Scan(&key.Id, &key.Secret, &key.Active, &key.Retired, &key.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.GetKeys): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetKeys): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return keys, nil
+}
+
+func (keyStorage *KeyStorage) AddActiveKey(ctx context.Context, key domain.SigningKey) error {
+	tx, err := keyStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("%w (postgres.AddActiveKey): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.AddActiveKey): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		update jwt_key
+		set active = false
+		where active;
+	`)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.AddActiveKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into jwt_key(kid, secret, active, created_at)
+		values ($1, $2, true, $3);
+	`, key.Id, key.Secret, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.AddActiveKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.AddActiveKey): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return nil
+}
+
+// AddInitialKey stores the key as active only if there is no active key yet.
+// The unique index on active keys makes concurrent callers wait for each
+// other, and all but the first skip the insert.
+func (keyStorage *KeyStorage) AddInitialKey(ctx context.Context, key domain.SigningKey) (bool, error) {
+	tag, err := keyStorage.pool.Exec(ctx, `
+		insert into jwt_key(kid, secret, active, created_at)
+		values ($1, $2, true, $3)
+		on conflict do nothing;
+	`, key.Id, key.Secret, key.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.AddInitialKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (keyStorage *KeyStorage) RetireKey(ctx context.Context, kid string) error {
+	tag, err := keyStorage.pool.Exec(ctx, `
+		update jwt_key
+		set retired_at = now()
+		where kid = $1 and not active and retired_at is null;
+	`, kid)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RetireKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w (postgres.RetireKey): %s", customErrors.ErrDoesNotExist, kid)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestGetKeys(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewKeyStorage(mock)
+	require.NoError(t, err)
+
+	createdAt := time.Now()
+
+	mockRows := pgxmock.NewRows([]string{"kid", "secret", "active", "retired", "created_at"}).
+		AddRow("old_kid", []byte("old_secret"), false, true, createdAt).
+		AddRow("new_kid", []byte("new_secret"), true, false, createdAt)
+
+	mock.ExpectQuery("select").
+		WillReturnRows(mockRows)
+
+	keys, err := storage.GetKeys(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.SigningKey{
+		{Id: "old_kid", Secret: []byte("old_secret"), Active: false, Retired: true, CreatedAt: createdAt},
+		{Id: "new_kid", Secret: []byte("new_secret"), Active: true, Retired: false, CreatedAt: createdAt},
+	}, keys)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestAddActiveKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewKeyStorage(mock)
+	require.NoError(t, err)
+
+	key := domain.SigningKey{
+		Id:        "test_kid",
+		Secret:    []byte("test_secret"),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectExec("update").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	mock.ExpectExec("insert").
+		WithArgs(key.Id, key.Secret, key.CreatedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectCommit()
+
+	err = storage.AddActiveKey(context.Background(), key)
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestAddInitialKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewKeyStorage(mock)
+	require.NoError(t, err)
+
+	key := domain.SigningKey{
+		Id:        "test_kid",
+		Secret:    []byte("test_secret"),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	mock.ExpectExec("insert (.+) on conflict do nothing").
+		WithArgs(key.Id, key.Secret, key.CreatedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	added, err := storage.AddInitialKey(context.Background(), key)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// another replica already stored its key
+	mock.ExpectExec("insert (.+) on conflict do nothing").
+		WithArgs(key.Id, key.Secret, key.CreatedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	added, err = storage.AddInitialKey(context.Background(), key)
+	require.NoError(t, err)
+	require.False(t, added)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestRetireKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewKeyStorage(mock)
+	require.NoError(t, err)
+
+	kid := "test_kid"
+
+	mock.ExpectExec("update").
+		WithArgs(kid).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = storage.RetireKey(context.Background(), kid)
+	require.NoError(t, err)
+
+	kid = "unknown_kid"
+
+	mock.ExpectExec("update").
+		WithArgs(kid).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err = storage.RetireKey(context.Background(), kid)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type LedgerStorage struct {
+	pool PgxPool
+}
+
+func NewLedgerStorage(pool PgxPool) (*LedgerStorage, error) {
+	return &LedgerStorage{
+		pool: pool,
+	}, nil
+}
+
+func (ledgerStorage *LedgerStorage) GetBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	rows, err := ledgerStorage.pool.Query(ctx, `
+		select a.kind, a.user_id, u.name, coalesce(sum(p.amount), 0), u.money
+		from ledger_account a
+		left join users u on u.id = a.user_id
+		left join ledger_posting p on p.account_id = a.id
+		group by a.id, u.id
+		order by a.id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetBalances): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	balances := make([]domain.AccountBalance, 0)
+	for rows.Next() {
+		var (
+			balance  domain.AccountBalance
+			userId   *int
+			userName *string
+		)
+
+		err = rows.Scan(&balance.Account.Kind, &userId, &userName, &balance.Balance, &balance.Cached)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.GetBalances): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		if userId != nil {
+			balance.Account.UserId = *userId
+		}
+		if userName != nil {
+			balance.UserName = *userName
+		}
+
+		balances = append(balances, balance)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetBalances): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return balances, nil
+}
+
+// GetActivity collects the balances of all users in a single query,
+// so that they come from the same snapshot.
+func (ledgerStorage *LedgerStorage) GetActivity(ctx context.Context) ([]domain.AccountActivity, error) {
+	rows, err := ledgerStorage.pool.Query(ctx, `
+		select
+			u.id,
+			u.name,
+			u.money,
+			coalesce((
+				select sum(p.amount)
+				from ledger_posting p
+				join ledger_account a on a.id = p.account_id
+				where a.user_id = u.id
+			), 0),
+			coalesce((select sum(money) from user_transaction where user_to = u.id), 0),
+			coalesce((select sum(money) from user_transaction where user_from = u.id), 0),
+			coalesce((
+				select sum(coalesce(up.price, pr.price))
+				from user_product up
+				left join product pr on pr.id = up.product_id
+				left join gift g on g.purchase_id = up.id
+				where up.refunded_at is null
+					and ((g.purchase_id is null and coalesce(up.bought_for, up.user_id) = u.id) or g.user_from = u.id)
+			), 0),
+			(
+				select count(*)
+				from user_product
+				where user_id = u.id and product_id is null and price is null and refunded_at is null
+			)
+		from users u
+		order by u.id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetActivity): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	activity := make([]domain.AccountActivity, 0)
+	for rows.Next() {
+		account := domain.AccountActivity{
+			Breakdown: domain.BalanceBreakdown{Grant: domain.SignupGrant},
+		}
+
+		err = rows.Scan(
+			&account.UserId,
+			&account.UserName,
+			&account.Cached,
+			&account.Ledger,
+			&account.Breakdown.Received,
+			&account.Breakdown.Sent,
+			&account.Breakdown.Purchases,
+			&account.Breakdown.UnpricedPurchases)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.GetActivity): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		activity = append(activity, account)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetActivity): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return activity, nil
+}
+
+// postLedgerEntry records the entry within the operation transaction and applies
+// its postings to the cached balances of the users. Whether the postings are
+// balanced is checked once more by the database when the transaction commits.
+func postLedgerEntry(ctx context.Context, tx pgx.Tx, entry domain.LedgerEntry) error {
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("(postgres.postLedgerEntry): %w", err)
+	}
+
+	var entryId int64
+	err := tx.QueryRow(ctx, `
+		insert into ledger_entry(kind)
+		values ($1)
+		returning id;
+	`, entry.Kind).Scan(&entryId)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.postLedgerEntry): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	for _, posting := range entry.Postings {
+		var userId *int
+		if posting.Account.Kind == domain.LedgerAccountUser {
+			userId = &posting.Account.UserId
+		}
+
+		tag, err := tx.Exec(ctx, `
+			insert into ledger_posting(entry_id, account_id, amount)
+			select $1, id, $2
+			from ledger_account
+			where kind = $3 and user_id is not distinct from $4;
+		`, entryId, posting.Amount, posting.Account.Kind, userId)
+		if err != nil {
+			return fmt.Errorf("%w (postgres.postLedgerEntry): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+		if tag.RowsAffected() != 1 {
+			return fmt.Errorf("%w (postgres.postLedgerEntry): ledger account %v",
+				customErrors.ErrDoesNotExist, posting.Account)
+		}
+
+		if userId == nil {
+			continue
+		}
+
+		err = updateCoins(ctx, tx, *userId, posting.Amount)
+		if err != nil {
+			return fmt.Errorf("(postgres.postLedgerEntry): %w", err)
+		}
+	}
+
+	return nil
+}
+
+// updateCoins keeps users.money in sync with the ledger, it must be called
+// only for the postings of a ledger entry.
+func updateCoins(ctx context.Context, tx pgx.Tx, userId int, coins int) error {
+	_, err := tx.Exec(ctx, `
+		update users
+		set money = money + $1
+		where id = $2;
+	`, coins, userId)
+	if err != nil {
+		if isCheckViolation(err) {
+			return fmt.Errorf("%w (postgres.updateCoins): %w", customErrors.ErrInsufficientFunds, err)
+		}
+
+		return fmt.Errorf("%w (postgres.updateCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
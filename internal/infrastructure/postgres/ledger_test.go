@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// expectLedgerEntry sets up the queries postLedgerEntry runs for the entry.
+func expectLedgerEntry(mock pgxmock.PgxPoolIface, entryId int64, entry domain.LedgerEntry) {
+	mock.ExpectQuery("insert into ledger_entry").
+		WithArgs(entry.Kind).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(entryId))
+
+	for _, posting := range entry.Postings {
+		var userId *int
+		if posting.Account.Kind == domain.LedgerAccountUser {
+			id := posting.Account.UserId
+			userId = &id
+		}
+
+		mock.ExpectExec("insert into ledger_posting").
+			WithArgs(entryId, posting.Amount, posting.Account.Kind, userId).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+		if userId != nil {
+			mock.ExpectExec("update").
+				WithArgs(posting.Amount, *userId).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		}
+	}
+}
+
+func TestPostLedgerEntry(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+
+	entry := domain.NewLedgerTransfer(domain.LedgerEntryPurchase, domain.UserAccount(1), domain.RevenueAccount, 80)
+	expectLedgerEntry(mock, 1, entry)
+
+	mock.ExpectQuery("insert into ledger_entry").
+		WithArgs(domain.LedgerEntryTransfer).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(2)))
+
+	unknownUserId := 2
+	mock.ExpectExec("insert into ledger_posting").
+		WithArgs(int64(2), -10, domain.LedgerAccountUser, &unknownUserId).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	mock.ExpectRollback()
+
+	tx, err := mock.Begin(ctx)
+	require.NoError(t, err)
+
+	err = postLedgerEntry(ctx, tx, entry)
+	require.NoError(t, err)
+
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryTransfer, domain.UserAccount(unknownUserId), domain.UserAccount(1), 10))
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = postLedgerEntry(ctx, tx, domain.LedgerEntry{
+		Kind:     domain.LedgerEntryAdjustment,
+		Postings: []domain.LedgerPosting{{Account: domain.UserAccount(1), Amount: 10}},
+	})
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+
+	err = tx.Rollback(ctx)
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetBalances(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewLedgerStorage(mock)
+	require.NoError(t, err)
+
+	userId := 1
+	userName := "test_user"
+	userMoney := 920
+
+	mockRows := pgxmock.NewRows([]string{"kind", "user_id", "name", "sum", "money"}).
+		AddRow(domain.LedgerAccountMint, nil, nil, -1000, nil).
+		AddRow(domain.LedgerAccountRevenue, nil, nil, 80, nil).
+		AddRow(domain.LedgerAccountUser, &userId, &userName, 920, &userMoney)
+
+	mock.ExpectQuery("select").
+		WillReturnRows(mockRows)
+
+	balances, err := storage.GetBalances(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.AccountBalance{
+		{Account: domain.MintAccount, Balance: -1000},
+		{Account: domain.RevenueAccount, Balance: 80},
+		{Account: domain.UserAccount(userId), UserName: userName, Balance: 920, Cached: &userMoney},
+	}, balances)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetActivity(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewLedgerStorage(mock)
+	require.NoError(t, err)
+
+	mockRows := pgxmock.NewRows([]string{"id", "name", "money", "ledger", "received", "sent", "purchases", "unpriced"}).
+		AddRow(1, "test_user", 920, 920, 100, 100, 80, 1)
+
+	mock.ExpectQuery("select").
+		WillReturnRows(mockRows)
+
+	activity, err := storage.GetActivity(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []domain.AccountActivity{{
+		UserId:   1,
+		UserName: "test_user",
+		Cached:   920,
+		Ledger:   920,
+		Breakdown: domain.BalanceBreakdown{
+			Grant:             domain.SignupGrant,
+			Received:          100,
+			Sent:              100,
+			Purchases:         80,
+			UnpricedPurchases: 1,
+		},
+	}}, activity)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,329 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationsLockId is the advisory lock key that serializes migrations
+// between replicas starting at the same time.
+const migrationsLockId = 7_320_915_846
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+
+	up   string
+	down string
+}
+
+type Migrator struct {
+	pool       PgxPool
+	migrations []Migration
+}
+
+func NewMigrator(pool PgxPool) (*Migrator, error) {
+	migrations, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.NewMigrator): %w", customErrors.ErrInternal, err)
+	}
+
+	return newMigrator(pool, migrations)
+}
+
+func newMigrator(pool PgxPool, migrationsDir fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(migrationsDir, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.newMigrator): %w", customErrors.ErrInternal, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w (postgres.newMigrator): unexpected file %s",
+				customErrors.ErrDataNotValid, entry.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.newMigrator): %w", customErrors.ErrDataNotValid, err)
+		}
+
+		content, err := fs.ReadFile(migrationsDir, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.newMigrator): %w", customErrors.ErrInternal, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w (postgres.newMigrator): version %d has different names",
+				customErrors.ErrDataNotValid, version)
+		}
+
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("%w (postgres.newMigrator): migration %d must have up and down files",
+				customErrors.ErrDataNotValid, migration.Version)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies all pending migrations in version order and returns the applied ones.
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := make([]Migration, 0)
+	for _, migration := range migrator.migrations {
+		ok, err := migrator.apply(ctx, migration)
+		if err != nil {
+			return applied, fmt.Errorf("(postgres.Up): %w", err)
+		}
+
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Down reverts up to steps most recently applied migrations and returns the reverted ones.
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	reverted := make([]Migration, 0, steps)
+	for i := 0; i < steps; i++ {
+		migration, ok, err := migrator.revertLast(ctx)
+		if err != nil {
+			return reverted, fmt.Errorf("(postgres.Down): %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// Status returns all known migrations, AppliedAt is nil for pending ones.
+func (migrator *Migrator) Status(ctx context.Context) ([]Migration, error) {
+	appliedAt := make(map[int]time.Time)
+	rows, err := migrator.pool.Query(ctx, `
+		select version, applied_at
+		from schema_migrations;
+	`)
+	if err != nil && !isUndefinedTable(err) {
+		return nil, fmt.Errorf("%w (postgres.Status): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if err == nil {
+		defer rows.Close()
+	}
+
+	for err == nil && rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.Status): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		appliedAt[version] = at
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	// the migrations table is created with the first migration
+	if err != nil && !isUndefinedTable(err) {
+		return nil, fmt.Errorf("%w (postgres.Status): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	migrations := make([]Migration, 0, len(migrator.migrations))
+	for _, migration := range migrator.migrations {
+		if at, ok := appliedAt[migration.Version]; ok {
+			migration.AppliedAt = &at
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+func (migrator *Migrator) apply(ctx context.Context, migration Migration) (bool, error) {
+	tx, err := migrator.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.apply): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.apply): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	err = migrator.lock(ctx, tx)
+	if err != nil {
+		return false, fmt.Errorf("(postgres.apply): %w", err)
+	}
+
+	// another replica may have applied the migration while we were waiting for the lock
+	var applied bool
+	err = tx.QueryRow(ctx, `
+		select exists(select from schema_migrations where version = $1);
+	`, migration.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.apply): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if applied {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, migration.up)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.apply): migration %d: %w",
+			customErrors.ErrFailedToExecuteQuery, migration.Version, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into schema_migrations(version, name)
+		values ($1, $2);
+	`, migration.Version, migration.Name)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.apply): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.apply): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return true, nil
+}
+
+func (migrator *Migrator) revertLast(ctx context.Context) (Migration, bool, error) {
+	tx, err := migrator.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.revertLast): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	err = migrator.lock(ctx, tx)
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("(postgres.revertLast): %w", err)
+	}
+
+	var version int
+	err = tx.QueryRow(ctx, `
+		select version
+		from schema_migrations
+		order by version desc
+		limit 1;
+	`).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Migration{}, false, nil
+		}
+
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	index := sort.Search(len(migrator.migrations), func(i int) bool {
+		return migrator.migrations[i].Version >= version
+	})
+	if index == len(migrator.migrations) || migrator.migrations[index].Version != version {
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): unknown migration %d",
+			customErrors.ErrDoesNotExist, version)
+	}
+	migration := migrator.migrations[index]
+
+	_, err = tx.Exec(ctx, migration.down)
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): migration %d: %w",
+			customErrors.ErrFailedToExecuteQuery, migration.Version, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		delete from schema_migrations
+		where version = $1;
+	`, migration.Version)
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("%w (postgres.revertLast): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return migration, true, nil
+}
+
+// lock takes the migrations advisory lock until the end of the transaction
+// and makes sure the migrations table exists.
+func (migrator *Migrator) lock(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `
+		select pg_advisory_xact_lock($1);
+	`, int64(migrationsLockId))
+	if err != nil {
+		return fmt.Errorf("%w (postgres.lock): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		create table if not exists schema_migrations (
+			version integer primary key,
+			name text not null,
+			applied_at timestamp default now() not null
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.lock): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_users.up.sql":     {Data: []byte("create table users();")},
+	"0001_users.down.sql":   {Data: []byte("drop table users;")},
+	"0002_product.up.sql":   {Data: []byte("create table product();")},
+	"0002_product.down.sql": {Data: []byte("drop table product;")},
+}
+
+func expectMigrationLock(mock pgxmock.PgxPoolIface) {
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectExec("pg_advisory_xact_lock").
+		WithArgs(int64(migrationsLockId)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectExec("create table if not exists schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrator.migrations)
+
+	for i, migration := range migrator.migrations {
+		require.Equal(t, i+1, migration.Version)
+	}
+}
+
+func TestNewMigratorErrors(t *testing.T) {
+	testData := []struct {
+		TestName string
+		Files    fstest.MapFS
+	}{
+		{
+			"unexpected file",
+			fstest.MapFS{"readme.md": {}},
+		},
+		{
+			"missing down file",
+			fstest.MapFS{"0001_users.up.sql": {Data: []byte("create table users();")}},
+		},
+		{
+			"different names",
+			fstest.MapFS{
+				"0001_users.up.sql":    {Data: []byte("create table users();")},
+				"0001_people.down.sql": {Data: []byte("drop table users;")},
+			},
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			_, err := newMigrator(nil, testCase.Files)
+			require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+		})
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	migrator, err := newMigrator(mock, testMigrations)
+	require.NoError(t, err)
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("select exists").
+		WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("select exists").
+		WithArgs(2).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("create table product").
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec("insert into schema_migrations").
+		WithArgs(2, "product").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, 2, applied[0].Version)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestMigrateDown(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	migrator, err := newMigrator(mock, testMigrations)
+	require.NoError(t, err)
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("select version").
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectExec("drop table product").
+		WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec("delete from schema_migrations").
+		WithArgs(2).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+
+	expectMigrationLock(mock)
+	mock.ExpectQuery("select version").
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	reverted, err := migrator.Down(context.Background(), 5)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, 2, reverted[0].Version)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestMigrateStatus(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	migrator, err := newMigrator(mock, testMigrations)
+	require.NoError(t, err)
+
+	appliedAt := time.Now()
+
+	mock.ExpectQuery("select version, applied_at").
+		WillReturnRows(pgxmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	migrations, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, &appliedAt, migrations[0].AppliedAt)
+	require.Nil(t, migrations[1].AppliedAt)
+
+	mock.ExpectQuery("select version, applied_at").
+		WillReturnError(&pgconn.PgError{Code: undefinedTableCode})
+
+	migrations, err = migrator.Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Nil(t, migrations[0].AppliedAt)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
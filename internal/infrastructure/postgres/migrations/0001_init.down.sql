@@ -0,0 +1,4 @@
+drop table if exists user_product;
+drop table if exists user_transaction;
+drop table if exists product;
+drop table if exists users;
@@ -0,0 +1,9 @@
+create table if not exists jwt_key (
+    kid text primary key,
+    secret bytea not null,
+    active boolean default false not null,
+    created_at timestamp default now() not null,
+    retired_at timestamp
+);
+
+create unique index if not exists jwt_key_active on jwt_key(active) where active;
@@ -0,0 +1,9 @@
+create table if not exists idempotency_key (
+    user_id integer not null,
+    key text not null,
+    request_hash text not null,
+    created_at timestamptz default now() not null,
+    expires_at timestamptz not null,
+    primary key (user_id, key),
+    foreign key (user_id) references users(id) on delete cascade
+);
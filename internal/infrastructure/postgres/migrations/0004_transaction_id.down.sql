@@ -0,0 +1 @@
+alter table user_transaction drop column if exists id;
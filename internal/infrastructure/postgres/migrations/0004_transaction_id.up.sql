@@ -0,0 +1,2 @@
+alter table user_transaction
+    add column if not exists id bigint generated always as identity primary key;
@@ -0,0 +1,6 @@
+alter table users alter column money set default 1000;
+
+drop table if exists ledger_posting;
+drop function if exists ledger_entry_balanced();
+drop table if exists ledger_entry;
+drop table if exists ledger_account;
@@ -0,0 +1,73 @@
+create table if not exists ledger_account (
+    id integer primary key generated always as identity,
+    kind text check(kind in ('user', 'revenue', 'mint')) not null,
+    user_id integer unique,
+    foreign key (user_id) references users(id) on delete set null
+);
+
+create unique index if not exists ledger_account_system on ledger_account(kind) where kind <> 'user';
+
+create table if not exists ledger_entry (
+    id bigint primary key generated always as identity,
+    kind text check(kind in ('opening', 'signup', 'transfer', 'purchase', 'adjustment')) not null,
+    created_at timestamptz default now() not null
+);
+
+create table if not exists ledger_posting (
+    id bigint primary key generated always as identity,
+    entry_id bigint not null,
+    account_id integer not null,
+    amount integer not null,
+    foreign key (entry_id) references ledger_entry(id),
+    foreign key (account_id) references ledger_account(id)
+);
+
+create index if not exists ledger_posting_entry on ledger_posting(entry_id);
+create index if not exists ledger_posting_account on ledger_posting(account_id);
+
+-- checked at commit, when all postings of an entry are inserted
+create or replace function ledger_entry_balanced() returns trigger as $$
+begin
+    if (select sum(amount) from ledger_posting where entry_id = new.entry_id) <> 0 then
+        raise exception 'ledger entry % is not balanced', new.entry_id using errcode = 'check_violation';
+    end if;
+
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists ledger_posting_balanced on ledger_posting;
+create constraint trigger ledger_posting_balanced
+    after insert or update on ledger_posting
+    deferrable initially deferred
+    for each row execute function ledger_entry_balanced();
+
+insert into ledger_account(kind)
+select kind
+from (values ('mint'), ('revenue')) as system(kind)
+where not exists (select from ledger_account a where a.kind = system.kind);
+
+insert into ledger_account(kind, user_id)
+select 'user', id
+from users
+where not exists (select from ledger_account a where a.user_id = users.id);
+
+-- the ledger starts from the current balances, the earlier movements
+-- are not recorded in a balanced form
+with entry as (
+    insert into ledger_entry(kind)
+    select 'opening'
+    where exists (select from users where money <> 0)
+    returning id
+)
+insert into ledger_posting(entry_id, account_id, amount)
+select entry.id, a.id, u.money
+from entry, users u
+join ledger_account a on a.user_id = u.id
+where u.money <> 0
+union all
+select entry.id, a.id, -(select sum(money) from users)
+from entry, ledger_account a
+where a.kind = 'mint';
+
+alter table users alter column money set default 0;
@@ -0,0 +1,3 @@
+drop index if exists product_name_unique;
+
+alter table product drop column if exists available;
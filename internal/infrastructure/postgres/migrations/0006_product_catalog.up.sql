@@ -0,0 +1,3 @@
+alter table product add column if not exists available boolean default true not null;
+
+create unique index if not exists product_name_unique on product(name);
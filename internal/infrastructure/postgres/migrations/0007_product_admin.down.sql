@@ -0,0 +1,6 @@
+drop table if exists product_audit;
+
+alter table user_product drop constraint if exists user_product_product_id_fkey;
+alter table user_product
+    add constraint user_product_product_id_fkey
+    foreign key (product_id) references product(id) on delete set null;
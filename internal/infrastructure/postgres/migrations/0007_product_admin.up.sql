@@ -0,0 +1,18 @@
+-- products are archived instead of deleted, so that purchases keep their product
+alter table user_product drop constraint if exists user_product_product_id_fkey;
+alter table user_product
+    add constraint user_product_product_id_fkey
+    foreign key (product_id) references product(id) on delete restrict;
+
+create table if not exists product_audit (
+    id bigint primary key generated always as identity,
+    product_id integer not null,
+    actor text not null,
+    action text check(action in ('create', 'update', 'archive', 'reprice')) not null,
+    before jsonb,
+    after jsonb not null,
+    changed_at timestamptz default now() not null,
+    foreign key (product_id) references product(id) on delete cascade
+);
+
+create index if not exists product_audit_product_time on product_audit(product_id, changed_at);
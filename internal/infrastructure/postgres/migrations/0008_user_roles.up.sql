@@ -0,0 +1,7 @@
+create table if not exists user_role (
+    user_id integer not null,
+    role text check(role in ('admin', 'support', 'service')) not null,
+    granted_at timestamptz default now() not null,
+    primary key (user_id, role),
+    foreign key (user_id) references users(id) on delete cascade
+);
@@ -0,0 +1,2 @@
+drop table if exists session_revocation;
+drop table if exists revoked_token;
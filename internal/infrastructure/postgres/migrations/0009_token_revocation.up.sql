@@ -0,0 +1,16 @@
+create table if not exists revoked_token (
+    id text primary key,
+    user_id integer not null,
+    expires_at timestamptz not null,
+    revoked_at timestamptz default now() not null,
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists revoked_token_expires_at on revoked_token(expires_at);
+
+-- tokens of the user issued before revoked_before are revoked
+create table if not exists session_revocation (
+    user_id integer primary key,
+    revoked_before timestamptz not null,
+    foreign key (user_id) references users(id) on delete cascade
+);
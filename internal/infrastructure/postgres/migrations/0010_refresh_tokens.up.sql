@@ -0,0 +1,15 @@
+create table if not exists refresh_token (
+    id bigint primary key generated always as identity,
+    token_hash text unique not null,
+    family_id text not null,
+    user_id integer not null,
+    expires_at timestamptz not null,
+    created_at timestamptz default now() not null,
+    rotated_at timestamptz,
+    revoked_at timestamptz,
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists refresh_token_family on refresh_token(family_id);
+create index if not exists refresh_token_user on refresh_token(user_id);
+create index if not exists refresh_token_expires_at on refresh_token(expires_at);
@@ -0,0 +1,10 @@
+create table if not exists password_reset (
+    token_hash text primary key,
+    user_id integer not null,
+    expires_at timestamptz not null,
+    created_at timestamptz default now() not null,
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists password_reset_user on password_reset(user_id);
+create index if not exists password_reset_expires_at on password_reset(expires_at);
@@ -0,0 +1 @@
+alter table idempotency_key drop column if exists response;
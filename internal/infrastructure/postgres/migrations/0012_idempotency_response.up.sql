@@ -0,0 +1 @@
+alter table idempotency_key add column if not exists response jsonb;
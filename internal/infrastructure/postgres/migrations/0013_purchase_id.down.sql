@@ -0,0 +1 @@
+alter table user_product drop column if exists id;
@@ -0,0 +1 @@
+alter table user_product add column if not exists id bigint generated always as identity primary key;
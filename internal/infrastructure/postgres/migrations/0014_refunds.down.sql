@@ -0,0 +1,7 @@
+alter table ledger_entry drop constraint if exists ledger_entry_kind_check;
+alter table ledger_entry
+    add constraint ledger_entry_kind_check
+    check(kind in ('opening', 'signup', 'transfer', 'purchase', 'adjustment'));
+
+alter table user_product drop column if exists refunded_at;
+alter table user_product drop column if exists price;
@@ -0,0 +1,8 @@
+-- the price paid is refunded, the purchases made before it was recorded have none
+alter table user_product add column if not exists price integer check(price >= 0);
+alter table user_product add column if not exists refunded_at timestamptz;
+
+alter table ledger_entry drop constraint if exists ledger_entry_kind_check;
+alter table ledger_entry
+    add constraint ledger_entry_kind_check
+    check(kind in ('opening', 'signup', 'transfer', 'purchase', 'adjustment', 'refund'));
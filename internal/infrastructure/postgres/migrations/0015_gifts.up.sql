@@ -0,0 +1,9 @@
+-- the gifted item is a purchase in the inventory of the recipient paid by user_from
+create table if not exists gift (
+    purchase_id bigint primary key,
+    user_from integer,
+    foreign key (purchase_id) references user_product(id) on delete cascade,
+    foreign key (user_from) references users(id) on delete set null
+);
+
+create index if not exists gift_user_from on gift(user_from);
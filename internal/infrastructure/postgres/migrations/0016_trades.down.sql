@@ -0,0 +1,5 @@
+drop table if exists trade_item;
+drop table if exists trade;
+
+alter table user_product drop constraint if exists user_product_bought_for_fkey;
+alter table user_product drop column if exists bought_for;
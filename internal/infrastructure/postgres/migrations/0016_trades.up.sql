@@ -0,0 +1,35 @@
+-- the user the item was bought or gifted for, set once the item changes hands in a trade
+alter table user_product add column if not exists bought_for integer;
+alter table user_product drop constraint if exists user_product_bought_for_fkey;
+alter table user_product
+    add constraint user_product_bought_for_fkey
+    foreign key (bought_for) references users(id) on delete set null;
+
+-- the status of a pending trade past expires_at is reported as expired, it is never stored
+create table if not exists trade (
+    id bigint generated always as identity primary key,
+    user_from integer not null,
+    user_to integer not null,
+    offered_coins integer check(offered_coins >= 0) not null,
+    requested_coins integer check(requested_coins >= 0) not null,
+    status text check(status in ('pending', 'accepted', 'declined')) default 'pending' not null,
+    created_at timestamptz default now() not null,
+    expires_at timestamptz not null,
+    resolved_at timestamptz,
+    foreign key (user_from) references users(id) on delete cascade,
+    foreign key (user_to) references users(id) on delete cascade
+);
+
+create index if not exists trade_user_from_time on trade(user_from, created_at);
+create index if not exists trade_user_to_time on trade(user_to, created_at);
+
+create table if not exists trade_item (
+    trade_id bigint not null,
+    side text check(side in ('offered', 'requested')) not null,
+    product_id integer not null,
+    quantity integer check(quantity >= 1) not null,
+    line integer not null,
+    primary key (trade_id, side, product_id),
+    foreign key (trade_id) references trade(id) on delete cascade,
+    foreign key (product_id) references product(id)
+);
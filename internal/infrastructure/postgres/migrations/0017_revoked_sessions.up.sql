@@ -0,0 +1,10 @@
+-- sessions ended because their refresh token was reused,
+-- every access token carrying the session id is revoked
+create table if not exists revoked_session (
+    id text primary key,
+    user_id integer not null,
+    revoked_at timestamptz default now() not null,
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists revoked_session_revoked_at on revoked_session(revoked_at);
@@ -0,0 +1,2 @@
+alter table user_transaction drop constraint if exists user_transaction_trade_id_fkey;
+alter table user_transaction drop column if exists trade_id;
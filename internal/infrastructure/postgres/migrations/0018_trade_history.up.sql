@@ -0,0 +1,7 @@
+-- the trade a transfer is one side of, every side of an accepted trade that gives
+-- coins or items is recorded, so that item-only trades show up in the history too
+alter table user_transaction add column if not exists trade_id bigint;
+alter table user_transaction drop constraint if exists user_transaction_trade_id_fkey;
+alter table user_transaction
+    add constraint user_transaction_trade_id_fkey
+    foreign key (trade_id) references trade(id) on delete set null;
@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// sessionsCleanupLockId is the advisory lock key that lets one replica
+// at a time delete the expired tokens.
+const sessionsCleanupLockId = 7_320_915_847
+
+type SessionStorage struct {
+	pool PgxPool
+}
+
+func NewSessionStorage(pool PgxPool) (*SessionStorage, error) {
+	return &SessionStorage{
+		pool: pool,
+	}, nil
+}
+
+func (sessionStorage *SessionStorage) RevokeToken(ctx context.Context, token domain.RevokedToken) error {
+	_, err := sessionStorage.pool.Exec(ctx, `
+		insert into revoked_token(id, user_id, expires_at)
+		values ($1, $2, $3)
+		on conflict (id) do nothing;
+	`, token.Id, token.UserId, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeToken): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+// RevokeUserTokens never moves the cutoff of the user back,
+// the refresh tokens of the user are revoked as well.
+func (sessionStorage *SessionStorage) RevokeUserTokens(ctx context.Context, userId int, before time.Time) error {
+	tx, err := sessionStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeUserTokens): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.RevokeUserTokens): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	_, err = tx.Exec(ctx, `
+		insert into session_revocation(user_id, revoked_before)
+		values ($1, $2)
+		on conflict (user_id) do update
+		set revoked_before = greatest(session_revocation.revoked_before, excluded.revoked_before);
+	`, userId, before)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeUserTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		update refresh_token
+		set revoked_at = now()
+		where user_id = $1 and revoked_at is null;
+	`, userId)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeUserTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeUserTokens): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return nil
+}
+
+func (sessionStorage *SessionStorage) GetRevocations(ctx context.Context) (domain.Revocations, error) {
+	revocations := domain.Revocations{
+		Tokens:   make(map[string]time.Time),
+		Cutoffs:  make(map[int]time.Time),
+		Sessions: make(map[string]time.Time),
+	}
+
+	rows, err := sessionStorage.pool.Query(ctx, `
+		select id, expires_at
+		from revoked_token
+		where expires_at > now();
+	`)
+	if err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tokenId   string
+			expiresAt time.Time
+		)
+
+		err = rows.Scan(&tokenId, &expiresAt)
+		if err != nil {
+			return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+				customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		revocations.Tokens[tokenId] = expiresAt
+	}
+	if err = rows.Err(); err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	rows, err = sessionStorage.pool.Query(ctx, `
+		select user_id, revoked_before
+		from session_revocation;
+	`)
+	if err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			userId int
+			cutoff time.Time
+		)
+
+		err = rows.Scan(&userId, &cutoff)
+		if err != nil {
+			return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+				customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		revocations.Cutoffs[userId] = cutoff
+	}
+	if err = rows.Err(); err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	rows, err = sessionStorage.pool.Query(ctx, `
+		select id, revoked_at
+		from revoked_session;
+	`)
+	if err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sessionId string
+			revokedAt time.Time
+		)
+
+		err = rows.Scan(&sessionId, &revokedAt)
+		if err != nil {
+			return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+				customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		revocations.Sessions[sessionId] = revokedAt
+	}
+	if err = rows.Err(); err != nil {
+		return domain.Revocations{}, fmt.Errorf("%w (postgres.GetRevocations): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return revocations, nil
+}
+
+func (sessionStorage *SessionStorage) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	_, err := sessionStorage.pool.Exec(ctx, `
+		insert into refresh_token(token_hash, family_id, user_id, expires_at)
+		values ($1, $2, $3, $4);
+	`, token.Hash, token.FamilyId, token.UserId, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.CreateRefreshToken): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken exchanges the token with the given hash for the next one
+// of the same family. Presenting an already rotated token means that it was
+// stolen, so the whole family is revoked.
+func (sessionStorage *SessionStorage) RotateRefreshToken(
+	ctx context.Context,
+	hash string,
+	next domain.RefreshToken) (domain.RefreshToken, error) {
+	tx, err := sessionStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.RotateRefreshToken): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	var (
+		tokenId int64
+		usable  bool
+		rotated bool
+	)
+	err = tx.QueryRow(ctx, `
+		select t.id, t.family_id, t.user_id, u.name,
+			t.revoked_at is null and t.expires_at > now(),
+			t.rotated_at is not null
+		from refresh_token t
+		join users u on u.id = t.user_id
+		where t.token_hash = $1
+		for update of t;
+	`, hash).Scan(&tokenId, &next.FamilyId, &next.UserId, &next.UserName, &usable, &rotated)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): unknown refresh token",
+				customErrors.ErrUnauthenticated)
+		}
+
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	if !usable {
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): refresh token expired or revoked",
+			customErrors.ErrUnauthenticated)
+	}
+
+	if rotated {
+		_, err = tx.Exec(ctx, `
+			update refresh_token
+			set revoked_at = now()
+			where family_id = $1 and revoked_at is null;
+		`, next.FamilyId)
+		if err != nil {
+			return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+				customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		// the access tokens already issued to the session are revoked too
+		_, err = tx.Exec(ctx, `
+			insert into revoked_session(id, user_id)
+			values ($1, $2)
+			on conflict (id) do update
+			set revoked_at = now();
+		`, next.FamilyId, next.UserId)
+		if err != nil {
+			return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+				customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		err = tx.Commit(ctx)
+		if err != nil {
+			return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+				customErrors.ErrFailedToCommitTx, err)
+		}
+
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrUnauthenticated, customErrors.ErrRefreshTokenReused)
+	}
+
+	_, err = tx.Exec(ctx, `
+		update refresh_token
+		set rotated_at = now()
+		where id = $1;
+	`, tokenId)
+	if err != nil {
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into refresh_token(token_hash, family_id, user_id, expires_at)
+		values ($1, $2, $3, $4);
+	`, next.Hash, next.FamilyId, next.UserId, next.ExpiresAt)
+	if err != nil {
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.RefreshToken{}, fmt.Errorf("%w (postgres.RotateRefreshToken): %w",
+			customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return next, nil
+}
+
+func (sessionStorage *SessionStorage) RevokeRefreshFamily(ctx context.Context, familyId string) error {
+	_, err := sessionStorage.pool.Exec(ctx, `
+		update refresh_token
+		set revoked_at = now()
+		where family_id = $1 and revoked_at is null;
+	`, familyId)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.RevokeRefreshFamily): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredTokens removes the revoked and refresh tokens that would be rejected anyway
+// and the cutoffs and ended sessions older than cutoffsBefore. Every replica calls it, so it does nothing
+// while another replica holds the cleanup lock.
+func (sessionStorage *SessionStorage) DeleteExpiredTokens(ctx context.Context, cutoffsBefore time.Time) error {
+	tx, err := sessionStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.DeleteExpiredTokens): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	var locked bool
+	err = tx.QueryRow(ctx, `
+		select pg_try_advisory_xact_lock($1);
+	`, int64(sessionsCleanupLockId)).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if !locked {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		delete from revoked_token
+		where expires_at <= now();
+	`)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		delete from refresh_token
+		where expires_at <= now();
+	`)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		delete from session_revocation
+		where revoked_before < $1;
+	`, cutoffsBefore)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		delete from revoked_session
+		where revoked_at < $1;
+	`, cutoffsBefore)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.DeleteExpiredTokens): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return nil
+}
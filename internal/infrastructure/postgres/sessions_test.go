@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestRevokeToken(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewSessionStorage(mock)
+	require.NoError(t, err)
+
+	token := domain.RevokedToken{Id: "jti", UserId: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	mock.ExpectExec("insert into revoked_token").
+		WithArgs(token.Id, token.UserId, token.ExpiresAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectExec("insert into session_revocation").
+		WithArgs(token.UserId, token.ExpiresAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("update refresh_token").
+		WithArgs(token.UserId).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectCommit()
+
+	err = storage.RevokeToken(context.Background(), token)
+	require.NoError(t, err)
+
+	err = storage.RevokeUserTokens(context.Background(), token.UserId, token.ExpiresAt)
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetRevocations(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewSessionStorage(mock)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	cutoff := time.Now()
+
+	mock.ExpectQuery("from revoked_token").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "expires_at"}).AddRow("jti", expiresAt))
+	mock.ExpectQuery("from session_revocation").
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "revoked_before"}).AddRow(2, cutoff))
+	mock.ExpectQuery("from revoked_session").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "revoked_at"}).AddRow("family", cutoff))
+
+	revocations, err := storage.GetRevocations(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, domain.Revocations{
+		Tokens:   map[string]time.Time{"jti": expiresAt},
+		Cutoffs:  map[int]time.Time{2: cutoff},
+		Sessions: map[string]time.Time{"family": cutoff},
+	}, revocations)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestDeleteExpiredTokens(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewSessionStorage(mock)
+	require.NoError(t, err)
+
+	cutoffsBefore := time.Now().Add(-time.Hour)
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("pg_try_advisory_xact_lock").
+		WithArgs(int64(sessionsCleanupLockId)).
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec("delete from revoked_token").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("delete from refresh_token").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("delete from session_revocation").
+		WithArgs(cutoffsBefore).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("delete from revoked_session").
+		WithArgs(cutoffsBefore).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+
+	err = storage.DeleteExpiredTokens(context.Background(), cutoffsBefore)
+	require.NoError(t, err)
+
+	// another replica is cleaning up already
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("pg_try_advisory_xact_lock").
+		WithArgs(int64(sessionsCleanupLockId)).
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+
+	err = storage.DeleteExpiredTokens(context.Background(), cutoffsBefore)
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewSessionStorage(mock)
+	require.NoError(t, err)
+
+	next := domain.RefreshToken{Hash: "next", ExpiresAt: time.Now().Add(time.Hour)}
+	columns := []string{"id", "family_id", "user_id", "name", "usable", "rotated"}
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("from refresh_token").
+		WithArgs("current").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(int64(1), "family", 2, "test_user", true, false))
+	mock.ExpectExec("update refresh_token").
+		WithArgs(int64(1)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("insert into refresh_token").
+		WithArgs(next.Hash, "family", 2, next.ExpiresAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	rotated, err := storage.RotateRefreshToken(context.Background(), "current", next)
+	require.NoError(t, err)
+	require.Equal(t, domain.RefreshToken{
+		Hash:      next.Hash,
+		FamilyId:  "family",
+		UserId:    2,
+		UserName:  "test_user",
+		ExpiresAt: next.ExpiresAt,
+	}, rotated)
+
+	// the rotated token is presented again, the whole family and its session are revoked
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("from refresh_token").
+		WithArgs("current").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(int64(1), "family", 2, "test_user", true, true))
+	mock.ExpectExec("update refresh_token").
+		WithArgs("family").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("insert into revoked_session").
+		WithArgs("family", 2).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	_, err = storage.RotateRefreshToken(context.Background(), "current", next)
+	require.True(t, errors.Is(err, customErrors.ErrUnauthenticated))
+	require.True(t, errors.Is(err, customErrors.ErrRefreshTokenReused))
+
+	mock.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	mock.ExpectQuery("from refresh_token").
+		WithArgs("expired").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(int64(3), "family", 2, "test_user", false, false))
+	mock.ExpectRollback()
+
+	_, err = storage.RotateRefreshToken(context.Background(), "expired", next)
+	require.True(t, errors.Is(err, customErrors.ErrUnauthenticated))
+	require.False(t, errors.Is(err, customErrors.ErrRefreshTokenReused))
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -2,8 +2,10 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 
@@ -65,6 +67,16 @@ func (shopStorage *ShopStorage) GetInfo(ctx context.Context, username string) (d
 		return domain.InventoryInfo{}, fmt.Errorf("%w (postgres.GetInfo): %w", customErrors.ErrFailedToExecuteQuery, err)
 	}
 
+	recievedGifts, err := shopStorage.getRecievedGifts(ctx, tx, userId)
+	if err != nil {
+		return domain.InventoryInfo{}, fmt.Errorf("%w (postgres.GetInfo): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	sentGifts, err := shopStorage.getSentGifts(ctx, tx, userId)
+	if err != nil {
+		return domain.InventoryInfo{}, fmt.Errorf("%w (postgres.GetInfo): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
 	inventoryInfo := domain.InventoryInfo{
 		Coins:     userMoney,
 		Inventory: inventory,
@@ -72,6 +84,10 @@ func (shopStorage *ShopStorage) GetInfo(ctx context.Context, username string) (d
 			Recieved: recievedCoins,
 			Sent:     sentCoins,
 		},
+		GiftHistory: domain.SentRecievedGifts{
+			Recieved: recievedGifts,
+			Sent:     sentGifts,
+		},
 	}
 
 	err = tx.Commit(ctx)
@@ -82,158 +98,767 @@ func (shopStorage *ShopStorage) GetInfo(ctx context.Context, username string) (d
 	return inventoryInfo, nil
 }
 
-func (shopStorage *ShopStorage) SendCoin(ctx context.Context, transaction domain.Transaction) error {
+func (shopStorage *ShopStorage) SendCoin(
+	ctx context.Context,
+	transaction domain.Transaction,
+	idempotencyKey domain.IdempotencyKey) error {
+	err := withRetry(ctx, func() error {
+		return shopStorage.sendCoin(ctx, transaction, idempotencyKey)
+	})
+	if err != nil {
+		return fmt.Errorf("(postgres.SendCoin): %w", err)
+	}
+
+	return nil
+}
+
+func (shopStorage *ShopStorage) BuyItem(
+	ctx context.Context,
+	username string,
+	itemName string,
+	idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
+	var receipt domain.PurchaseReceipt
+	err := withRetry(ctx, func() error {
+		var err error
+		receipt, err = shopStorage.buyItem(ctx, username, itemName, idempotencyKey)
+		return err
+	})
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.BuyItem): %w", err)
+	}
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) BuyCart(
+	ctx context.Context,
+	username string,
+	cart domain.Cart,
+	idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	var receipt domain.CartReceipt
+	err := withRetry(ctx, func() error {
+		var err error
+		receipt, err = shopStorage.buyCart(ctx, username, cart, idempotencyKey)
+		return err
+	})
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.BuyCart): %w", err)
+	}
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) GiftItem(
+	ctx context.Context,
+	gift domain.Gift,
+	idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
+	var receipt domain.GiftReceipt
+	err := withRetry(ctx, func() error {
+		var err error
+		receipt, err = shopStorage.giftItem(ctx, gift, idempotencyKey)
+		return err
+	})
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(postgres.GiftItem): %w", err)
+	}
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) RefundPurchase(
+	ctx context.Context,
+	username string,
+	purchaseId int64,
+	boughtAfter time.Time) (domain.RefundReceipt, error) {
+	var receipt domain.RefundReceipt
+	err := withRetry(ctx, func() error {
+		var err error
+		receipt, err = shopStorage.refundPurchase(ctx, username, purchaseId, boughtAfter)
+		return err
+	})
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("(postgres.RefundPurchase): %w", err)
+	}
+
+	return receipt, nil
+}
+
+type lockedUser struct {
+	id    int
+	money int
+}
+
+func (shopStorage *ShopStorage) sendCoin(
+	ctx context.Context,
+	transaction domain.Transaction,
+	idempotencyKey domain.IdempotencyKey) error {
 	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToBeginTx, err)
+		return fmt.Errorf("%w (postgres.sendCoin): %w", customErrors.ErrFailedToBeginTx, err)
 	}
 	defer func() {
 		err = tx.Rollback(ctx)
 		if err != nil && err != pgx.ErrTxClosed {
-			fmt.Printf("%v (postgres.SendCoin): %v", customErrors.ErrFailedToRollbackTx, err)
+			fmt.Printf("%v (postgres.sendCoin): %v", customErrors.ErrFailedToRollbackTx, err)
 		}
 	}()
 
-	var toUserId int
-	err = tx.QueryRow(ctx, `
-		select id
-		from users
-		where name = $1;
-	`, transaction.To).Scan(&toUserId)
+	users, err := lockUsers(ctx, tx, transaction.From, transaction.To)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrDoesNotExist, err)
+		return fmt.Errorf("(postgres.sendCoin): %w", err)
+	}
+
+	fromUser, ok := users[transaction.From]
+	if !ok {
+		return fmt.Errorf("%w (postgres.sendCoin): %s", customErrors.ErrDoesNotExist, transaction.From)
+	}
+
+	toUser, ok := users[transaction.To]
+	if !ok {
+		return fmt.Errorf("%w (postgres.sendCoin): %s", customErrors.ErrDoesNotExist, transaction.To)
+	}
+
+	replay, err := shopStorage.claimIdempotencyKey(ctx, tx, fromUser.id, idempotencyKey)
+	if err != nil {
+		return fmt.Errorf("(postgres.sendCoin): %w", err)
+	}
+	if replay {
+		return nil
+	}
+
+	if fromUser.money-transaction.Amount < 0 {
+		return fmt.Errorf("%w (postgres.sendCoin)", customErrors.ErrInsufficientFunds)
+	}
+
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryTransfer,
+		domain.UserAccount(fromUser.id),
+		domain.UserAccount(toUser.id),
+		transaction.Amount))
+	if err != nil {
+		return fmt.Errorf("(postgres.sendCoin): %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into user_transaction(user_from, user_to, money)
+		values ($1, $2, $3);
+	`, fromUser.id, toUser.id, transaction.Amount)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.sendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.sendCoin): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return nil
+}
+
+func (shopStorage *ShopStorage) buyItem(
+	ctx context.Context,
+	username string,
+	itemName string,
+	idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
+	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.buyItem): %v", customErrors.ErrFailedToRollbackTx, err)
 		}
+	}()
 
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+	users, err := lockUsers(ctx, tx, username)
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.buyItem): %w", err)
+	}
+
+	user, ok := users[username]
+	if !ok {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	// a replay returns the saved receipt even if the product has been archived or repriced since
+	replay, err := shopStorage.claimIdempotencyKey(ctx, tx, user.id, idempotencyKey)
+	if err != nil {
+		return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.buyItem): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.PurchaseReceipt
+		err = shopStorage.getIdempotentResponse(ctx, tx, user.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.buyItem): %w", err)
+		}
+
+		return savedReceipt, nil
 	}
 
 	var (
-		fromUserId int
-		userMoney  int
+		itemId        int
+		itemPrice     int
+		itemAvailable bool
 	)
 	err = tx.QueryRow(ctx, `
-		select id, money
-		from users
+		select id, price, available
+		from product
 		where name = $1;
-	`, transaction.From).Scan(&fromUserId, &userMoney)
+	`, itemName).Scan(&itemId, &itemPrice, &itemAvailable)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrDoesNotExist, err)
+			return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %w", customErrors.ErrDoesNotExist, err)
 		}
 
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if !itemAvailable {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %s", customErrors.ErrNotAvailable, itemName)
 	}
 
-	if userMoney-transaction.Amount < 0 {
-		return fmt.Errorf("%w (postgres.SendCoin): incorrect amount of coins", customErrors.ErrDataNotValid)
+	if user.money-itemPrice < 0 {
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem)", customErrors.ErrInsufficientFunds)
 	}
 
-	err = shopStorage.updateCoins(ctx, tx, fromUserId, -transaction.Amount)
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(user.id),
+		domain.RevenueAccount,
+		itemPrice))
 	if err != nil {
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.buyItem): %w", err)
 	}
 
-	err = shopStorage.updateCoins(ctx, tx, toUserId, transaction.Amount)
+	receipt := domain.PurchaseReceipt{
+		Item:    itemName,
+		Price:   itemPrice,
+		Balance: user.money - itemPrice,
+	}
+	err = tx.QueryRow(ctx, `
+		insert into user_product(user_id, product_id, price)
+		values ($1, $2, $3)
+		returning id, bought_at::timestamptz;
+	`, user.id, itemId, itemPrice).Scan(&receipt.Id, &receipt.BoughtAt)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
 	}
 
-	_, err = tx.Exec(ctx, `
-		insert into user_transaction(user_from, user_to, money)
-		values ($1, $2, $3);
-	`, fromUserId, toUserId, transaction.Amount)
+	err = shopStorage.saveIdempotentResponse(ctx, tx, user.id, idempotencyKey, receipt)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("(postgres.buyItem): %w", err)
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.SendCoin): %w", customErrors.ErrFailedToCommitTx, err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("%w (postgres.buyItem): %w", customErrors.ErrFailedToCommitTx, err)
 	}
 
-	return nil
+	return receipt, nil
 }
 
-func (shopStorage *ShopStorage) BuyItem(ctx context.Context, username string, itemName string) error {
+func (shopStorage *ShopStorage) giftItem(
+	ctx context.Context,
+	gift domain.Gift,
+	idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
 	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToBeginTx, err)
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrFailedToBeginTx, err)
 	}
 	defer func() {
 		err = tx.Rollback(ctx)
 		if err != nil && err != pgx.ErrTxClosed {
-			fmt.Printf("%v (postgres.BuyItem): %v", customErrors.ErrFailedToRollbackTx, err)
+			fmt.Printf("%v (postgres.giftItem): %v", customErrors.ErrFailedToRollbackTx, err)
 		}
 	}()
 
+	users, err := lockUsers(ctx, tx, gift.From, gift.To)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(postgres.giftItem): %w", err)
+	}
+
+	fromUser, ok := users[gift.From]
+	if !ok {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %s", customErrors.ErrDoesNotExist, gift.From)
+	}
+
+	toUser, ok := users[gift.To]
+	if !ok {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %s", customErrors.ErrDoesNotExist, gift.To)
+	}
+
+	// a replay returns the saved receipt even if the product has been archived or repriced since
+	replay, err := shopStorage.claimIdempotencyKey(ctx, tx, fromUser.id, idempotencyKey)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(postgres.giftItem): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.GiftReceipt
+		err = shopStorage.getIdempotentResponse(ctx, tx, fromUser.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.GiftReceipt{}, fmt.Errorf("(postgres.giftItem): %w", err)
+		}
+
+		return savedReceipt, nil
+	}
+
 	var (
-		itemId    int
-		itemPrice int
+		itemId        int
+		itemPrice     int
+		itemAvailable bool
 	)
 	err = tx.QueryRow(ctx, `
-		select id, price
+		select id, price, available
 		from product
 		where name = $1;
-	`, itemName).Scan(&itemId, &itemPrice)
+	`, gift.Item).Scan(&itemId, &itemPrice, &itemAvailable)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrDoesNotExist, err)
+			return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrDoesNotExist, err)
+		}
+
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if !itemAvailable {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %s", customErrors.ErrNotAvailable, gift.Item)
+	}
+
+	if fromUser.money-itemPrice < 0 {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem)", customErrors.ErrInsufficientFunds)
+	}
+
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(fromUser.id),
+		domain.RevenueAccount,
+		itemPrice))
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(postgres.giftItem): %w", err)
+	}
+
+	receipt := domain.GiftReceipt{
+		To:      gift.To,
+		Item:    gift.Item,
+		Price:   itemPrice,
+		Balance: fromUser.money - itemPrice,
+	}
+	err = tx.QueryRow(ctx, `
+		insert into user_product(user_id, product_id, price)
+		values ($1, $2, $3)
+		returning id, bought_at::timestamptz;
+	`, toUser.id, itemId, itemPrice).Scan(&receipt.Id, &receipt.SentAt)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into gift(purchase_id, user_from)
+		values ($1, $2);
+	`, receipt.Id, fromUser.id)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = shopStorage.saveIdempotentResponse(ctx, tx, fromUser.id, idempotencyKey, receipt)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("(postgres.giftItem): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.GiftReceipt{}, fmt.Errorf("%w (postgres.giftItem): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return receipt, nil
+}
+
+func (shopStorage *ShopStorage) refundPurchase(
+	ctx context.Context,
+	username string,
+	purchaseId int64,
+	boughtAfter time.Time) (domain.RefundReceipt, error) {
+	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.refundPurchase): %v", customErrors.ErrFailedToRollbackTx, err)
 		}
+	}()
 
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+	users, err := lockUsers(ctx, tx, username)
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("(postgres.refundPurchase): %w", err)
+	}
+
+	user, ok := users[username]
+	if !ok {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): %s", customErrors.ErrDoesNotExist, username)
 	}
 
 	var (
-		userId    int
-		userMoney int
+		itemName   *string
+		price      *int
+		boughtAt   time.Time
+		refundedAt *time.Time
+		notPaid    bool
 	)
 	err = tx.QueryRow(ctx, `
-		select id, money
-		from users
-		where name = $1;
-	`, username).Scan(&userId, &userMoney)
+		select p.name, up.price, up.bought_at::timestamptz, up.refunded_at,
+			g.purchase_id is not null or up.bought_for is not null
+		from user_product up
+		left join product p on p.id = up.product_id
+		left join gift g on g.purchase_id = up.id
+		where up.id = $1 and up.user_id = $2
+		for update of up;
+	`, purchaseId, user.id).Scan(&itemName, &price, &boughtAt, &refundedAt, &notPaid)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrDoesNotExist, err)
+			return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): purchase %d",
+				customErrors.ErrDoesNotExist, purchaseId)
 		}
 
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if refundedAt != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): purchase %d is already refunded",
+			customErrors.ErrAlreadyExists, purchaseId)
 	}
+	// gifts and traded items have not been paid for by their owner
+	if price == nil || notPaid || boughtAt.Before(boughtAfter) {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): purchase %d can not be refunded",
+			customErrors.ErrNotAvailable, purchaseId)
+	}
+
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryRefund,
+		domain.RevenueAccount,
+		domain.UserAccount(user.id),
+		*price))
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("(postgres.refundPurchase): %w", err)
+	}
+
+	receipt := domain.RefundReceipt{
+		PurchaseId: purchaseId,
+		Amount:     *price,
+		Balance:    user.money + *price,
+	}
+	if itemName != nil {
+		receipt.Item = *itemName
+	}
+	err = tx.QueryRow(ctx, `
+		update user_product
+		set refunded_at = now()
+		where id = $1
+		returning refunded_at;
+	`, purchaseId).Scan(&receipt.RefundedAt)
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.RefundReceipt{}, fmt.Errorf("%w (postgres.refundPurchase): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return receipt, nil
+}
+
+type cartProduct struct {
+	id        int
+	price     int
+	available bool
+}
+
+func (shopStorage *ShopStorage) buyCart(
+	ctx context.Context,
+	username string,
+	cart domain.Cart,
+	idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	tx, err := shopStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.buyCart): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	users, err := lockUsers(ctx, tx, username)
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
+	}
+
+	user, ok := users[username]
+	if !ok {
+		return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %s", customErrors.ErrDoesNotExist, username)
+	}
+
+	// a replay returns the saved receipt even if the products have been archived or repriced since
+	replay, err := shopStorage.claimIdempotencyKey(ctx, tx, user.id, idempotencyKey)
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
+	}
+	if replay {
+		var savedReceipt domain.CartReceipt
+		err = shopStorage.getIdempotentResponse(ctx, tx, user.id, idempotencyKey, &savedReceipt)
+		if err != nil {
+			return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
+		}
+
+		return savedReceipt, nil
+	}
+
+	products, err := getCartProducts(ctx, tx, cart.Items())
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
+	}
+
+	receipt := domain.CartReceipt{
+		Lines: make([]domain.CartReceiptLine, 0, len(cart.Lines)),
+	}
+	productIds := make([]int, 0, len(cart.Lines))
+	quantities := make([]int, 0, len(cart.Lines))
+	prices := make([]int, 0, len(cart.Lines))
+	for _, line := range cart.Lines {
+		product, ok := products[line.Item]
+		if !ok {
+			return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %s", customErrors.ErrDoesNotExist, line.Item)
+		}
+		if !product.available {
+			return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %s", customErrors.ErrNotAvailable, line.Item)
+		}
 
-	if userMoney-itemPrice < 0 {
-		return fmt.Errorf("%w (postgres.BuyItem): incorrect amount of coins", customErrors.ErrDataNotValid)
+		receipt.Lines = append(receipt.Lines, domain.CartReceiptLine{
+			Item:     line.Item,
+			Quantity: line.Quantity,
+			Price:    product.price,
+			Cost:     product.price * line.Quantity,
+		})
+		receipt.Total += product.price * line.Quantity
+		productIds = append(productIds, product.id)
+		quantities = append(quantities, line.Quantity)
+		prices = append(prices, product.price)
 	}
 
-	err = shopStorage.updateCoins(ctx, tx, userId, -itemPrice)
+	if user.money-receipt.Total < 0 {
+		return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart)", customErrors.ErrInsufficientFunds)
+	}
+	receipt.Balance = user.money - receipt.Total
+
+	err = postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(user.id),
+		domain.RevenueAccount,
+		receipt.Total))
 	if err != nil {
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
 	}
 
 	_, err = tx.Exec(ctx, `
-		insert into user_product(user_id, product_id)
-		values ($1, $2);
-	`, userId, itemId)
+		insert into user_product(user_id, product_id, price)
+		select $1, line.product_id, line.price
+		from unnest($2::integer[], $3::integer[], $4::integer[]) as line(product_id, quantity, price),
+			generate_series(1, line.quantity);
+	`, user.id, productIds, quantities, prices)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	err = shopStorage.saveIdempotentResponse(ctx, tx, user.id, idempotencyKey, receipt)
+	if err != nil {
+		return domain.CartReceipt{}, fmt.Errorf("(postgres.buyCart): %w", err)
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.BuyItem): %w", customErrors.ErrFailedToCommitTx, err)
+		return domain.CartReceipt{}, fmt.Errorf("%w (postgres.buyCart): %w", customErrors.ErrFailedToCommitTx, err)
 	}
 
-	return nil
+	return receipt, nil
+}
+
+func getCartProducts(
+	ctx context.Context,
+	tx pgx.Tx,
+	names []string) (map[string]cartProduct, error) {
+	products := make(map[string]cartProduct, len(names))
+	rows, err := tx.Query(ctx, `
+		select id, name, price, available
+		from product
+		where name = any($1);
+	`, names)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.getCartProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			product cartProduct
+			name    string
+		)
+
+		err = rows.Scan(&product.id, &name, &product.price, &product.available)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.getCartProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		products[name] = product
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.getCartProducts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return products, nil
+}
+
+// lockUsers locks the rows of the given users in id order, so that
+// transactions touching the same users never wait on each other in a cycle.
+func lockUsers(
+	ctx context.Context,
+	tx pgx.Tx,
+	names ...string) (map[string]lockedUser, error) {
+	users := make(map[string]lockedUser, len(names))
+	rows, err := tx.Query(ctx, `
+		select id, name, money
+		from users
+		where name = any($1)
+		order by id
+		for update;
+	`, names)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.lockUsers): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			user lockedUser
+			name string
+		)
+
+		err = rows.Scan(&user.id, &name, &user.money)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.lockUsers): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		users[name] = user
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.lockUsers): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return users, nil
 }
 
-func (shopStorage *ShopStorage) updateCoins(ctx context.Context, tx pgx.Tx, userId int, coins int) error {
+// claimIdempotencyKey stores the key within the operation transaction, so the key
+// is saved only if the operation commits. It reports true if the same request
+// has already been processed and must not be applied again.
+func (shopStorage *ShopStorage) claimIdempotencyKey(
+	ctx context.Context,
+	tx pgx.Tx,
+	userId int,
+	idempotencyKey domain.IdempotencyKey) (bool, error) {
+	if idempotencyKey.Key == "" {
+		return false, nil
+	}
+
 	_, err := tx.Exec(ctx, `
-		update users
-		set money = money + $1
-		where id = $2;
-	`, coins, userId)
+		delete from idempotency_key
+		where user_id = $1 and expires_at < now();
+	`, userId)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.claimIdempotencyKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		insert into idempotency_key(user_id, key, request_hash, expires_at)
+		values ($1, $2, $3, $4)
+		on conflict do nothing;
+	`, userId, idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.claimIdempotencyKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	if tag.RowsAffected() == 1 {
+		return false, nil
+	}
+
+	var requestHash string
+	err = tx.QueryRow(ctx, `
+		select request_hash
+		from idempotency_key
+		where user_id = $1 and key = $2;
+	`, userId, idempotencyKey.Key).Scan(&requestHash)
+	if err != nil {
+		return false, fmt.Errorf("%w (postgres.claimIdempotencyKey): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	if requestHash != idempotencyKey.RequestHash {
+		return false, fmt.Errorf("%w (postgres.claimIdempotencyKey): %s",
+			customErrors.ErrIdempotencyKeyReused, idempotencyKey.Key)
+	}
+
+	return true, nil
+}
+
+// saveIdempotentResponse stores the result of the operation next to its claimed key.
+func (shopStorage *ShopStorage) saveIdempotentResponse(
+	ctx context.Context,
+	tx pgx.Tx,
+	userId int,
+	idempotencyKey domain.IdempotencyKey,
+	response any) error {
+	if idempotencyKey.Key == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("(postgres.saveIdempotentResponse): %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		update idempotency_key
+		set response = $3
+		where user_id = $1 and key = $2;
+	`, userId, idempotencyKey.Key, data)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.saveIdempotentResponse): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return nil
+}
+
+// getIdempotentResponse reads the result stored by saveIdempotentResponse for a replayed request.
+func (shopStorage *ShopStorage) getIdempotentResponse(
+	ctx context.Context,
+	tx pgx.Tx,
+	userId int,
+	idempotencyKey domain.IdempotencyKey,
+	response any) error {
+	var data []byte
+	err := tx.QueryRow(ctx, `
+		select response
+		from idempotency_key
+		where user_id = $1 and key = $2;
+	`, userId, idempotencyKey.Key).Scan(&data)
 	if err != nil {
-		return fmt.Errorf("%w (postgres.updateCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
+		return fmt.Errorf("%w (postgres.getIdempotentResponse): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	// the keys claimed before the responses were stored have none
+	if data == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(data, response)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.getIdempotentResponse): %w", customErrors.ErrFailedToExecuteQuery, err)
 	}
 
 	return nil
@@ -244,9 +869,9 @@ func (shopStorage *ShopStorage) getInventory(ctx context.Context, tx pgx.Tx, use
 	rows, err := tx.Query(ctx, `
 		select p.name, count(*)
 		from user_product up, product p
-		where up.product_id = p.id and up.user_id = $1
-		group by p.id, p.name, bought_at
-		order by bought_at desc; 
+		where up.product_id = p.id and up.user_id = $1 and up.refunded_at is null
+		group by p.id, p.name
+		order by max(up.bought_at) desc;
 	`, userId)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
@@ -276,11 +901,12 @@ func (shopStorage *ShopStorage) getRecievedCoins(
 	userId int) ([]domain.RecievedCoins, error) {
 	recievedCoins := make([]domain.RecievedCoins, 0)
 	rows, err := tx.Query(ctx, `
-		select u.name, ut.money
+		select u.name, ut.money, ut.trade_id
 		from user_transaction ut, users u
 		where ut.user_from = u.id and ut.user_to = $1
-		order by sent_at desc; 
-	`, userId)
+		order by sent_at desc
+		limit $2;
+	`, userId, domain.InfoHistoryLimit)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("%w (postgres.getRecievedCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
@@ -289,7 +915,7 @@ func (shopStorage *ShopStorage) getRecievedCoins(
 	for rows.Next() {
 		var coins domain.RecievedCoins
 
-		err = rows.Scan(&coins.From, &coins.Amount)
+		err = rows.Scan(&coins.From, &coins.Amount, &coins.TradeId)
 		if err != nil {
 			return nil, fmt.Errorf("%w (postgres.getRecievedCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
 		}
@@ -306,11 +932,12 @@ func (shopStorage *ShopStorage) getRecievedCoins(
 func (shopStorage *ShopStorage) getSentCoins(ctx context.Context, tx pgx.Tx, userId int) ([]domain.SentCoins, error) {
 	sentCoins := make([]domain.SentCoins, 0)
 	rows, err := tx.Query(ctx, `
-		select u.name, ut.money
+		select u.name, ut.money, ut.trade_id
 		from user_transaction ut, users u
 		where ut.user_to = u.id and ut.user_from = $1
-		order by sent_at desc; 
-	`, userId)
+		order by sent_at desc
+		limit $2;
+	`, userId, domain.InfoHistoryLimit)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("%w (postgres.getSentCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
@@ -319,7 +946,7 @@ func (shopStorage *ShopStorage) getSentCoins(ctx context.Context, tx pgx.Tx, use
 	for rows.Next() {
 		var coins domain.SentCoins
 
-		err = rows.Scan(&coins.To, &coins.Amount)
+		err = rows.Scan(&coins.To, &coins.Amount, &coins.TradeId)
 		if err != nil {
 			return nil, fmt.Errorf("%w (postgres.getSentCoins): %w", customErrors.ErrFailedToExecuteQuery, err)
 		}
@@ -332,3 +959,74 @@ func (shopStorage *ShopStorage) getSentCoins(ctx context.Context, tx pgx.Tx, use
 
 	return sentCoins, nil
 }
+
+func (shopStorage *ShopStorage) getRecievedGifts(
+	ctx context.Context,
+	tx pgx.Tx,
+	userId int) ([]domain.RecievedGift, error) {
+	recievedGifts := make([]domain.RecievedGift, 0)
+	rows, err := tx.Query(ctx, `
+		select u.name, p.name
+		from gift g
+		join user_product up on up.id = g.purchase_id
+		join users u on u.id = g.user_from
+		join product p on p.id = up.product_id
+		where coalesce(up.bought_for, up.user_id) = $1
+		order by up.bought_at desc
+		limit $2;
+	`, userId, domain.InfoHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.getRecievedGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gift domain.RecievedGift
+
+		err = rows.Scan(&gift.From, &gift.Item)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.getRecievedGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		recievedGifts = append(recievedGifts, gift)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.getRecievedGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return recievedGifts, nil
+}
+
+func (shopStorage *ShopStorage) getSentGifts(ctx context.Context, tx pgx.Tx, userId int) ([]domain.SentGift, error) {
+	sentGifts := make([]domain.SentGift, 0)
+	rows, err := tx.Query(ctx, `
+		select u.name, p.name
+		from gift g
+		join user_product up on up.id = g.purchase_id
+		join users u on u.id = coalesce(up.bought_for, up.user_id)
+		join product p on p.id = up.product_id
+		where g.user_from = $1
+		order by up.bought_at desc
+		limit $2;
+	`, userId, domain.InfoHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.getSentGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gift domain.SentGift
+
+		err = rows.Scan(&gift.To, &gift.Item)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.getSentGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		sentGifts = append(sentGifts, gift)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.getSentGifts): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return sentGifts, nil
+}
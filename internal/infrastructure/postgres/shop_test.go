@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v3"
 	"github.com/stretchr/testify/require"
 
@@ -46,22 +49,40 @@ func TestGetInfo(t *testing.T) {
 	anotherUser := "test_2_user"
 	amount := 100
 
-	mockRows = pgxmock.NewRows([]string{"name", "money"}).AddRow(anotherUser, amount)
+	tradeId := int64(3)
+
+	mockRows = pgxmock.NewRows([]string{"name", "money", "trade_id"}).AddRow(anotherUser, amount, nil)
 
 	mock.ExpectQuery("select").
-		WithArgs(userId).
+		WithArgs(userId, domain.InfoHistoryLimit).
 		WillReturnRows(mockRows)
 
-	mockRows = pgxmock.NewRows([]string{"name", "money"}).AddRow(anotherUser, amount)
+	mockRows = pgxmock.NewRows([]string{"name", "money", "trade_id"}).AddRow(anotherUser, 0, &tradeId)
 
 	mock.ExpectQuery("select").
-		WithArgs(userId).
+		WithArgs(userId, domain.InfoHistoryLimit).
+		WillReturnRows(mockRows)
+
+	mockRows = pgxmock.NewRows([]string{"name", "name"}).AddRow(anotherUser, productName)
+
+	mock.ExpectQuery("select (.+) from gift").
+		WithArgs(userId, domain.InfoHistoryLimit).
+		WillReturnRows(mockRows)
+
+	mockRows = pgxmock.NewRows([]string{"name", "name"})
+
+	mock.ExpectQuery("select (.+) from gift").
+		WithArgs(userId, domain.InfoHistoryLimit).
 		WillReturnRows(mockRows)
 
 	mock.ExpectCommit()
 
-	_, err = storage.GetInfo(context.Background(), userName)
+	info, err := storage.GetInfo(context.Background(), userName)
 	require.NoError(t, err)
+	require.Equal(t, []domain.RecievedCoins{{From: anotherUser, Amount: amount}}, info.CoinHistory.Recieved)
+	require.Equal(t, []domain.SentCoins{{To: anotherUser, Amount: 0, TradeId: &tradeId}}, info.CoinHistory.Sent)
+	require.Equal(t, []domain.RecievedGift{{From: anotherUser, Item: productName}}, info.GiftHistory.Recieved)
+	require.Equal(t, []domain.SentGift{}, info.GiftHistory.Sent)
 
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
@@ -85,30 +106,23 @@ func TestSendMoney(t *testing.T) {
 		Amount: 100,
 	}
 
-	toUserId := 2
-
-	mockRows := pgxmock.NewRows([]string{"id"}).AddRow(toUserId)
-
-	mock.ExpectQuery("select").
-		WithArgs(transaction.To).
-		WillReturnRows(mockRows)
-
 	fromUserId := 1
+	toUserId := 2
 	userMoney := 1000
 
-	mockRows = pgxmock.NewRows([]string{"id", "money"}).AddRow(fromUserId, userMoney)
+	mockRows := pgxmock.NewRows([]string{"id", "name", "money"}).
+		AddRow(fromUserId, transaction.From, userMoney).
+		AddRow(toUserId, transaction.To, 0)
 
-	mock.ExpectQuery("select").
-		WithArgs(transaction.From).
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{transaction.From, transaction.To}).
 		WillReturnRows(mockRows)
 
-	mock.ExpectExec("update").
-		WithArgs(-transaction.Amount, fromUserId).
-		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
-
-	mock.ExpectExec("update").
-		WithArgs(transaction.Amount, toUserId).
-		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryTransfer,
+		domain.UserAccount(fromUserId),
+		domain.UserAccount(toUserId),
+		transaction.Amount))
 
 	mock.ExpectExec("insert").
 		WithArgs(fromUserId, toUserId, transaction.Amount).
@@ -116,12 +130,27 @@ func TestSendMoney(t *testing.T) {
 
 	mock.ExpectCommit()
 
-	err = storage.SendCoin(context.Background(), transaction)
+	err = storage.SendCoin(context.Background(), transaction, domain.IdempotencyKey{})
 	require.NoError(t, err)
 
-	transaction.Amount = -1
-	err = storage.SendCoin(context.Background(), transaction)
-	require.Error(t, err, customErrors.ErrDataNotValid)
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	transaction.Amount = userMoney + 1
+
+	mockRows = pgxmock.NewRows([]string{"id", "name", "money"}).
+		AddRow(fromUserId, transaction.From, userMoney).
+		AddRow(toUserId, transaction.To, 0)
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{transaction.From, transaction.To}).
+		WillReturnRows(mockRows)
+
+	mock.ExpectRollback()
+
+	err = storage.SendCoin(context.Background(), transaction, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
 
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
@@ -143,37 +172,483 @@ func TestBuyItem(t *testing.T) {
 	itemId := 1
 	itemPrice := 80
 
-	mockRows := pgxmock.NewRows([]string{"id", "price"}).AddRow(itemId, itemPrice)
+	userName := "test_user"
+	userId := 1
+	userMoney := 80
+
+	mockRows := pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, userMoney)
 
-	mock.ExpectQuery("select").
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnRows(mockRows)
+
+	mockRows = pgxmock.NewRows([]string{"id", "price", "available"}).AddRow(itemId, itemPrice, true)
+
+	mock.ExpectQuery("select (.+) from product").
+		WithArgs(itemName).
+		WillReturnRows(mockRows)
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(userId),
+		domain.RevenueAccount,
+		itemPrice))
+
+	boughtAt := time.Now().UTC().Truncate(time.Microsecond)
+	mockRows = pgxmock.NewRows([]string{"id", "bought_at"}).AddRow(int64(7), boughtAt)
+
+	mock.ExpectQuery("insert").
+		WithArgs(userId, itemId, itemPrice).
+		WillReturnRows(mockRows)
+
+	mock.ExpectCommit()
+
+	receipt, err := storage.BuyItem(context.Background(), userName, itemName, domain.IdempotencyKey{})
+	require.NoError(t, err)
+	require.Equal(t, domain.PurchaseReceipt{
+		Id:       7,
+		Item:     itemName,
+		Price:    itemPrice,
+		BoughtAt: boughtAt,
+		Balance:  userMoney - itemPrice,
+	}, receipt)
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mockRows = pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, 0)
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnRows(mockRows)
+
+	mockRows = pgxmock.NewRows([]string{"id", "price", "available"}).AddRow(itemId, itemPrice, true)
+
+	mock.ExpectQuery("select (.+) from product").
 		WithArgs(itemName).
 		WillReturnRows(mockRows)
 
+	mock.ExpectRollback()
+
+	_, err = storage.BuyItem(context.Background(), userName, itemName, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestBuyCart(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
 	userName := "test_user"
 	userId := 1
-	userMoney := 80
+	cart := domain.Cart{Lines: []domain.CartLine{{Item: "pen", Quantity: 5}, {Item: "cup", Quantity: 2}}}
+	idempotencyKey := domain.IdempotencyKey{
+		Key:         "test_key",
+		RequestHash: "test_hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	expectedReceipt := domain.CartReceipt{
+		Lines: []domain.CartReceiptLine{
+			{Item: "pen", Quantity: 5, Price: 10, Cost: 50},
+			{Item: "cup", Quantity: 2, Price: 20, Cost: 40},
+		},
+		Total:   90,
+		Balance: 10,
+	}
+	savedReceipt, err := json.Marshal(expectedReceipt)
+	require.NoError(t, err)
 
-	mockRows = pgxmock.NewRows([]string{"id", "money"}).AddRow(userId, userMoney)
+	expectClaim := func(inserted int64) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
 
-	mock.ExpectQuery("select").
-		WithArgs(userName).
-		WillReturnRows(mockRows)
+		mockRows := pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, 100)
+
+		mock.ExpectQuery("select (.+) for update").
+			WithArgs([]string{userName}).
+			WillReturnRows(mockRows)
+
+		mock.ExpectExec("delete").
+			WithArgs(userId).
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+		mock.ExpectExec("insert into idempotency_key").
+			WithArgs(userId, idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt).
+			WillReturnResult(pgxmock.NewResult("INSERT", inserted))
+	}
+
+	expectClaim(1)
+
+	mock.ExpectQuery("select (.+) from product").
+		WithArgs([]string{"pen", "cup"}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "price", "available"}).
+			AddRow(4, "pen", 10, true).
+			AddRow(2, "cup", 20, true))
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(userId),
+		domain.RevenueAccount,
+		expectedReceipt.Total))
 
-	mock.ExpectExec("update").
-		WithArgs(-itemPrice, userId).
+	mock.ExpectExec("insert into user_product").
+		WithArgs(userId, []int{4, 2}, []int{5, 2}, []int{10, 20}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 7))
+
+	mock.ExpectExec("update idempotency_key").
+		WithArgs(userId, idempotencyKey.Key, savedReceipt).
 		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
-	mock.ExpectExec("insert").
-		WithArgs(userId, itemId).
+	mock.ExpectCommit()
+
+	receipt, err := storage.BuyCart(context.Background(), userName, cart, idempotencyKey)
+	require.NoError(t, err)
+	require.Equal(t, expectedReceipt, receipt)
+
+	// the replay returns the saved receipt and buys nothing
+	expectClaim(0)
+
+	mock.ExpectQuery("select request_hash").
+		WithArgs(userId, idempotencyKey.Key).
+		WillReturnRows(pgxmock.NewRows([]string{"request_hash"}).AddRow(idempotencyKey.RequestHash))
+
+	mock.ExpectQuery("select response").
+		WithArgs(userId, idempotencyKey.Key).
+		WillReturnRows(pgxmock.NewRows([]string{"response"}).AddRow(savedReceipt))
+
+	mock.ExpectRollback()
+
+	receipt, err = storage.BuyCart(context.Background(), userName, cart, idempotencyKey)
+	require.NoError(t, err)
+	require.Equal(t, expectedReceipt, receipt)
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, 100))
+
+	mock.ExpectQuery("select (.+) from product").
+		WithArgs([]string{"pen", "cup"}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "price", "available"}).AddRow(4, "pen", 10, true))
+
+	mock.ExpectRollback()
+
+	_, err = storage.BuyCart(context.Background(), userName, cart, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGiftItem(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	gift := domain.Gift{From: "test_user", To: "test_2_user", Item: "cup"}
+	fromId := 1
+	toId := 2
+	itemId := 2
+	itemPrice := 20
+
+	expectGift := func(fromMoney int) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
+
+		mock.ExpectQuery("select (.+) for update").
+			WithArgs([]string{gift.From, gift.To}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).
+				AddRow(fromId, gift.From, fromMoney).
+				AddRow(toId, gift.To, 0))
+
+		mock.ExpectQuery("select (.+) from product").
+			WithArgs(gift.Item).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "price", "available"}).AddRow(itemId, itemPrice, true))
+	}
+
+	expectGift(100)
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(fromId),
+		domain.RevenueAccount,
+		itemPrice))
+
+	sentAt := time.Now().UTC().Truncate(time.Microsecond)
+	mock.ExpectQuery("insert into user_product").
+		WithArgs(toId, itemId, itemPrice).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "bought_at"}).AddRow(int64(7), sentAt))
+
+	mock.ExpectExec("insert into gift").
+		WithArgs(int64(7), fromId).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	mock.ExpectCommit()
 
-	err = storage.BuyItem(context.Background(), userName, itemName)
+	receipt, err := storage.GiftItem(context.Background(), gift, domain.IdempotencyKey{})
 	require.NoError(t, err)
+	require.Equal(t, domain.GiftReceipt{
+		Id:      7,
+		To:      gift.To,
+		Item:    gift.Item,
+		Price:   itemPrice,
+		SentAt:  sentAt,
+		Balance: 80,
+	}, receipt)
+
+	expectGift(10)
+	mock.ExpectRollback()
+
+	_, err = storage.GiftItem(context.Background(), gift, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{gift.From, gift.To}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(fromId, gift.From, 100))
+	mock.ExpectRollback()
+
+	_, err = storage.GiftItem(context.Background(), gift, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestRefundPurchase(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	userName := "test_user"
+	userId := 1
+	purchaseId := int64(7)
+	itemName := "t-shirt"
+	price := 80
+	boughtAfter := time.Now().Add(-time.Hour)
+	refundedAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	expectPurchase := func(boughtAt time.Time, refundedAt *time.Time, gifted bool) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
+
+		mock.ExpectQuery("select (.+) for update").
+			WithArgs([]string{userName}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, 20))
+
+		mock.ExpectQuery("select (.+) from user_product").
+			WithArgs(purchaseId, userId).
+			WillReturnRows(pgxmock.NewRows([]string{"name", "price", "bought_at", "refunded_at", "gifted"}).
+				AddRow(&itemName, &price, boughtAt, refundedAt, gifted))
+	}
+
+	expectPurchase(time.Now(), nil, false)
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryRefund,
+		domain.RevenueAccount,
+		domain.UserAccount(userId),
+		price))
+
+	mock.ExpectQuery("update user_product").
+		WithArgs(purchaseId).
+		WillReturnRows(pgxmock.NewRows([]string{"refunded_at"}).AddRow(refundedAt))
+
+	mock.ExpectCommit()
+
+	receipt, err := storage.RefundPurchase(context.Background(), userName, purchaseId, boughtAfter)
+	require.NoError(t, err)
+	require.Equal(t, domain.RefundReceipt{
+		PurchaseId: purchaseId,
+		Item:       itemName,
+		Amount:     price,
+		RefundedAt: refundedAt,
+		Balance:    100,
+	}, receipt)
+
+	expectPurchase(time.Now(), &refundedAt, false)
+	mock.ExpectRollback()
+
+	_, err = storage.RefundPurchase(context.Background(), userName, purchaseId, boughtAfter)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	expectPurchase(boughtAfter.Add(-time.Minute), nil, false)
+	mock.ExpectRollback()
+
+	_, err = storage.RefundPurchase(context.Background(), userName, purchaseId, boughtAfter)
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	expectPurchase(time.Now(), nil, true)
+	mock.ExpectRollback()
+
+	_, err = storage.RefundPurchase(context.Background(), userName, purchaseId, boughtAfter)
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, 20))
+	mock.ExpectQuery("select (.+) from user_product").
+		WithArgs(purchaseId, userId).
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err = storage.RefundPurchase(context.Background(), userName, purchaseId, boughtAfter)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestBuyItemRetry(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	itemName := "t-shirt"
+	itemId := 1
+	itemPrice := 80
+
+	userName := "test_user"
+	userId := 1
+	userMoney := 1000
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnError(&pgconn.PgError{Code: deadlockDetectedCode})
+
+	mock.ExpectRollback()
+
+	mock.ExpectBeginTx(pgx.TxOptions{
+		IsoLevel: pgx.ReadCommitted,
+	})
+
+	mockRows := pgxmock.NewRows([]string{"id", "name", "money"}).AddRow(userId, userName, userMoney)
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{userName}).
+		WillReturnRows(mockRows)
+
+	mockRows = pgxmock.NewRows([]string{"id", "price", "available"}).AddRow(itemId, itemPrice, true)
+
+	mock.ExpectQuery("select (.+) from product").
+		WithArgs(itemName).
+		WillReturnRows(mockRows)
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryPurchase,
+		domain.UserAccount(userId),
+		domain.RevenueAccount,
+		itemPrice))
+
+	mockRows = pgxmock.NewRows([]string{"id", "bought_at"}).AddRow(int64(1), time.Now())
+
+	mock.ExpectQuery("insert").
+		WithArgs(userId, itemId, itemPrice).
+		WillReturnRows(mockRows)
+
+	mock.ExpectCommit()
+
+	_, err = storage.BuyItem(context.Background(), userName, itemName, domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestSendCoinIdempotency(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewShopStorage(mock)
+	require.NoError(t, err)
+
+	transaction := domain.Transaction{
+		From:   "test_user",
+		To:     "test_2_user",
+		Amount: 100,
+	}
+	idempotencyKey := domain.IdempotencyKey{
+		Key:         "test_key",
+		RequestHash: "test_hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	fromUserId := 1
+	toUserId := 2
+
+	expectClaim := func(inserted int64, storedHash string) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
+
+		mockRows := pgxmock.NewRows([]string{"id", "name", "money"}).
+			AddRow(fromUserId, transaction.From, 0).
+			AddRow(toUserId, transaction.To, 0)
+
+		mock.ExpectQuery("select (.+) for update").
+			WithArgs([]string{transaction.From, transaction.To}).
+			WillReturnRows(mockRows)
+
+		mock.ExpectExec("delete").
+			WithArgs(fromUserId).
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+		mock.ExpectExec("insert").
+			WithArgs(fromUserId, idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt).
+			WillReturnResult(pgxmock.NewResult("INSERT", inserted))
+
+		mockRows = pgxmock.NewRows([]string{"request_hash"}).AddRow(storedHash)
+
+		mock.ExpectQuery("select request_hash").
+			WithArgs(fromUserId, idempotencyKey.Key).
+			WillReturnRows(mockRows)
+
+		mock.ExpectRollback()
+	}
+
+	// the transfer was already applied, so it is replayed even though
+	// the balance is no longer enough to repeat it
+	expectClaim(0, idempotencyKey.RequestHash)
+
+	err = storage.SendCoin(context.Background(), transaction, idempotencyKey)
+	require.NoError(t, err)
+
+	expectClaim(0, "another_hash")
 
-	err = storage.BuyItem(context.Background(), userName, itemName)
-	require.Error(t, err, customErrors.ErrDataNotValid)
+	err = storage.SendCoin(context.Background(), transaction, idempotencyKey)
+	require.ErrorIs(t, err, customErrors.ErrIdempotencyKeyReused)
 
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
@@ -0,0 +1,616 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type TradeStorage struct {
+	pool PgxPool
+}
+
+func NewTradeStorage(pool PgxPool) (*TradeStorage, error) {
+	return &TradeStorage{
+		pool: pool,
+	}, nil
+}
+
+func (tradeStorage *TradeStorage) CreateTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	var trade domain.Trade
+	err := withRetry(ctx, func() error {
+		var err error
+		trade, err = tradeStorage.createTrade(ctx, offer)
+		return err
+	})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.CreateTrade): %w", err)
+	}
+
+	return trade, nil
+}
+
+func (tradeStorage *TradeStorage) AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	var trade domain.Trade
+	err := withRetry(ctx, func() error {
+		var err error
+		trade, err = tradeStorage.acceptTrade(ctx, username, tradeId)
+		return err
+	})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.AcceptTrade): %w", err)
+	}
+
+	return trade, nil
+}
+
+func (tradeStorage *TradeStorage) DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	var trade domain.Trade
+	err := withRetry(ctx, func() error {
+		var err error
+		trade, err = tradeStorage.declineTrade(ctx, username, tradeId)
+		return err
+	})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.DeclineTrade): %w", err)
+	}
+
+	return trade, nil
+}
+
+func (tradeStorage *TradeStorage) GetTrades(ctx context.Context, username string) ([]domain.Trade, error) {
+	tx, err := tradeStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetTrades): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.GetTrades): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	trades := make([]domain.Trade, 0)
+	rows, err := tx.Query(ctx, `
+		select t.id, uf.name, ut.name, t.offered_coins, t.requested_coins,
+			case when t.status = 'pending' and t.expires_at <= now() then 'expired' else t.status end,
+			t.created_at, t.expires_at, t.resolved_at
+		from trade t
+		join users uf on uf.id = t.user_from
+		join users ut on ut.id = t.user_to
+		where uf.name = $1 or ut.name = $1
+		order by t.created_at desc, t.id desc
+		limit $2;
+	`, username, domain.MaxTrades)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetTrades): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		trade, err := scanTrade(rows)
+		if err != nil {
+			return nil, fmt.Errorf("(postgres.GetTrades): %w", err)
+		}
+
+		trades = append(trades, trade)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetTrades): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	_, err = getTradeItems(ctx, tx, trades)
+	if err != nil {
+		return nil, fmt.Errorf("(postgres.GetTrades): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.GetTrades): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return trades, nil
+}
+
+func (tradeStorage *TradeStorage) GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tx, err := tradeStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.GetTrade): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.GetTrade): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	trade, _, err := getTrade(ctx, tx, username, tradeId, false)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.GetTrade): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.GetTrade): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return trade, nil
+}
+
+func (tradeStorage *TradeStorage) createTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	tx, err := tradeStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.createTrade): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	names := make([]string, 0, len(offer.Offered.Items)+len(offer.Requested.Items))
+	for _, side := range []domain.TradeSide{offer.Offered, offer.Requested} {
+		for _, item := range side.Items {
+			names = append(names, item.Item)
+		}
+	}
+
+	products, err := getCartProducts(ctx, tx, names)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.createTrade): %w", err)
+	}
+
+	productIds := make(map[string]int, len(products))
+	for _, name := range names {
+		product, ok := products[name]
+		if !ok {
+			return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %s", customErrors.ErrDoesNotExist, name)
+		}
+
+		productIds[name] = product.id
+	}
+
+	users, err := lockUsers(ctx, tx, offer.From, offer.To)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.createTrade): %w", err)
+	}
+
+	fromUser, ok := users[offer.From]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %s", customErrors.ErrDoesNotExist, offer.From)
+	}
+
+	toUser, ok := users[offer.To]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %s", customErrors.ErrDoesNotExist, offer.To)
+	}
+
+	// nothing is reserved for the trade, the offer is checked once more on acceptance
+	err = checkTradeSide(ctx, tx, fromUser, offer.Offered, productIds)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.createTrade): %w", err)
+	}
+
+	trade := domain.Trade{
+		From:      offer.From,
+		To:        offer.To,
+		Offered:   offer.Offered,
+		Requested: offer.Requested,
+		ExpiresAt: offer.ExpiresAt,
+	}
+	err = tx.QueryRow(ctx, `
+		insert into trade(user_from, user_to, offered_coins, requested_coins, expires_at)
+		values ($1, $2, $3, $4, $5)
+		returning id, case when expires_at <= created_at then 'expired' else status end, created_at;
+	`, fromUser.id, toUser.id, offer.Offered.Coins, offer.Requested.Coins, offer.ExpiresAt).
+		Scan(&trade.Id, &trade.Status, &trade.CreatedAt)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	sides := make([]string, 0, len(names))
+	itemIds := make([]int, 0, len(names))
+	quantities := make([]int, 0, len(names))
+	for _, side := range []struct {
+		name  string
+		items []domain.TradeItem
+	}{
+		{domain.TradeSideOffered, offer.Offered.Items},
+		{domain.TradeSideRequested, offer.Requested.Items},
+	} {
+		for _, item := range side.items {
+			sides = append(sides, side.name)
+			itemIds = append(itemIds, productIds[item.Item])
+			quantities = append(quantities, item.Quantity)
+		}
+	}
+
+	if len(itemIds) > 0 {
+		_, err = tx.Exec(ctx, `
+			insert into trade_item(trade_id, side, product_id, quantity, line)
+			select $1, item.side, item.product_id, item.quantity, item.line
+			from unnest($2::text[], $3::integer[], $4::integer[]) with ordinality
+				as item(side, product_id, quantity, line);
+		`, trade.Id, sides, itemIds, quantities)
+		if err != nil {
+			return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.createTrade): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	if trade.Offered.Items == nil {
+		trade.Offered.Items = make([]domain.TradeItem, 0)
+	}
+	if trade.Requested.Items == nil {
+		trade.Requested.Items = make([]domain.TradeItem, 0)
+	}
+
+	return trade, nil
+}
+
+// acceptTrade locks the trade before the users, no transaction locks them
+// in the opposite order.
+func (tradeStorage *TradeStorage) acceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tx, err := tradeStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.acceptTrade): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.acceptTrade): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	trade, productIds, err := getTrade(ctx, tx, username, tradeId, true)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	err = trade.CheckResolvable(username)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	users, err := lockUsers(ctx, tx, trade.From, trade.To)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	fromUser, ok := users[trade.From]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.acceptTrade): %s", customErrors.ErrDoesNotExist, trade.From)
+	}
+
+	toUser, ok := users[trade.To]
+	if !ok {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.acceptTrade): %s", customErrors.ErrDoesNotExist, trade.To)
+	}
+
+	err = checkTradeSide(ctx, tx, fromUser, trade.Offered, productIds)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	err = checkTradeSide(ctx, tx, toUser, trade.Requested, productIds)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	err = giveTradeSide(ctx, tx, trade.Id, fromUser, toUser, trade.Offered, productIds)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	err = giveTradeSide(ctx, tx, trade.Id, toUser, fromUser, trade.Requested, productIds)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	trade, err = resolveTrade(ctx, tx, trade, domain.TradeStatusAccepted)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.acceptTrade): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.acceptTrade): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return trade, nil
+}
+
+func (tradeStorage *TradeStorage) declineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	tx, err := tradeStorage.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.declineTrade): %w", customErrors.ErrFailedToBeginTx, err)
+	}
+	defer func() {
+		err = tx.Rollback(ctx)
+		if err != nil && err != pgx.ErrTxClosed {
+			fmt.Printf("%v (postgres.declineTrade): %v", customErrors.ErrFailedToRollbackTx, err)
+		}
+	}()
+
+	trade, _, err := getTrade(ctx, tx, username, tradeId, true)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.declineTrade): %w", err)
+	}
+
+	err = trade.CheckResolvable(username)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.declineTrade): %w", err)
+	}
+
+	trade, err = resolveTrade(ctx, tx, trade, domain.TradeStatusDeclined)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("(postgres.declineTrade): %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.declineTrade): %w", customErrors.ErrFailedToCommitTx, err)
+	}
+
+	return trade, nil
+}
+
+// getTrade reads a trade of the user with its items and the ids of the traded products.
+func getTrade(
+	ctx context.Context,
+	tx pgx.Tx,
+	username string,
+	tradeId int64,
+	forUpdate bool) (domain.Trade, map[string]int, error) {
+	query := `
+		select t.id, uf.name, ut.name, t.offered_coins, t.requested_coins,
+			case when t.status = 'pending' and t.expires_at <= now() then 'expired' else t.status end,
+			t.created_at, t.expires_at, t.resolved_at
+		from trade t
+		join users uf on uf.id = t.user_from
+		join users ut on ut.id = t.user_to
+		where t.id = $1 and (uf.name = $2 or ut.name = $2)`
+	if forUpdate {
+		query += `
+		for update of t`
+	}
+
+	trade, err := scanTrade(tx.QueryRow(ctx, query+";", tradeId, username))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Trade{}, nil, fmt.Errorf("%w (postgres.getTrade): trade %d",
+				customErrors.ErrDoesNotExist, tradeId)
+		}
+
+		return domain.Trade{}, nil, fmt.Errorf("(postgres.getTrade): %w", err)
+	}
+
+	trades := []domain.Trade{trade}
+	productIds, err := getTradeItems(ctx, tx, trades)
+	if err != nil {
+		return domain.Trade{}, nil, fmt.Errorf("(postgres.getTrade): %w", err)
+	}
+
+	return trades[0], productIds, nil
+}
+
+func scanTrade(row pgx.Row) (domain.Trade, error) {
+	trade := domain.Trade{
+		Offered:   domain.TradeSide{Items: make([]domain.TradeItem, 0)},
+		Requested: domain.TradeSide{Items: make([]domain.TradeItem, 0)},
+	}
+
+	err := row.Scan(
+		&trade.Id,
+		&trade.From,
+		&trade.To,
+		&trade.Offered.Coins,
+		&trade.Requested.Coins,
+		&trade.Status,
+		&trade.CreatedAt,
+		&trade.ExpiresAt,
+		&trade.ResolvedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Trade{}, err
+		}
+
+		return domain.Trade{}, fmt.Errorf("%w (postgres.scanTrade): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return trade, nil
+}
+
+// getTradeItems fills the items of the trades and returns the ids of the traded products.
+func getTradeItems(ctx context.Context, tx pgx.Tx, trades []domain.Trade) (map[string]int, error) {
+	productIds := make(map[string]int)
+	if len(trades) == 0 {
+		return productIds, nil
+	}
+
+	positions := make(map[int64]int, len(trades))
+	tradeIds := make([]int64, 0, len(trades))
+	for i, trade := range trades {
+		positions[trade.Id] = i
+		tradeIds = append(tradeIds, trade.Id)
+	}
+
+	rows, err := tx.Query(ctx, `
+		select ti.trade_id, ti.side, p.id, p.name, ti.quantity
+		from trade_item ti
+		join product p on p.id = ti.product_id
+		where ti.trade_id = any($1)
+		order by ti.trade_id, ti.line;
+	`, tradeIds)
+	if err != nil {
+		return nil, fmt.Errorf("%w (postgres.getTradeItems): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tradeId   int64
+			side      string
+			productId int
+			item      domain.TradeItem
+		)
+
+		err = rows.Scan(&tradeId, &side, &productId, &item.Item, &item.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("%w (postgres.getTradeItems): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		trade := &trades[positions[tradeId]]
+		if side == domain.TradeSideOffered {
+			trade.Offered.Items = append(trade.Offered.Items, item)
+		} else {
+			trade.Requested.Items = append(trade.Requested.Items, item)
+		}
+		productIds[item.Item] = productId
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w (postgres.getTradeItems): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	return productIds, nil
+}
+
+// checkTradeSide verifies that the user has the coins and the items given away,
+// the user must be locked.
+func checkTradeSide(
+	ctx context.Context,
+	tx pgx.Tx,
+	user lockedUser,
+	side domain.TradeSide,
+	productIds map[string]int) error {
+	if user.money-side.Coins < 0 {
+		return fmt.Errorf("%w (postgres.checkTradeSide)", customErrors.ErrInsufficientFunds)
+	}
+
+	if len(side.Items) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(side.Items))
+	for _, item := range side.Items {
+		ids = append(ids, productIds[item.Item])
+	}
+
+	owned := make(map[int]int, len(ids))
+	rows, err := tx.Query(ctx, `
+		select product_id, count(*)
+		from user_product
+		where user_id = $1 and product_id = any($2) and refunded_at is null
+		group by product_id;
+	`, user.id, ids)
+	if err != nil {
+		return fmt.Errorf("%w (postgres.checkTradeSide): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productId, count int
+
+		err = rows.Scan(&productId, &count)
+		if err != nil {
+			return fmt.Errorf("%w (postgres.checkTradeSide): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+
+		owned[productId] = count
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%w (postgres.checkTradeSide): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	for _, item := range side.Items {
+		if owned[productIds[item.Item]] < item.Quantity {
+			return fmt.Errorf("%w (postgres.checkTradeSide): not enough %s", customErrors.ErrNotAvailable, item.Item)
+		}
+	}
+
+	return nil
+}
+
+// giveTradeSide moves the coins and the earliest bought items of the side from one user to another,
+// a side that gives anything is recorded in the history of both users, even with no coins.
+func giveTradeSide(
+	ctx context.Context,
+	tx pgx.Tx,
+	tradeId int64,
+	fromUser lockedUser,
+	toUser lockedUser,
+	side domain.TradeSide,
+	productIds map[string]int) error {
+	if side.Coins > 0 {
+		err := postLedgerEntry(ctx, tx, domain.NewLedgerTransfer(
+			domain.LedgerEntryTransfer,
+			domain.UserAccount(fromUser.id),
+			domain.UserAccount(toUser.id),
+			side.Coins))
+		if err != nil {
+			return fmt.Errorf("(postgres.giveTradeSide): %w", err)
+		}
+	}
+
+	if side.Coins > 0 || len(side.Items) > 0 {
+		_, err := tx.Exec(ctx, `
+			insert into user_transaction(user_from, user_to, money, trade_id)
+			values ($1, $2, $3, $4);
+		`, fromUser.id, toUser.id, side.Coins, tradeId)
+		if err != nil {
+			return fmt.Errorf("%w (postgres.giveTradeSide): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+	}
+
+	for _, item := range side.Items {
+		tag, err := tx.Exec(ctx, `
+			update user_product
+			set user_id = $2, bought_for = coalesce(bought_for, user_id)
+			where id in (
+				select id
+				from user_product
+				where user_id = $1 and product_id = $3 and refunded_at is null
+				order by bought_at, id
+				limit $4
+				for update
+			);
+		`, fromUser.id, toUser.id, productIds[item.Item], item.Quantity)
+		if err != nil {
+			return fmt.Errorf("%w (postgres.giveTradeSide): %w", customErrors.ErrFailedToExecuteQuery, err)
+		}
+		if tag.RowsAffected() != int64(item.Quantity) {
+			return fmt.Errorf("%w (postgres.giveTradeSide): not enough %s", customErrors.ErrNotAvailable, item.Item)
+		}
+	}
+
+	return nil
+}
+
+func resolveTrade(ctx context.Context, tx pgx.Tx, trade domain.Trade, status string) (domain.Trade, error) {
+	var resolvedAt time.Time
+	err := tx.QueryRow(ctx, `
+		update trade
+		set status = $2, resolved_at = now()
+		where id = $1
+		returning resolved_at;
+	`, trade.Id, status).Scan(&resolvedAt)
+	if err != nil {
+		return domain.Trade{}, fmt.Errorf("%w (postgres.resolveTrade): %w", customErrors.ErrFailedToExecuteQuery, err)
+	}
+
+	trade.Status = status
+	trade.ResolvedAt = &resolvedAt
+
+	return trade, nil
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+func TestCreateTrade(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewTradeStorage(mock)
+	require.NoError(t, err)
+
+	offer := domain.TradeOffer{
+		From:      "test_user",
+		To:        "test_2_user",
+		Offered:   domain.TradeSide{Items: []domain.TradeItem{{Item: "pen", Quantity: 2}}, Coins: 30},
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	fromId := 1
+	toId := 2
+	penId := 4
+	cupId := 2
+
+	expectOffer := func(ownedPens int) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
+
+		mock.ExpectQuery("select (.+) from product").
+			WithArgs([]string{"pen", "cup"}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "price", "available"}).
+				AddRow(penId, "pen", 10, true).
+				AddRow(cupId, "cup", 20, true))
+
+		mock.ExpectQuery("select (.+) for update").
+			WithArgs([]string{offer.From, offer.To}).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).
+				AddRow(fromId, offer.From, 100).
+				AddRow(toId, offer.To, 100))
+
+		mock.ExpectQuery("select product_id, count").
+			WithArgs(fromId, []int{penId}).
+			WillReturnRows(pgxmock.NewRows([]string{"product_id", "count"}).AddRow(penId, ownedPens))
+	}
+
+	expectOffer(2)
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	mock.ExpectQuery("insert into trade").
+		WithArgs(fromId, toId, offer.Offered.Coins, offer.Requested.Coins, offer.ExpiresAt).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "status", "created_at"}).
+			AddRow(int64(3), domain.TradeStatusPending, createdAt))
+
+	mock.ExpectExec("insert into trade_item").
+		WithArgs(int64(3), []string{domain.TradeSideOffered, domain.TradeSideRequested}, []int{penId, cupId}, []int{2, 1}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+	mock.ExpectCommit()
+
+	trade, err := storage.CreateTrade(context.Background(), offer)
+	require.NoError(t, err)
+	require.Equal(t, domain.Trade{
+		Id:        3,
+		From:      offer.From,
+		To:        offer.To,
+		Offered:   offer.Offered,
+		Requested: offer.Requested,
+		Status:    domain.TradeStatusPending,
+		CreatedAt: createdAt,
+		ExpiresAt: offer.ExpiresAt,
+	}, trade)
+
+	expectOffer(1)
+	mock.ExpectRollback()
+
+	_, err = storage.CreateTrade(context.Background(), offer)
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestAcceptTrade(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	storage, err := NewTradeStorage(mock)
+	require.NoError(t, err)
+
+	tradeId := int64(3)
+	fromName := "test_user"
+	toName := "test_2_user"
+	fromId := 1
+	toId := 2
+	penId := 4
+	cupId := 2
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	expiresAt := createdAt.Add(time.Hour)
+
+	expectTrade := func(username string) {
+		mock.ExpectBeginTx(pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})
+
+		mock.ExpectQuery("select (.+) from trade t").
+			WithArgs(tradeId, username).
+			WillReturnRows(pgxmock.NewRows([]string{
+				"id", "from", "to", "offered_coins", "requested_coins", "status", "created_at", "expires_at", "resolved_at",
+			}).AddRow(tradeId, fromName, toName, 30, 0, domain.TradeStatusPending, createdAt, expiresAt, nil))
+
+		mock.ExpectQuery("select (.+) from trade_item").
+			WithArgs([]int64{tradeId}).
+			WillReturnRows(pgxmock.NewRows([]string{"trade_id", "side", "id", "name", "quantity"}).
+				AddRow(tradeId, domain.TradeSideOffered, penId, "pen", 2).
+				AddRow(tradeId, domain.TradeSideRequested, cupId, "cup", 1))
+	}
+
+	expectTrade(toName)
+
+	mock.ExpectQuery("select (.+) for update").
+		WithArgs([]string{fromName, toName}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "money"}).
+			AddRow(fromId, fromName, 100).
+			AddRow(toId, toName, 100))
+
+	mock.ExpectQuery("select product_id, count").
+		WithArgs(fromId, []int{penId}).
+		WillReturnRows(pgxmock.NewRows([]string{"product_id", "count"}).AddRow(penId, 2))
+	mock.ExpectQuery("select product_id, count").
+		WithArgs(toId, []int{cupId}).
+		WillReturnRows(pgxmock.NewRows([]string{"product_id", "count"}).AddRow(cupId, 1))
+
+	expectLedgerEntry(mock, 1, domain.NewLedgerTransfer(
+		domain.LedgerEntryTransfer,
+		domain.UserAccount(fromId),
+		domain.UserAccount(toId),
+		30))
+	mock.ExpectExec("insert into user_transaction").
+		WithArgs(fromId, toId, 30, tradeId).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("update user_product").
+		WithArgs(fromId, toId, penId, 2).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	// the requested side gives no coins but is recorded for the history
+	mock.ExpectExec("insert into user_transaction").
+		WithArgs(toId, fromId, 0, tradeId).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("update user_product").
+		WithArgs(toId, fromId, cupId, 1).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	resolvedAt := time.Now().UTC().Truncate(time.Microsecond)
+	mock.ExpectQuery("update trade").
+		WithArgs(tradeId, domain.TradeStatusAccepted).
+		WillReturnRows(pgxmock.NewRows([]string{"resolved_at"}).AddRow(resolvedAt))
+
+	mock.ExpectCommit()
+
+	trade, err := storage.AcceptTrade(context.Background(), toName, tradeId)
+	require.NoError(t, err)
+	require.Equal(t, domain.Trade{
+		Id:         tradeId,
+		From:       fromName,
+		To:         toName,
+		Offered:    domain.TradeSide{Items: []domain.TradeItem{{Item: "pen", Quantity: 2}}, Coins: 30},
+		Requested:  domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+		Status:     domain.TradeStatusAccepted,
+		CreatedAt:  createdAt,
+		ExpiresAt:  expiresAt,
+		ResolvedAt: &resolvedAt,
+	}, trade)
+
+	// only the recipient resolves the trade
+	expectTrade(fromName)
+	mock.ExpectRollback()
+
+	_, err = storage.AcceptTrade(context.Background(), fromName, tradeId)
+	require.ErrorIs(t, err, customErrors.ErrForbidden)
+
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,1097 @@
+// Package storagetest contains the conformance suite that every
+// AuthStorage and ShopStorage backend has to pass.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/services"
+)
+
+// PostgresDSNEnv names the environment variable with the connection string
+// of the database used by the tests that need a live postgres.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// PostgresDSN returns the connection string from PostgresDSNEnv and skips
+// the test when it is not set.
+func PostgresDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set, skipping the test against a live postgres", PostgresDSNEnv)
+	}
+
+	return dsn
+}
+
+type Storages struct {
+	Auth     services.AuthStorage
+	Shop     services.ShopStorage
+	Products services.ProductStorage
+	Ledger   services.LedgerStorage
+	Trades   services.TradeStorage
+}
+
+// Run runs the suite against the given storages. Every test creates its own
+// users, so the suite may run against a database that already has data.
+func Run(t *testing.T, storages Storages) {
+	suffix := time.Now().UnixNano()
+	newUser := func(t *testing.T, name string) string {
+		username := fmt.Sprintf("%s_%d", name, suffix)
+		err := storages.Auth.CreateUser(context.Background(), domain.UserCredantials{
+			UserName: username,
+			Password: "password_" + name,
+		})
+		require.NoError(t, err)
+
+		return username
+	}
+
+	t.Run("Users", func(t *testing.T) {
+		testUsers(t, storages, newUser)
+	})
+	t.Run("Roles", func(t *testing.T) {
+		testRoles(t, storages, newUser)
+	})
+	t.Run("Passwords", func(t *testing.T) {
+		testPasswords(t, storages, newUser)
+	})
+	t.Run("Info", func(t *testing.T) {
+		testInfo(t, storages, newUser)
+	})
+	t.Run("SendCoin", func(t *testing.T) {
+		testSendCoin(t, storages, newUser)
+	})
+	t.Run("BuyItem", func(t *testing.T) {
+		testBuyItem(t, storages, newUser)
+	})
+	t.Run("BuyCart", func(t *testing.T) {
+		testBuyCart(t, storages, newUser)
+	})
+	t.Run("Refunds", func(t *testing.T) {
+		testRefunds(t, storages, newUser)
+	})
+	t.Run("Gifts", func(t *testing.T) {
+		testGifts(t, storages, newUser)
+	})
+	t.Run("Trades", func(t *testing.T) {
+		testTrades(t, storages, newUser)
+	})
+	t.Run("History", func(t *testing.T) {
+		testHistory(t, storages, newUser)
+	})
+	t.Run("Catalog", func(t *testing.T) {
+		testCatalog(t, storages)
+	})
+	t.Run("Products", func(t *testing.T) {
+		testProducts(t, storages, newUser)
+	})
+	t.Run("Idempotency", func(t *testing.T) {
+		testIdempotency(t, storages, newUser)
+	})
+	t.Run("Ledger", func(t *testing.T) {
+		testLedger(t, storages, newUser)
+	})
+	t.Run("Concurrency", func(t *testing.T) {
+		testConcurrency(t, storages, newUser)
+	})
+}
+
+type newUserFunc func(t *testing.T, name string) string
+
+func testUsers(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "users")
+
+	ok, err := storages.Auth.HasUser(ctx, username)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	password, err := storages.Auth.GetPassword(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, "password_users", password)
+
+	user, err := storages.Auth.GetUser(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, username, user.Name)
+	require.NotZero(t, user.Id)
+
+	err = storages.Auth.CreateUser(ctx, domain.UserCredantials{UserName: username, Password: "other"})
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	err = storages.Auth.CreateUser(ctx, domain.UserCredantials{UserName: "ab", Password: "password"})
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+
+	ok, err = storages.Auth.HasUser(ctx, username+"_unknown")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = storages.Auth.GetPassword(ctx, username+"_unknown")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Auth.GetUser(ctx, username+"_unknown")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+}
+
+func testRoles(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "roles")
+
+	user, err := storages.Auth.GetUser(ctx, username)
+	require.NoError(t, err)
+	require.Empty(t, user.Roles)
+
+	for _, role := range []string{domain.RoleSupport, domain.RoleAdmin, domain.RoleAdmin} {
+		err = storages.Auth.GrantRole(ctx, username, role)
+		require.NoError(t, err)
+	}
+
+	user, err = storages.Auth.GetUser(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, []string{domain.RoleAdmin, domain.RoleSupport}, user.Roles)
+
+	err = storages.Auth.RevokeRole(ctx, username, domain.RoleSupport)
+	require.NoError(t, err)
+	err = storages.Auth.RevokeRole(ctx, username, domain.RoleService)
+	require.NoError(t, err)
+
+	user, err = storages.Auth.GetUser(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, []string{domain.RoleAdmin}, user.Roles)
+
+	err = storages.Auth.GrantRole(ctx, username, "superuser")
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+
+	err = storages.Auth.GrantRole(ctx, username+"_unknown", domain.RoleAdmin)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = storages.Auth.RevokeRole(ctx, username+"_unknown", domain.RoleAdmin)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+}
+
+func testPasswords(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "passwords")
+
+	err := storages.Auth.UpdatePassword(ctx, username, "changed")
+	require.NoError(t, err)
+
+	password, err := storages.Auth.GetPassword(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, "changed", password)
+
+	err = storages.Auth.UpdatePassword(ctx, username+"_unknown", "changed")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	// the password was changed after the rehashed one was read
+	err = storages.Auth.RehashPassword(ctx, username, "password_passwords", "rehashed")
+	require.NoError(t, err)
+
+	password, err = storages.Auth.GetPassword(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, "changed", password)
+
+	err = storages.Auth.RehashPassword(ctx, username, "changed", "rehashed")
+	require.NoError(t, err)
+
+	password, err = storages.Auth.GetPassword(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, "rehashed", password)
+
+	user, err := storages.Auth.GetUser(ctx, username)
+	require.NoError(t, err)
+
+	hash := fmt.Sprintf("hash_%s", username)
+	for _, reset := range []domain.PasswordReset{
+		{Hash: hash + "_replaced", UserId: user.Id, ExpiresAt: time.Now().Add(time.Hour)},
+		{Hash: hash, UserId: user.Id, ExpiresAt: time.Now().Add(time.Hour)},
+		{Hash: hash + "_expired", UserId: user.Id, ExpiresAt: time.Now().Add(-time.Hour)},
+	} {
+		err = storages.Auth.CreatePasswordReset(ctx, reset)
+		require.NoError(t, err)
+	}
+
+	// only the last reset issued to the user is valid
+	for _, invalidHash := range []string{hash + "_replaced", hash + "_expired"} {
+		_, err = storages.Auth.GetPasswordReset(ctx, invalidHash)
+		require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+		_, err = storages.Auth.ResetPassword(ctx, invalidHash, "reset")
+		require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+	}
+
+	err = storages.Auth.CreatePasswordReset(ctx, domain.PasswordReset{
+		Hash:      hash,
+		UserId:    user.Id,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	reset, err := storages.Auth.GetPasswordReset(ctx, hash)
+	require.NoError(t, err)
+	require.Equal(t, user.Id, reset.UserId)
+
+	resetUser, err := storages.Auth.ResetPassword(ctx, hash, "reset")
+	require.NoError(t, err)
+	require.Equal(t, user.Id, resetUser.Id)
+	require.Equal(t, username, resetUser.Name)
+
+	password, err = storages.Auth.GetPassword(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, "reset", password)
+
+	_, err = storages.Auth.ResetPassword(ctx, hash, "again")
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+	_, err = storages.Auth.GetPasswordReset(ctx, hash)
+	require.ErrorIs(t, err, customErrors.ErrDataNotValid)
+}
+
+func testInfo(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "info")
+
+	info, err := storages.Shop.GetInfo(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, domain.InventoryInfo{
+		Coins:     1000,
+		Inventory: []domain.Item{},
+		CoinHistory: domain.SentRecievedHistory{
+			Recieved: []domain.RecievedCoins{},
+			Sent:     []domain.SentCoins{},
+		},
+		GiftHistory: domain.SentRecievedGifts{
+			Recieved: []domain.RecievedGift{},
+			Sent:     []domain.SentGift{},
+		},
+	}, info)
+
+	_, err = storages.Shop.GetInfo(ctx, username+"_unknown")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+}
+
+func testSendCoin(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	sender := newUser(t, "sender")
+	recipient := newUser(t, "recipient")
+
+	for _, amount := range []int{10, 20} {
+		err := storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient, Amount: amount},
+			domain.IdempotencyKey{})
+		require.NoError(t, err)
+	}
+
+	err := storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient, Amount: 971},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	err = storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient + "_unknown", Amount: 1},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = storages.Shop.SendCoin(ctx, domain.Transaction{From: sender + "_unknown", To: recipient, Amount: 1},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	info, err := storages.Shop.GetInfo(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, 970, info.Coins)
+	require.Equal(t, []domain.SentCoins{{To: recipient, Amount: 20}, {To: recipient, Amount: 10}},
+		info.CoinHistory.Sent)
+	require.Empty(t, info.CoinHistory.Recieved)
+
+	info, err = storages.Shop.GetInfo(ctx, recipient)
+	require.NoError(t, err)
+	require.Equal(t, 1030, info.Coins)
+	require.Equal(t, []domain.RecievedCoins{{From: sender, Amount: 20}, {From: sender, Amount: 10}},
+		info.CoinHistory.Recieved)
+	require.Empty(t, info.CoinHistory.Sent)
+}
+
+func testBuyItem(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "buyer")
+
+	var receipt domain.PurchaseReceipt
+	for _, item := range []string{"pen", "pen", "cup", "pink-hoody"} {
+		previousId := receipt.Id
+
+		var err error
+		receipt, err = storages.Shop.BuyItem(ctx, username, item, domain.IdempotencyKey{})
+		require.NoError(t, err)
+		require.Greater(t, receipt.Id, previousId)
+	}
+	require.Equal(t, "pink-hoody", receipt.Item)
+	require.Equal(t, 500, receipt.Price)
+	require.Equal(t, 460, receipt.Balance)
+	require.WithinDuration(t, time.Now(), receipt.BoughtAt, time.Minute)
+
+	_, err := storages.Shop.BuyItem(ctx, username, "pink-hoody", domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	_, err = storages.Shop.BuyItem(ctx, username, "unknown-item", domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Shop.BuyItem(ctx, username+"_unknown", "pen", domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	info, err := storages.Shop.GetInfo(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, 460, info.Coins)
+	require.Equal(t, []domain.Item{
+		{Type: "pink-hoody", Quantity: 1},
+		{Type: "cup", Quantity: 1},
+		{Type: "pen", Quantity: 2},
+	}, info.Inventory)
+}
+
+func testBuyCart(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "cart_buyer")
+
+	receipt, err := storages.Shop.BuyCart(ctx, username, domain.Cart{Lines: []domain.CartLine{
+		{Item: "pen", Quantity: 5},
+		{Item: "cup", Quantity: 2},
+	}}, domain.IdempotencyKey{})
+	require.NoError(t, err)
+	require.Equal(t, domain.CartReceipt{
+		Lines: []domain.CartReceiptLine{
+			{Item: "pen", Quantity: 5, Price: 10, Cost: 50},
+			{Item: "cup", Quantity: 2, Price: 20, Cost: 40},
+		},
+		Total:   90,
+		Balance: 910,
+	}, receipt)
+
+	// a failing line cancels the whole cart
+	_, err = storages.Shop.BuyCart(ctx, username, domain.Cart{Lines: []domain.CartLine{
+		{Item: "pen", Quantity: 1},
+		{Item: "unknown-item", Quantity: 1},
+	}}, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Shop.BuyCart(ctx, username, domain.Cart{Lines: []domain.CartLine{
+		{Item: "pen", Quantity: 1},
+		{Item: "pink-hoody", Quantity: 2},
+	}}, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	_, err = storages.Shop.BuyCart(ctx, username+"_unknown", domain.Cart{Lines: []domain.CartLine{
+		{Item: "pen", Quantity: 1},
+	}}, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	key := domain.IdempotencyKey{
+		Key:         "cart-key",
+		RequestHash: "cart-hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	cart := domain.Cart{Lines: []domain.CartLine{{Item: "book", Quantity: 1}}}
+	receipt, err = storages.Shop.BuyCart(ctx, username, cart, key)
+	require.NoError(t, err)
+	require.Equal(t, 860, receipt.Balance)
+
+	replayed, err := storages.Shop.BuyCart(ctx, username, cart, key)
+	require.NoError(t, err)
+	require.Equal(t, receipt, replayed)
+
+	info, err := storages.Shop.GetInfo(ctx, username)
+	require.NoError(t, err)
+	require.Equal(t, 860, info.Coins)
+	require.ElementsMatch(t, []domain.Item{
+		{Type: "pen", Quantity: 5},
+		{Type: "cup", Quantity: 2},
+		{Type: "book", Quantity: 1},
+	}, info.Inventory)
+}
+
+func testRefunds(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	buyer := newUser(t, "refund_buyer")
+	other := newUser(t, "refund_other")
+	name := buyer + "_item"
+	window := time.Now().Add(-time.Hour)
+
+	_, err := storages.Products.CreateProduct(ctx, buyer, domain.Product{Name: name, Price: 30, Available: true})
+	require.NoError(t, err)
+
+	purchase, err := storages.Shop.BuyItem(ctx, buyer, name, domain.IdempotencyKey{})
+	require.NoError(t, err)
+	kept, err := storages.Shop.BuyItem(ctx, buyer, "pen", domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	// the price paid is refunded, not the current one
+	price := 45
+	_, err = storages.Products.UpdateProduct(ctx, name, domain.ProductUpdate{
+		Actor:  buyer,
+		Action: domain.ProductActionReprice,
+		Price:  &price,
+	})
+	require.NoError(t, err)
+
+	_, err = storages.Shop.RefundPurchase(ctx, other, purchase.Id, window)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Shop.RefundPurchase(ctx, buyer, kept.Id, time.Now().Add(time.Hour))
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	refund, err := storages.Shop.RefundPurchase(ctx, buyer, purchase.Id, window)
+	require.NoError(t, err)
+	require.Equal(t, purchase.Id, refund.PurchaseId)
+	require.Equal(t, name, refund.Item)
+	require.Equal(t, 30, refund.Amount)
+	require.Equal(t, 990, refund.Balance)
+	require.WithinDuration(t, time.Now(), refund.RefundedAt, time.Minute)
+
+	_, err = storages.Shop.RefundPurchase(ctx, buyer, purchase.Id, window)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	_, err = storages.Shop.RefundPurchase(ctx, buyer, purchase.Id+1000000, window)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	info, err := storages.Shop.GetInfo(ctx, buyer)
+	require.NoError(t, err)
+	require.Equal(t, 990, info.Coins)
+	require.Equal(t, []domain.Item{{Type: "pen", Quantity: 1}}, info.Inventory)
+
+	activity, err := storages.Ledger.GetActivity(ctx)
+	require.NoError(t, err)
+	for _, account := range activity {
+		if account.UserName == buyer {
+			require.Equal(t, account.Cached, account.Breakdown.Expected())
+			require.Equal(t, account.Cached, account.Ledger)
+		}
+	}
+}
+
+func testGifts(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	sender := newUser(t, "gift_sender")
+	recipient := newUser(t, "gift_recipient")
+
+	receipt, err := storages.Shop.GiftItem(ctx, domain.Gift{From: sender, To: recipient, Item: "cup"},
+		domain.IdempotencyKey{})
+	require.NoError(t, err)
+	require.NotZero(t, receipt.Id)
+	require.Equal(t, recipient, receipt.To)
+	require.Equal(t, "cup", receipt.Item)
+	require.Equal(t, 20, receipt.Price)
+	require.Equal(t, 980, receipt.Balance)
+	require.WithinDuration(t, time.Now(), receipt.SentAt, time.Minute)
+
+	_, err = storages.Shop.GiftItem(ctx, domain.Gift{From: sender, To: recipient + "_unknown", Item: "cup"},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Shop.GiftItem(ctx, domain.Gift{From: sender, To: recipient, Item: "unknown"},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	err = storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient, Amount: 900},
+		domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	// the sender pays, not the recipient
+	_, err = storages.Shop.GiftItem(ctx, domain.Gift{From: sender, To: recipient, Item: "powerbank"},
+		domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	senderInfo, err := storages.Shop.GetInfo(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, 80, senderInfo.Coins)
+	require.Equal(t, []domain.Item{}, senderInfo.Inventory)
+	require.Equal(t, []domain.SentGift{{To: recipient, Item: "cup"}}, senderInfo.GiftHistory.Sent)
+	require.Equal(t, []domain.RecievedGift{}, senderInfo.GiftHistory.Recieved)
+
+	recipientInfo, err := storages.Shop.GetInfo(ctx, recipient)
+	require.NoError(t, err)
+	require.Equal(t, 1900, recipientInfo.Coins)
+	require.Equal(t, []domain.Item{{Type: "cup", Quantity: 1}}, recipientInfo.Inventory)
+	require.Equal(t, []domain.RecievedGift{{From: sender, Item: "cup"}}, recipientInfo.GiftHistory.Recieved)
+	require.Equal(t, []domain.SentGift{}, recipientInfo.GiftHistory.Sent)
+
+	// the recipient has not paid for the gift, so there is nothing to refund
+	_, err = storages.Shop.RefundPurchase(ctx, recipient, receipt.Id, time.Now().Add(-time.Hour))
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	activity, err := storages.Ledger.GetActivity(ctx)
+	require.NoError(t, err)
+	for _, account := range activity {
+		if account.UserName == sender || account.UserName == recipient {
+			require.Equal(t, account.Cached, account.Breakdown.Expected())
+			require.Equal(t, account.Cached, account.Ledger)
+		}
+	}
+}
+
+func testTrades(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	proposer := newUser(t, "trade_proposer")
+	recipient := newUser(t, "trade_recipient")
+	other := newUser(t, "trade_other")
+	expiresAt := time.Now().Add(time.Hour)
+
+	pen, err := storages.Shop.BuyItem(ctx, proposer, "pen", domain.IdempotencyKey{})
+	require.NoError(t, err)
+	_, err = storages.Shop.BuyItem(ctx, proposer, "pen", domain.IdempotencyKey{})
+	require.NoError(t, err)
+	_, err = storages.Shop.BuyItem(ctx, recipient, "cup", domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	offer := domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Offered:   domain.TradeSide{Items: []domain.TradeItem{{Item: "pen", Quantity: 2}}, Coins: 30},
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+		ExpiresAt: expiresAt,
+	}
+
+	trade, err := storages.Trades.CreateTrade(ctx, offer)
+	require.NoError(t, err)
+	require.NotZero(t, trade.Id)
+	require.Equal(t, proposer, trade.From)
+	require.Equal(t, recipient, trade.To)
+	require.Equal(t, offer.Offered, trade.Offered)
+	require.Equal(t, domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}}, trade.Requested)
+	require.Equal(t, domain.TradeStatusPending, trade.Status)
+	require.WithinDuration(t, time.Now(), trade.CreatedAt, time.Minute)
+	require.WithinDuration(t, expiresAt, trade.ExpiresAt, time.Millisecond)
+	require.Nil(t, trade.ResolvedAt)
+
+	// the proposer has to own what is offered, the recipient is checked on acceptance
+	_, err = storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Offered:   domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+		ExpiresAt: expiresAt,
+	})
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	_, err = storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Offered:   domain.TradeSide{Coins: 2000},
+		ExpiresAt: expiresAt,
+	})
+	require.ErrorIs(t, err, customErrors.ErrInsufficientFunds)
+
+	_, err = storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "unknown", Quantity: 1}}},
+		ExpiresAt: expiresAt,
+	})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient + "_unknown",
+		Offered:   domain.TradeSide{Coins: 10},
+		ExpiresAt: expiresAt,
+	})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	trades, err := storages.Trades.GetTrades(ctx, recipient)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Trade{trade}, trades)
+
+	trades, err = storages.Trades.GetTrades(ctx, other)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Trade{}, trades)
+
+	_, err = storages.Trades.GetTrade(ctx, other, trade.Id)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Trades.AcceptTrade(ctx, other, trade.Id)
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	_, err = storages.Trades.AcceptTrade(ctx, proposer, trade.Id)
+	require.ErrorIs(t, err, customErrors.ErrForbidden)
+
+	accepted, err := storages.Trades.AcceptTrade(ctx, recipient, trade.Id)
+	require.NoError(t, err)
+	require.Equal(t, domain.TradeStatusAccepted, accepted.Status)
+	require.NotNil(t, accepted.ResolvedAt)
+
+	_, err = storages.Trades.AcceptTrade(ctx, recipient, trade.Id)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	proposerInfo, err := storages.Shop.GetInfo(ctx, proposer)
+	require.NoError(t, err)
+	require.Equal(t, 950, proposerInfo.Coins)
+	require.Equal(t, []domain.Item{{Type: "cup", Quantity: 1}}, proposerInfo.Inventory)
+	require.Equal(t, domain.SentRecievedHistory{
+		Recieved: []domain.RecievedCoins{{From: recipient, Amount: 0, TradeId: &trade.Id}},
+		Sent:     []domain.SentCoins{{To: recipient, Amount: 30, TradeId: &trade.Id}},
+	}, proposerInfo.CoinHistory)
+
+	recipientInfo, err := storages.Shop.GetInfo(ctx, recipient)
+	require.NoError(t, err)
+	require.Equal(t, 1010, recipientInfo.Coins)
+	require.Equal(t, []domain.Item{{Type: "pen", Quantity: 2}}, recipientInfo.Inventory)
+
+	// traded items have not been paid for by their new owner
+	_, err = storages.Shop.RefundPurchase(ctx, recipient, pen.Id, time.Now().Add(-time.Hour))
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	// the recipient no longer has the cup, so the offer is verified once more on acceptance
+	pending, err := storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Offered:   domain.TradeSide{Coins: 10},
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	_, err = storages.Trades.AcceptTrade(ctx, recipient, pending.Id)
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	declined, err := storages.Trades.DeclineTrade(ctx, recipient, pending.Id)
+	require.NoError(t, err)
+	require.Equal(t, domain.TradeStatusDeclined, declined.Status)
+	require.NotNil(t, declined.ResolvedAt)
+
+	_, err = storages.Trades.DeclineTrade(ctx, recipient, pending.Id)
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	expired, err := storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      proposer,
+		To:        recipient,
+		Offered:   domain.TradeSide{Coins: 10},
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, domain.TradeStatusExpired, expired.Status)
+
+	_, err = storages.Trades.AcceptTrade(ctx, recipient, expired.Id)
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	stored, err := storages.Trades.GetTrade(ctx, proposer, expired.Id)
+	require.NoError(t, err)
+	require.Equal(t, domain.TradeStatusExpired, stored.Status)
+
+	trades, err = storages.Trades.GetTrades(ctx, proposer)
+	require.NoError(t, err)
+	require.Len(t, trades, 3)
+	require.Equal(t, []int64{expired.Id, pending.Id, trade.Id}, []int64{trades[0].Id, trades[1].Id, trades[2].Id})
+
+	// a trade with no coins is listed in the history of both users as well
+	itemOnly, err := storages.Trades.CreateTrade(ctx, domain.TradeOffer{
+		From:      recipient,
+		To:        proposer,
+		Offered:   domain.TradeSide{Items: []domain.TradeItem{{Item: "pen", Quantity: 1}}},
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	_, err = storages.Trades.AcceptTrade(ctx, proposer, itemOnly.Id)
+	require.NoError(t, err)
+
+	for _, side := range []struct {
+		username     string
+		direction    string
+		counterparty string
+	}{
+		{recipient, domain.HistoryDirectionSent, proposer},
+		{proposer, domain.HistoryDirectionReceived, recipient},
+	} {
+		page, err := storages.Shop.GetHistory(ctx, side.username, domain.HistoryFilter{Limit: domain.MaxHistoryLimit})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.Entries)
+
+		entry := page.Entries[0]
+		require.NotNil(t, entry.TradeId, side.username)
+		require.Equal(t, itemOnly.Id, *entry.TradeId, side.username)
+		require.Equal(t, side.direction, entry.Direction, side.username)
+		require.Equal(t, side.counterparty, entry.Counterparty, side.username)
+		require.Equal(t, 0, entry.Amount, side.username)
+	}
+
+	activity, err := storages.Ledger.GetActivity(ctx)
+	require.NoError(t, err)
+	for _, account := range activity {
+		if account.UserName == proposer || account.UserName == recipient {
+			require.Equal(t, account.Cached, account.Breakdown.Expected())
+			require.Equal(t, account.Cached, account.Ledger)
+		}
+	}
+}
+
+func testHistory(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	username := newUser(t, "history")
+	friend := newUser(t, "friend")
+	other := newUser(t, "other")
+
+	transfers := []domain.Transaction{
+		{From: username, To: friend, Amount: 10},
+		{From: friend, To: username, Amount: 20},
+		{From: username, To: other, Amount: 30},
+		{From: other, To: username, Amount: 40},
+		{From: username, To: friend, Amount: 50},
+	}
+	for _, transfer := range transfers {
+		err := storages.Shop.SendCoin(ctx, transfer, domain.IdempotencyKey{})
+		require.NoError(t, err)
+	}
+
+	amounts := func(entries []domain.HistoryEntry) []int {
+		result := make([]int, 0, len(entries))
+		for _, entry := range entries {
+			result = append(result, entry.Amount)
+		}
+		return result
+	}
+
+	filter := domain.HistoryFilter{Limit: 2}
+	collected := make([]domain.HistoryEntry, 0)
+	for range transfers {
+		page, err := storages.Shop.GetHistory(ctx, username, filter)
+		require.NoError(t, err)
+		collected = append(collected, page.Entries...)
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor, err := domain.DecodeHistoryCursor(page.NextCursor)
+		require.NoError(t, err)
+		filter.Cursor = &cursor
+	}
+	require.Equal(t, []int{50, 40, 30, 20, 10}, amounts(collected))
+	require.Equal(t, domain.HistoryEntry{
+		Id:           collected[0].Id,
+		Direction:    domain.HistoryDirectionSent,
+		Counterparty: friend,
+		Amount:       50,
+		SentAt:       collected[0].SentAt,
+	}, collected[0])
+	require.Equal(t, domain.HistoryDirectionReceived, collected[1].Direction)
+	require.Equal(t, other, collected[1].Counterparty)
+
+	testData := []struct {
+		name     string
+		filter   domain.HistoryFilter
+		expected []int
+	}{
+		{"sent", domain.HistoryFilter{Direction: domain.HistoryDirectionSent}, []int{50, 30, 10}},
+		{"received", domain.HistoryFilter{Direction: domain.HistoryDirectionReceived}, []int{40, 20}},
+		{"counterparty", domain.HistoryFilter{Counterparty: friend}, []int{50, 20, 10}},
+		{"amount range", domain.HistoryFilter{MinAmount: intPtr(20), MaxAmount: intPtr(40)}, []int{40, 30, 20}},
+		{"until now", domain.HistoryFilter{To: time.Now().Add(time.Minute)}, []int{50, 40, 30, 20, 10}},
+		{"from now on", domain.HistoryFilter{From: time.Now().Add(time.Minute)}, []int{}},
+	}
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.filter.Limit = domain.MaxHistoryLimit
+
+			page, err := storages.Shop.GetHistory(ctx, username, testCase.filter)
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, amounts(page.Entries))
+			require.Empty(t, page.NextCursor)
+		})
+	}
+
+	_, err := storages.Shop.GetHistory(ctx, username+"_unknown", domain.HistoryFilter{Limit: 1})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func testCatalog(t *testing.T, storages Storages) {
+	ctx := context.Background()
+
+	products, err := storages.Shop.GetProducts(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, products)
+	for i := 1; i < len(products); i++ {
+		require.Less(t, products[i-1].Name, products[i].Name)
+	}
+	require.Contains(t, products, domain.Product{Name: "pink-hoody", Price: 500, Available: true})
+
+	product, err := storages.Shop.GetProduct(ctx, "cup")
+	require.NoError(t, err)
+	require.Equal(t, domain.Product{Name: "cup", Price: 20, Available: true}, product)
+
+	_, err = storages.Shop.GetProduct(ctx, "unknown-product")
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+}
+
+func testProducts(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	admin := newUser(t, "product_admin")
+	buyer := newUser(t, "product_buyer")
+	name := admin + "_item"
+
+	product, err := storages.Products.CreateProduct(ctx, admin, domain.Product{Name: name, Price: 30, Available: true})
+	require.NoError(t, err)
+	require.Equal(t, domain.Product{Name: name, Price: 30, Available: true}, product)
+
+	_, err = storages.Products.CreateProduct(ctx, admin, domain.Product{Name: name, Price: 10, Available: true})
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	_, err = storages.Shop.BuyItem(ctx, buyer, name, domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	price := 40
+	product, err = storages.Products.UpdateProduct(ctx, name, domain.ProductUpdate{
+		Actor:  admin,
+		Action: domain.ProductActionReprice,
+		Price:  &price,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 40, product.Price)
+
+	_, err = storages.Products.UpdateProduct(ctx, name, domain.ProductUpdate{
+		Actor:  admin,
+		Action: domain.ProductActionUpdate,
+		Name:   stringPtr("cup"),
+	})
+	require.ErrorIs(t, err, customErrors.ErrAlreadyExists)
+
+	available := false
+	product, err = storages.Products.UpdateProduct(ctx, name, domain.ProductUpdate{
+		Actor:     admin,
+		Action:    domain.ProductActionArchive,
+		Available: &available,
+	})
+	require.NoError(t, err)
+	require.False(t, product.Available)
+
+	_, err = storages.Shop.BuyItem(ctx, buyer, name, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	info, err := storages.Shop.GetInfo(ctx, buyer)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Item{{Type: name, Quantity: 1}}, info.Inventory)
+
+	product, err = storages.Shop.GetProduct(ctx, name)
+	require.NoError(t, err)
+	require.Equal(t, domain.Product{Name: name, Price: 40, Available: false}, product)
+
+	_, err = storages.Products.UpdateProduct(ctx, name+"_unknown", domain.ProductUpdate{
+		Actor:  admin,
+		Action: domain.ProductActionReprice,
+		Price:  &price,
+	})
+	require.ErrorIs(t, err, customErrors.ErrDoesNotExist)
+
+	changes, err := storages.Products.GetProductAudit(ctx, name)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	actions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		require.Equal(t, admin, change.Actor)
+		require.False(t, change.ChangedAt.IsZero())
+		actions = append(actions, change.Action)
+	}
+	require.Equal(t, []string{domain.ProductActionArchive, domain.ProductActionReprice, domain.ProductActionCreate},
+		actions)
+	require.Nil(t, changes[2].Before)
+	require.Equal(t, &domain.Product{Name: name, Price: 30, Available: true}, changes[1].Before)
+	require.Equal(t, domain.Product{Name: name, Price: 40, Available: true}, changes[1].After)
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func testIdempotency(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	sender := newUser(t, "idempotent_sender")
+	recipient := newUser(t, "idempotent_recipient")
+
+	key := domain.IdempotencyKey{
+		Key:         "send-key",
+		RequestHash: "send-hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	for i := 0; i < 2; i++ {
+		err := storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient, Amount: 100}, key)
+		require.NoError(t, err)
+	}
+
+	key = domain.IdempotencyKey{
+		Key:         "buy-key",
+		RequestHash: "buy-hash",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	receipt, err := storages.Shop.BuyItem(ctx, sender, "book", key)
+	require.NoError(t, err)
+
+	replayed, err := storages.Shop.BuyItem(ctx, sender, "book", key)
+	require.NoError(t, err)
+	require.Equal(t, receipt, replayed)
+
+	key.RequestHash = "other-hash"
+	_, err = storages.Shop.BuyItem(ctx, sender, "cup", key)
+	require.ErrorIs(t, err, customErrors.ErrIdempotencyKeyReused)
+
+	info, err := storages.Shop.GetInfo(ctx, sender)
+	require.NoError(t, err)
+	require.Equal(t, 850, info.Coins)
+	require.Len(t, info.CoinHistory.Sent, 1)
+	require.Equal(t, []domain.Item{{Type: "book", Quantity: 1}}, info.Inventory)
+
+	// replays return the saved receipts even after the product has been archived
+	buyer := newUser(t, "idempotent_buyer")
+	name := buyer + "_item"
+	_, err = storages.Products.CreateProduct(ctx, buyer, domain.Product{Name: name, Price: 30, Available: true})
+	require.NoError(t, err)
+
+	itemKey := domain.IdempotencyKey{Key: "archived-item", RequestHash: "item-hash", ExpiresAt: time.Now().Add(time.Hour)}
+	itemReceipt, err := storages.Shop.BuyItem(ctx, buyer, name, itemKey)
+	require.NoError(t, err)
+
+	cart := domain.Cart{Lines: []domain.CartLine{{Item: name, Quantity: 2}}}
+	cartKey := domain.IdempotencyKey{Key: "archived-cart", RequestHash: "cart-hash", ExpiresAt: time.Now().Add(time.Hour)}
+	cartReceipt, err := storages.Shop.BuyCart(ctx, buyer, cart, cartKey)
+	require.NoError(t, err)
+
+	gift := domain.Gift{From: buyer, To: recipient, Item: name}
+	giftKey := domain.IdempotencyKey{Key: "archived-gift", RequestHash: "gift-hash", ExpiresAt: time.Now().Add(time.Hour)}
+	giftReceipt, err := storages.Shop.GiftItem(ctx, gift, giftKey)
+	require.NoError(t, err)
+
+	available := false
+	_, err = storages.Products.UpdateProduct(ctx, name, domain.ProductUpdate{
+		Actor:     buyer,
+		Action:    domain.ProductActionArchive,
+		Available: &available,
+	})
+	require.NoError(t, err)
+
+	_, err = storages.Shop.BuyItem(ctx, buyer, name, domain.IdempotencyKey{})
+	require.ErrorIs(t, err, customErrors.ErrNotAvailable)
+
+	replayed, err = storages.Shop.BuyItem(ctx, buyer, name, itemKey)
+	require.NoError(t, err)
+	require.Equal(t, itemReceipt, replayed)
+
+	replayedCart, err := storages.Shop.BuyCart(ctx, buyer, cart, cartKey)
+	require.NoError(t, err)
+	require.Equal(t, cartReceipt, replayedCart)
+
+	replayedGift, err := storages.Shop.GiftItem(ctx, gift, giftKey)
+	require.NoError(t, err)
+	require.Equal(t, giftReceipt, replayedGift)
+
+	info, err = storages.Shop.GetInfo(ctx, buyer)
+	require.NoError(t, err)
+	require.Equal(t, 880, info.Coins)
+}
+
+func testLedger(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	sender := newUser(t, "ledger_sender")
+	recipient := newUser(t, "ledger_recipient")
+
+	err := storages.Shop.SendCoin(ctx, domain.Transaction{From: sender, To: recipient, Amount: 100},
+		domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	_, err = storages.Shop.BuyItem(ctx, recipient, "cup", domain.IdempotencyKey{})
+	require.NoError(t, err)
+
+	balances, err := storages.Ledger.GetBalances(ctx)
+	require.NoError(t, err)
+
+	total := 0
+	userBalances := make(map[string]int)
+	for _, balance := range balances {
+		assert.True(t, balance.IsConsistent(), "inconsistent balance %+v", balance)
+
+		total += balance.Balance
+		if balance.Account.Kind == domain.LedgerAccountUser {
+			userBalances[balance.UserName] = balance.Balance
+		}
+	}
+	require.Zero(t, total)
+	require.Equal(t, 900, userBalances[sender])
+	require.Equal(t, 1080, userBalances[recipient])
+
+	activity, err := storages.Ledger.GetActivity(ctx)
+	require.NoError(t, err)
+
+	userActivity := make(map[string]domain.AccountActivity)
+	for _, account := range activity {
+		userActivity[account.UserName] = account
+	}
+	require.Equal(t, domain.BalanceBreakdown{Grant: domain.SignupGrant, Sent: 100}, userActivity[sender].Breakdown)
+	require.Equal(t, domain.BalanceBreakdown{Grant: domain.SignupGrant, Received: 100, Purchases: 20},
+		userActivity[recipient].Breakdown)
+	for _, name := range []string{sender, recipient} {
+		account := userActivity[name]
+		require.Equal(t, account.Cached, account.Ledger)
+		require.Equal(t, account.Cached, account.Breakdown.Expected())
+	}
+}
+
+func testConcurrency(t *testing.T, storages Storages, newUser newUserFunc) {
+	ctx := context.Background()
+
+	first := newUser(t, "concurrent_first")
+	second := newUser(t, "concurrent_second")
+
+	const workers = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			err := storages.Shop.SendCoin(ctx, domain.Transaction{From: first, To: second, Amount: 7},
+				domain.IdempotencyKey{})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			err := storages.Shop.SendCoin(ctx, domain.Transaction{From: second, To: first, Amount: 5},
+				domain.IdempotencyKey{})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := storages.Shop.BuyItem(ctx, first, "socks", domain.IdempotencyKey{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	firstInfo, err := storages.Shop.GetInfo(ctx, first)
+	require.NoError(t, err)
+	require.Equal(t, 1000-workers*(7-5+10), firstInfo.Coins)
+	require.Equal(t, []domain.Item{{Type: "socks", Quantity: workers}}, firstInfo.Inventory)
+
+	secondInfo, err := storages.Shop.GetInfo(ctx, second)
+	require.NoError(t, err)
+	require.Equal(t, 1000+workers*(7-5), secondInfo.Coins)
+	require.Len(t, secondInfo.CoinHistory.Sent, workers)
+	require.Len(t, secondInfo.CoinHistory.Recieved, workers)
+}
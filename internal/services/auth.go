@@ -3,13 +3,16 @@ package services
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/argon2"
 
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
 	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
@@ -19,148 +22,496 @@ type AuthStorage interface {
 	CreateUser(ctx context.Context, userCreds domain.UserCredantials) error
 	GetPassword(ctx context.Context, email string) (string, error)
 	HasUser(ctx context.Context, name string) (bool, error)
+	GetUser(ctx context.Context, name string) (domain.User, error)
+	GrantRole(ctx context.Context, name string, role string) error
+	RevokeRole(ctx context.Context, name string, role string) error
+	UpdatePassword(ctx context.Context, name string, password string) error
+	RehashPassword(ctx context.Context, name string, oldPassword string, newPassword string) error
+	CreatePasswordReset(ctx context.Context, reset domain.PasswordReset) error
+	GetPasswordReset(ctx context.Context, hash string) (domain.PasswordReset, error)
+	ResetPassword(ctx context.Context, hash string, password string) (domain.User, error)
 }
 
 type AuthService struct {
 	authStorage    AuthStorage
+	keyStorage     KeyStorage
+	sessionStorage SessionStorage
 	logger         *zap.SugaredLogger
 	saltLength     int
-	jwtKey         []byte
+	hashParams     PasswordHashParams
 	expirationTime int
+	refreshTime    int
+	resetTime      int
+	autoSignup     bool
+
+	hashSlots     chan struct{}
+	dummyHashOnce sync.Once
+	dummyHash     passwordHash
+
+	keysMu         sync.RWMutex
+	keys           map[string]domain.SigningKey
+	activeKid      string
+	keysReloadedAt time.Time
+
+	revocationsMu sync.RWMutex
+	revocations   domain.Revocations
 }
 
 func NewAuthService(
 	authStorage AuthStorage,
+	keyStorage KeyStorage,
+	sessionStorage SessionStorage,
 	logger *zap.SugaredLogger,
 	saltLength int,
-	expirationTime int) (*AuthService, error) {
-
-	jwtKey := make([]byte, 16)
-	_, err := rand.Read(jwtKey)
-	if err != nil {
-		return nil, fmt.Errorf("%w (service.NewAuthService): %w", customErrors.ErrFailedToGenJWTKey, err)
-	}
+	hashParams PasswordHashParams,
+	hashConcurrency int,
+	expirationTime int,
+	refreshTime int,
+	resetTime int,
+	autoSignup bool) (*AuthService, error) {
 
 	authService := AuthService{
 		authStorage:    authStorage,
+		keyStorage:     keyStorage,
+		sessionStorage: sessionStorage,
 		logger:         logger,
 		saltLength:     saltLength,
-		jwtKey:         jwtKey,
+		hashParams:     hashParams,
+		hashSlots:      make(chan struct{}, hashConcurrency),
 		expirationTime: expirationTime,
+		refreshTime:    refreshTime,
+		resetTime:      resetTime,
+		autoSignup:     autoSignup,
+		keys:           make(map[string]domain.SigningKey),
+		revocations: domain.Revocations{
+			Tokens:   make(map[string]time.Time),
+			Cutoffs:  make(map[int]time.Time),
+			Sessions: make(map[string]time.Time),
+		},
 	}
 
 	return &authService, nil
 }
 
+// LoginOrCreateUser signs up unknown users if auto-signup is enabled,
+// otherwise it is the same as Login.
 func (authService *AuthService) LoginOrCreateUser(
 	ctx context.Context,
-	userCreds domain.UserCredantials) (string, error) {
+	userCreds domain.UserCredantials) (domain.Tokens, error) {
+	if !authService.autoSignup {
+		return authService.Login(ctx, userCreds)
+	}
+
 	ok, err := authService.authStorage.HasUser(ctx, userCreds.UserName)
 	if err != nil {
-		authService.logger.Errorf("failed to check for user (service.LoginOrCreateUser): %w", err)
-		return "", fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+		authService.logger.Errorf("failed to check for user (service.LoginOrCreateUser): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+	}
+
+	if !ok {
+		err = authService.createUser(ctx, userCreds)
+		// the user could have been created by a concurrent request
+		if errors.Is(err, customErrors.ErrAlreadyExists) {
+			ok = true
+		} else if err != nil {
+			authService.logger.Errorf("failed to create user (service.LoginOrCreateUser): %v", err)
+			return domain.Tokens{}, fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+		}
 	}
 
 	if ok {
 		err = authService.loginUser(ctx, userCreds)
 		if err != nil {
-			authService.logger.Errorf("failed to login user (service.LoginOrCreateUser): %w", err)
-			return "", fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+			authService.logger.Errorf("failed to login user (service.LoginOrCreateUser): %v", err)
+			return domain.Tokens{}, fmt.Errorf("(service.LoginOrCreateUser): %w", err)
 		}
-	} else {
-		err = authService.createUser(ctx, userCreds)
-		if err != nil {
-			authService.logger.Errorf("failed to create user (service.LoginOrCreateUser): %w", err)
-			return "", fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+	}
+
+	tokens, err := authService.issueToken(ctx, userCreds.UserName)
+	if err != nil {
+		return domain.Tokens{}, fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Register creates a new user and returns a token for it,
+// taken names are reported with ErrAlreadyExists.
+func (authService *AuthService) Register(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error) {
+	err := authService.createUser(ctx, userCreds)
+	if err != nil {
+		authService.logger.Infof("failed to register user (service.Register): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Register): %w", err)
+	}
+
+	tokens, err := authService.issueToken(ctx, userCreds.UserName)
+	if err != nil {
+		return domain.Tokens{}, fmt.Errorf("(service.Register): %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Login returns a token for an existing user, unknown users are reported
+// the same way as wrong passwords.
+func (authService *AuthService) Login(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error) {
+	err := authService.loginUser(ctx, userCreds)
+	if err != nil {
+		authService.logger.Infof("failed to login user (service.Login): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Login): %w", err)
+	}
+
+	tokens, err := authService.issueToken(ctx, userCreds.UserName)
+	if err != nil {
+		return domain.Tokens{}, fmt.Errorf("(service.Login): %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Refresh exchanges the refresh token for a new pair of tokens,
+// the roles are taken from the storage so the changes apply without a new login.
+func (authService *AuthService) Refresh(ctx context.Context, refreshToken string) (domain.Tokens, error) {
+	if refreshToken == "" {
+		return domain.Tokens{}, fmt.Errorf("%w (service.Refresh): no refresh token", customErrors.ErrUnauthenticated)
+	}
+
+	nextToken, next, err := authService.newRefreshToken()
+	if err != nil {
+		authService.logger.Errorf("failed to create refresh token (service.Refresh): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Refresh): %w", err)
+	}
+
+	rotated, err := authService.sessionStorage.RotateRefreshToken(ctx, hashToken(refreshToken), next)
+	if errors.Is(err, customErrors.ErrRefreshTokenReused) {
+		authService.logger.Warnf("reused refresh token, session revoked (service.Refresh): %v", err)
+
+		// the access tokens of the session are rejected here right away, not on the next reload
+		reloadErr := authService.LoadRevocations(ctx)
+		if reloadErr != nil {
+			authService.logger.Errorf("failed to reload revocations (service.Refresh): %v", reloadErr)
 		}
+
+		return domain.Tokens{}, fmt.Errorf("(service.Refresh): %w", err)
+	}
+	if err != nil {
+		authService.logger.Infof("failed to rotate refresh token (service.Refresh): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Refresh): %w", err)
 	}
 
-	token, err := authService.createToken(userCreds.UserName)
+	user, err := authService.authStorage.GetUser(ctx, rotated.UserName)
 	if err != nil {
-		authService.logger.Errorf("failed to create session (service.LoginOrCreateUser): %w", err)
-		return "", fmt.Errorf("(service.LoginOrCreateUser): %w", err)
+		authService.logger.Errorf("failed to get user (service.Refresh): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Refresh): %w", err)
 	}
 
-	return token, nil
+	accessToken, accessExpiresAt, err := authService.createToken(ctx, user, rotated.FamilyId)
+	if err != nil {
+		authService.logger.Errorf("failed to create session (service.Refresh): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.Refresh): %w", err)
+	}
+
+	return domain.Tokens{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     nextToken,
+		RefreshExpiresAt: rotated.ExpiresAt,
+	}, nil
 }
 
-func (authService *AuthService) GetNameAndCheck(ctx context.Context, token string) (string, bool) {
-	claims, err := authService.getTokenClaims(token)
+// Authenticate checks the token and returns the user it was issued to.
+func (authService *AuthService) Authenticate(ctx context.Context, token string) (domain.Principal, error) {
+	claims, err := authService.getTokenClaims(ctx, token)
 	if err != nil {
-		authService.logger.Errorf("failed to check session (service.GetNameAndCHeck): %w", err)
-		return "", false
+		authService.logger.Errorf("failed to check session (service.Authenticate): %v", err)
+		return domain.Principal{}, fmt.Errorf("%w (service.Authenticate): %w", customErrors.ErrUnauthenticated, err)
 	}
 
-	name, ok := (*claims)["name"]
+	if claims.Name == "" {
+		authService.logger.Errorf("failed to get name from token (service.Authenticate)")
+		return domain.Principal{}, fmt.Errorf("%w (service.Authenticate): token without name", customErrors.ErrUnauthenticated)
+	}
 
-	if !ok {
-		authService.logger.Errorf("failed to get name from token (service.GetNameAndCHeck): %w", err)
-		return "", false
+	principal := domain.Principal{
+		UserId:    claims.UserId,
+		Name:      claims.Name,
+		Roles:     claims.Roles,
+		TokenId:   claims.Id,
+		SessionId: claims.SessionId,
+		IssuedAt:  claims.issuedAt(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
 	}
 
-	return name.(string), true
+	if authService.isRevoked(principal) {
+		authService.logger.Infof("revoked token used by %s (service.Authenticate)", principal.Name)
+		return domain.Principal{}, fmt.Errorf("%w (service.Authenticate): token revoked", customErrors.ErrUnauthenticated)
+	}
+
+	return principal, nil
 }
 
-func (authService *AuthService) createUser(ctx context.Context, userCreds domain.UserCredantials) error {
-	salt, err := genRandomSalt(authService.saltLength)
+func (authService *AuthService) GetRoles(ctx context.Context, name string) ([]string, error) {
+	user, err := authService.authStorage.GetUser(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("(service.GetRoles): %w", err)
+	}
+
+	return user.Roles, nil
+}
+
+// GrantRole takes effect when the user gets a new token,
+// tokens issued before keep their roles until they expire.
+func (authService *AuthService) GrantRole(ctx context.Context, name string, role string) error {
+	if err := domain.ValidateRole(role); err != nil {
+		return fmt.Errorf("(service.GrantRole): %w", err)
+	}
+
+	err := authService.authStorage.GrantRole(ctx, name, role)
+	if err != nil {
+		authService.logger.Errorf("failed to grant role (service.GrantRole): %v", err)
+		return fmt.Errorf("(service.GrantRole): %w", err)
+	}
+
+	authService.logger.Infof("role %s granted to %s", role, name)
+
+	return nil
+}
+
+func (authService *AuthService) RevokeRole(ctx context.Context, name string, role string) error {
+	if err := domain.ValidateRole(role); err != nil {
+		return fmt.Errorf("(service.RevokeRole): %w", err)
+	}
+
+	err := authService.authStorage.RevokeRole(ctx, name, role)
+	if err != nil {
+		authService.logger.Errorf("failed to revoke role (service.RevokeRole): %v", err)
+		return fmt.Errorf("(service.RevokeRole): %w", err)
+	}
+
+	authService.logger.Infof("role %s revoked from %s", role, name)
+
+	return nil
+}
+
+// issueToken starts a new session, the refresh tokens of the session
+// share the family id kept in the access tokens.
+func (authService *AuthService) issueToken(ctx context.Context, name string) (domain.Tokens, error) {
+	user, err := authService.authStorage.GetUser(ctx, name)
+	if err != nil {
+		authService.logger.Errorf("failed to get user (service.issueToken): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.issueToken): %w", err)
+	}
+
+	familyId, err := randomHex(16)
+	if err != nil {
+		return domain.Tokens{}, fmt.Errorf("%w (service.issueToken): %w", customErrors.ErrFailedToCreateToken, err)
+	}
+
+	refreshToken, refresh, err := authService.newRefreshToken()
+	if err != nil {
+		authService.logger.Errorf("failed to create refresh token (service.issueToken): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.issueToken): %w", err)
+	}
+	refresh.FamilyId = familyId
+	refresh.UserId = user.Id
+	refresh.UserName = user.Name
+
+	err = authService.sessionStorage.CreateRefreshToken(ctx, refresh)
 	if err != nil {
-		authService.logger.Errorf("failed to generate salt (service.createUser): %w", err)
-		return fmt.Errorf("%w (service.createUser): %w", customErrors.ErrInternal, err)
+		authService.logger.Errorf("failed to store refresh token (service.issueToken): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.issueToken): %w", err)
 	}
 
-	hash, err := hashPassword(userCreds.Password, salt)
+	accessToken, accessExpiresAt, err := authService.createToken(ctx, user, familyId)
 	if err != nil {
-		authService.logger.Errorf("failed to hash password (service.createUser): %w", err)
-		return fmt.Errorf("%w (service.createUser): %w", customErrors.ErrInternal, err)
+		authService.logger.Errorf("failed to create session (service.issueToken): %v", err)
+		return domain.Tokens{}, fmt.Errorf("(service.issueToken): %w", err)
 	}
 
-	hashedPassword := append(salt, hash...)
+	return domain.Tokens{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// newRefreshToken returns an opaque token for the client and its record
+// for the storage, only the hash of the token is stored.
+func (authService *AuthService) newRefreshToken() (string, domain.RefreshToken, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", domain.RefreshToken{}, fmt.Errorf("%w (service.newRefreshToken): %w",
+			customErrors.ErrFailedToCreateToken, err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	refresh := domain.RefreshToken{
+		Hash:      hashToken(token),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(authService.refreshTime)),
+	}
+
+	return token, refresh, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(length int) (string, error) {
+	value := make([]byte, length)
+	_, err := rand.Read(value)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(value), nil
+}
+
+func (authService *AuthService) createUser(ctx context.Context, userCreds domain.UserCredantials) error {
+	password, err := authService.encodePassword(ctx, userCreds.Password)
+	if err != nil {
+		authService.logger.Errorf("failed to hash password (service.createUser): %v", err)
+		return fmt.Errorf("(service.createUser): %w", err)
+	}
 
-	userCreds.Password = base64.RawStdEncoding.EncodeToString(hashedPassword)
+	userCreds.Password = password
 
 	err = authService.authStorage.CreateUser(ctx, userCreds)
 	if err != nil {
-		authService.logger.Errorf("failed to create user (service.createUser): %w", err)
+		authService.logger.Errorf("failed to create user (service.createUser): %v", err)
 		return fmt.Errorf("(service.createUser): %w", err)
 	}
 
 	return nil
 }
 
+// hashSlotWait is how long a request waits for a free hashing slot before it is rejected.
+const hashSlotWait = 500 * time.Millisecond
+
+// acquireHashSlot bounds the number of concurrent argon2 computations,
+// each of them takes hashParams.Memory of memory.
+func (authService *AuthService) acquireHashSlot(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(hashSlotWait)
+	defer timer.Stop()
+
+	select {
+	case authService.hashSlots <- struct{}{}:
+		return func() { <-authService.hashSlots }, nil
+	case <-timer.C:
+		authService.logger.Warnf("password hashing saturated (service.acquireHashSlot)")
+		return nil, fmt.Errorf("%w (service.acquireHashSlot): password hashing saturated",
+			customErrors.ErrTooManyRequests)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("(service.acquireHashSlot): %w", ctx.Err())
+	}
+}
+
+// encodePassword returns the salted hash of the password in the form it is stored.
+func (authService *AuthService) encodePassword(ctx context.Context, password string) (string, error) {
+	salt, err := genRandomSalt(authService.saltLength)
+	if err != nil {
+		return "", fmt.Errorf("%w (service.encodePassword): %w", customErrors.ErrInternal, err)
+	}
+
+	release, err := authService.acquireHashSlot(ctx)
+	if err != nil {
+		return "", fmt.Errorf("(service.encodePassword): %w", err)
+	}
+	defer release()
+
+	return newPasswordHash(password, salt, authService.hashParams).String(), nil
+}
+
+// loginUser checks the password, hashes with outdated parameters are replaced
+// once the password is known to be correct.
 func (authService *AuthService) loginUser(ctx context.Context, userCreds domain.UserCredantials) error {
-	expectedPassword, err := authService.authStorage.GetPassword(ctx, userCreds.UserName)
+	expectedPassword, expectedHash, err := authService.checkPassword(ctx, userCreds)
 	if err != nil {
-		authService.logger.Errorf("failed to get password (service.loginUser): %w", err)
 		return fmt.Errorf("(service.loginUser): %w", err)
 	}
 
-	expectedHash, err := base64.RawStdEncoding.DecodeString(expectedPassword)
+	if expectedHash.outdated(authService.hashParams, authService.saltLength) {
+		authService.rehashPassword(ctx, userCreds, expectedPassword)
+	}
+
+	return nil
+}
+
+// checkPassword verifies the password and returns the stored one along with its parsed hash.
+func (authService *AuthService) checkPassword(
+	ctx context.Context,
+	userCreds domain.UserCredantials) (string, passwordHash, error) {
+	expectedPassword, err := authService.authStorage.GetPassword(ctx, userCreds.UserName)
+	if errors.Is(err, customErrors.ErrDoesNotExist) {
+		// takes as long as a wrong password, so the response time does not reveal the user names
+		_, verifyErr := authService.verifyPassword(ctx, authService.getDummyHash(), userCreds.Password)
+		if verifyErr != nil {
+			return "", passwordHash{}, fmt.Errorf("(service.checkPassword): %w", verifyErr)
+		}
+
+		return "", passwordHash{}, fmt.Errorf("%w (service.checkPassword): %w",
+			customErrors.ErrIncorrectEmailOrPassword, err)
+	}
+	if err != nil {
+		authService.logger.Errorf("failed to get password (service.checkPassword): %v", err)
+		return "", passwordHash{}, fmt.Errorf("(service.checkPassword): %w", err)
+	}
+
+	expectedHash, err := parsePasswordHash(expectedPassword, authService.saltLength)
 	if err != nil {
-		authService.logger.Errorf("failed to decode password (service.loginUser): %w", err)
-		return fmt.Errorf("%w (service.loginUser): %w", customErrors.ErrInternal, err)
+		authService.logger.Errorf("failed to decode password (service.checkPassword): %v", err)
+		return "", passwordHash{}, fmt.Errorf("(service.checkPassword): %w", err)
 	}
 
-	salt := expectedHash[0:authService.saltLength]
-	givenHash, err := hashPassword(userCreds.Password, salt)
+	ok, err := authService.verifyPassword(ctx, expectedHash, userCreds.Password)
 	if err != nil {
-		authService.logger.Errorf("failed to hash password (service.loginUser): %w", err)
-		return fmt.Errorf("%w (service.loginUser): %w", customErrors.ErrInternal, err)
+		return "", passwordHash{}, fmt.Errorf("(service.checkPassword): %w", err)
+	}
+	if !ok {
+		authService.logger.Errorf("passwords do not match (service.checkPassword)")
+		return "", passwordHash{}, fmt.Errorf("%w (service.checkPassword)", customErrors.ErrIncorrectEmailOrPassword)
 	}
 
-	givenPassword := append(salt, givenHash...)
+	return expectedPassword, expectedHash, nil
+}
 
-	if expectedPassword != base64.RawStdEncoding.EncodeToString(givenPassword) {
-		authService.logger.Errorf("passwords do not match (service.loginUser)")
-		return fmt.Errorf("%w (service.loginUser)", customErrors.ErrIncorrectEmailOrPassword)
+// rehashPassword does not fail the login, the hash is upgraded on one of the next logins then.
+func (authService *AuthService) rehashPassword(ctx context.Context, userCreds domain.UserCredantials, oldPassword string) {
+	password, err := authService.encodePassword(ctx, userCreds.Password)
+	if err != nil {
+		authService.logger.Errorf("failed to hash password (service.rehashPassword): %v", err)
+		return
 	}
 
-	return nil
+	err = authService.authStorage.RehashPassword(ctx, userCreds.UserName, oldPassword, password)
+	if err != nil {
+		authService.logger.Errorf("failed to store password (service.rehashPassword): %v", err)
+		return
+	}
+
+	authService.logger.Infof("password hash of %s upgraded", userCreds.UserName)
+}
+
+func (authService *AuthService) verifyPassword(ctx context.Context, hash passwordHash, password string) (bool, error) {
+	release, err := authService.acquireHashSlot(ctx)
+	if err != nil {
+		return false, fmt.Errorf("(service.verifyPassword): %w", err)
+	}
+	defer release()
+
+	return hash.verify(password), nil
 }
 
-func hashPassword(password string, salt []byte) ([]byte, error) {
-	hash := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
+func (authService *AuthService) getDummyHash() passwordHash {
+	authService.dummyHashOnce.Do(func() {
+		authService.dummyHash = newPasswordHash("", make([]byte, authService.saltLength), authService.hashParams)
+	})
 
-	return hash, nil
+	return authService.dummyHash
 }
 
 func genRandomSalt(length int) ([]byte, error) {
@@ -174,42 +525,86 @@ func genRandomSalt(length int) ([]byte, error) {
 }
 
 type myCustomClaims struct {
-	Name string `json:"name"`
+	Name      string   `json:"name"`
+	UserId    int      `json:"uid"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionId string   `json:"sid,omitempty"`
+	// IssuedAtNano keeps the issue time that iat rounds down to seconds,
+	// so the tokens issued right after a revocation are told apart from the revoked ones.
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
 	jwt.StandardClaims
 }
 
-func (authService *AuthService) createToken(name string) (string, error) {
+// issuedAt falls back to iat for the tokens issued before IssuedAtNano was added.
+func (claims *myCustomClaims) issuedAt() time.Time {
+	if claims.IssuedAtNano != 0 {
+		return time.Unix(0, claims.IssuedAtNano)
+	}
+
+	return time.Unix(claims.IssuedAt, 0)
+}
+
+// tokenClockSkew is the difference between the clocks of the replicas
+// tolerated when a token is checked by another replica than the one issued it.
+const tokenClockSkew = 5 * time.Second
+
+func (claims myCustomClaims) Valid() error {
+	standardClaims := claims.StandardClaims
+	standardClaims.IssuedAt -= int64(tokenClockSkew / time.Second)
+
+	return standardClaims.Valid()
+}
+
+func (authService *AuthService) createToken(
+	ctx context.Context,
+	user domain.User,
+	sessionId string) (string, time.Time, error) {
+	key, err := authService.activeKey(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w (service.createToken): %w", customErrors.ErrFailedToCreateToken, err)
+	}
+
+	tokenId, err := randomHex(16)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w (service.createToken): %w", customErrors.ErrFailedToCreateToken, err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Second * time.Duration(authService.expirationTime))
 	claims := myCustomClaims{
-		name,
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Second * time.Duration(authService.expirationTime)).Unix(),
+		Name:         user.Name,
+		UserId:       user.Id,
+		Roles:        user.Roles,
+		SessionId:    sessionId,
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenId,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
 			Issuer:    "auth",
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(authService.jwtKey)
+	token.Header["kid"] = key.Id
+	signedToken, err := token.SignedString(key.Secret)
 	if err != nil {
-		return "", fmt.Errorf("%w (redis.createToken): %w", customErrors.ErrFailedToCreateToken, err)
+		return "", time.Time{}, fmt.Errorf("%w (redis.createToken): %w", customErrors.ErrFailedToCreateToken, err)
 	}
 
-	return signedToken, nil
+	return signedToken, expiresAt, nil
 }
 
-func (authService *AuthService) getTokenClaims(token string) (*jwt.MapClaims, error) {
-	parsedToken, err := jwt.Parse(token,
+func (authService *AuthService) getTokenClaims(ctx context.Context, token string) (*myCustomClaims, error) {
+	var claims myCustomClaims
+	_, err := jwt.ParseWithClaims(token, &claims,
 		func(token *jwt.Token) (interface{}, error) {
-			return authService.jwtKey, nil
+			return authService.verificationKey(ctx, token)
 		},
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, err
-	}
-
 	return &claims, nil
 }
@@ -0,0 +1,654 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func newKeyStorageMock(ctrl *gomock.Controller) *storageMocks.MockKeyStorage {
+	keyStorage := storageMocks.NewMockKeyStorage(ctrl)
+	keys := make([]domain.SigningKey, 0)
+
+	keyStorage.EXPECT().GetKeys(gomock.Any()).DoAndReturn(
+		func(ctx context.Context) ([]domain.SigningKey, error) {
+			return append([]domain.SigningKey(nil), keys...), nil
+		}).AnyTimes()
+	keyStorage.EXPECT().AddActiveKey(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key domain.SigningKey) error {
+			for i := range keys {
+				keys[i].Active = false
+			}
+			keys = append(keys, key)
+			return nil
+		}).AnyTimes()
+	keyStorage.EXPECT().AddInitialKey(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key domain.SigningKey) (bool, error) {
+			for i := range keys {
+				if keys[i].Active {
+					return false, nil
+				}
+			}
+			keys = append(keys, key)
+			return true, nil
+		}).AnyTimes()
+	keyStorage.EXPECT().RetireKey(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, kid string) error {
+			for i := range keys {
+				if keys[i].Id == kid {
+					keys[i].Retired = true
+					return nil
+				}
+			}
+			return customErrors.ErrDoesNotExist
+		}).AnyTimes()
+
+	return keyStorage
+}
+
+var testUser = domain.User{Id: 1, Name: "test_user"}
+
+func testPrincipal(t *testing.T, token string) domain.Principal {
+	var claims myCustomClaims
+	_, _, err := new(jwt.Parser).ParseUnverified(token, &claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return domain.Principal{
+		UserId:    testUser.Id,
+		Name:      testUser.Name,
+		TokenId:   claims.Id,
+		IssuedAt:  claims.issuedAt(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}
+}
+
+func getKid(t *testing.T, token string) string {
+	parsedToken, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kid, _ := parsedToken.Header["kid"].(string)
+
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	err = authService.LoadKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, _, err := authService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKid := getKid(t, oldToken)
+
+	newKid, err := authService.RotateKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, _, err := authService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getKid(t, newToken) != newKid {
+		t.Errorf("new token is not signed with the active key")
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		principal, err := authService.Authenticate(ctx, token)
+		if err != nil || !reflect.DeepEqual(principal, testPrincipal(t, token)) {
+			t.Errorf("token signed with a non-retired key was rejected")
+		}
+	}
+
+	err = authService.RetireKey(ctx, newKid)
+	if !errors.Is(err, customErrors.ErrDataNotValid) {
+		t.Errorf("active key must not be retired, got %v", err)
+	}
+
+	err = authService.RetireKey(ctx, oldKid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authService.Authenticate(ctx, oldToken); !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("token signed with a retired key was accepted")
+	}
+	if _, err := authService.Authenticate(ctx, newToken); err != nil {
+		t.Errorf("token signed with the active key was rejected")
+	}
+}
+
+func TestLoadKeysRace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := storageMocks.NewMockKeyStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	// another replica stores its key between our read and our insert
+	foreignKey := domain.SigningKey{Id: "foreign_kid", Secret: []byte("foreign_secret"), Active: true}
+	gomock.InOrder(
+		keyStorage.EXPECT().GetKeys(gomock.Any()).Return([]domain.SigningKey{}, nil),
+		keyStorage.EXPECT().AddInitialKey(gomock.Any(), gomock.Any()).Return(false, nil),
+		keyStorage.EXPECT().GetKeys(gomock.Any()).Return([]domain.SigningKey{foreignKey}, nil).AnyTimes(),
+	)
+
+	ctx := context.Background()
+
+	err = authService.LoadKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := authService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getKid(t, token) != foreignKey.Id {
+		t.Errorf("token is not signed with the key stored by another replica")
+	}
+}
+
+func TestSharedKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	firstReplica, err := NewAuthService(authStorage, keyStorage, storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+	secondReplica, err := NewAuthService(authStorage, keyStorage, storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	token, _, err := firstReplica.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := secondReplica.Authenticate(ctx, token); err != nil {
+		t.Errorf("token issued by another replica was rejected")
+	}
+
+	_, err = secondReplica.RotateKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err = secondReplica.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstReplica.keysReloadedAt = time.Time{}
+	if _, err := firstReplica.Authenticate(ctx, token); err != nil {
+		t.Errorf("token signed with a key rotated on another replica was rejected")
+	}
+
+	foreignService, err := NewAuthService(authStorage, newKeyStorageMock(ctrl), storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	token, _, err = foreignService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := firstReplica.Authenticate(ctx, token); err == nil {
+		t.Errorf("token signed with an unknown key was accepted")
+	}
+}
+
+func TestRoleClaims(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	admin := domain.User{Id: 2, Name: "admin_user", Roles: []string{domain.RoleAdmin}}
+
+	token, _, err := authService.createToken(ctx, admin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := authService.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(principal.Roles, admin.Roles) {
+		t.Errorf("got roles %v, expected %v", principal.Roles, admin.Roles)
+	}
+	if !principal.HasPermission(domain.PermissionManageRoles) {
+		t.Errorf("admin is not allowed to manage roles")
+	}
+
+	authStorage.EXPECT().GrantRole(ctx, testUser.Name, domain.RoleSupport).Return(nil)
+
+	err = authService.GrantRole(ctx, testUser.Name, domain.RoleSupport)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = authService.GrantRole(ctx, testUser.Name, "superuser")
+	if !errors.Is(err, customErrors.ErrDataNotValid) {
+		t.Errorf("unknown role was granted, got %v", err)
+	}
+}
+
+func TestSignup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	ctx := context.Background()
+
+	userCreds := domain.UserCredantials{UserName: testUser.Name, Password: "test_password"}
+
+	var storedPassword string
+	authStorage.EXPECT().CreateUser(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userCreds domain.UserCredantials) error {
+			storedPassword = userCreds.Password
+			return nil
+		})
+	authStorage.EXPECT().GetUser(ctx, testUser.Name).Return(testUser, nil).AnyTimes()
+
+	sessionStorage := storageMocks.NewMockSessionStorage(ctrl)
+	sessionStorage.EXPECT().CreateRefreshToken(ctx, gomock.Any()).Return(nil).AnyTimes()
+
+	authService, err := NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, false)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	_, err = authService.Register(ctx, userCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authStorage.EXPECT().CreateUser(ctx, gomock.Any()).Return(customErrors.ErrAlreadyExists)
+
+	_, err = authService.Register(ctx, userCreds)
+	if !errors.Is(err, customErrors.ErrAlreadyExists) {
+		t.Errorf("taken name was registered again, got %v", err)
+	}
+
+	authStorage.EXPECT().GetPassword(ctx, testUser.Name).DoAndReturn(
+		func(ctx context.Context, name string) (string, error) {
+			return storedPassword, nil
+		}).AnyTimes()
+	authStorage.EXPECT().GetPassword(ctx, "unknown_user").Return("", customErrors.ErrDoesNotExist).AnyTimes()
+
+	_, err = authService.Login(ctx, userCreds)
+	if err != nil {
+		t.Errorf("registered user failed to login: %v", err)
+	}
+
+	unknownCreds := domain.UserCredantials{UserName: "unknown_user", Password: "test_password"}
+
+	_, err = authService.LoginOrCreateUser(ctx, unknownCreds)
+	if !errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+		t.Errorf("unknown user was signed up with auto-signup disabled, got %v", err)
+	}
+
+	// a concurrent request creates the user between HasUser and CreateUser
+	authService.autoSignup = true
+	authStorage.EXPECT().HasUser(ctx, testUser.Name).Return(false, nil)
+	authStorage.EXPECT().CreateUser(ctx, gomock.Any()).Return(customErrors.ErrAlreadyExists)
+
+	_, err = authService.LoginOrCreateUser(ctx, userCreds)
+	if err != nil {
+		t.Errorf("user created concurrently failed to login: %v", err)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+	sessionStorage := storageMocks.NewMockSessionStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+	otherReplica, err := NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	loggedOut, _, err := authService.createToken(ctx, testUser, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := authService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := authService.Authenticate(ctx, loggedOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedToken := domain.RevokedToken{Id: principal.TokenId, UserId: testUser.Id, ExpiresAt: principal.ExpiresAt}
+	sessionStorage.EXPECT().RevokeToken(ctx, revokedToken).Return(nil)
+	sessionStorage.EXPECT().RevokeRefreshFamily(ctx, "family").Return(nil)
+
+	err = authService.Logout(ctx, principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authService.Authenticate(ctx, loggedOut); !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("logged out token was accepted")
+	}
+	if _, err := authService.Authenticate(ctx, other); err != nil {
+		t.Errorf("other token of the user was rejected")
+	}
+
+	sessionStorage.EXPECT().GetRevocations(ctx).Return(domain.Revocations{
+		Tokens: map[string]time.Time{revokedToken.Id: revokedToken.ExpiresAt},
+	}, nil)
+
+	err = otherReplica.LoadRevocations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherReplica.Authenticate(ctx, loggedOut); !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("token logged out on another replica was accepted")
+	}
+
+	authStorage.EXPECT().GetUser(ctx, testUser.Name).Return(testUser, nil)
+	sessionStorage.EXPECT().RevokeUserTokens(ctx, testUser.Id, gomock.Any()).Return(nil)
+
+	err = authService.RevokeSessions(ctx, testUser.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authService.Authenticate(ctx, other); !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("token issued before all sessions were revoked was accepted")
+	}
+
+	// logging in again right away, most likely in the same second as the revocation
+	relogin, _, err := authService.createToken(ctx, testUser, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authService.Authenticate(ctx, relogin); err != nil {
+		t.Errorf("token issued right after all sessions were revoked was rejected: %v", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+	sessionStorage := storageMocks.NewMockSessionStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	var stored domain.RefreshToken
+	authStorage.EXPECT().GetUser(ctx, testUser.Name).Return(testUser, nil)
+	sessionStorage.EXPECT().CreateRefreshToken(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, token domain.RefreshToken) error {
+			stored = token
+			return nil
+		})
+
+	tokens, err := authService.issueToken(ctx, testUser.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Hash != hashToken(tokens.RefreshToken) || stored.Hash == tokens.RefreshToken {
+		t.Errorf("refresh token is not stored hashed")
+	}
+
+	// roles granted after the login are picked up by the refreshed token
+	promoted := testUser
+	promoted.Roles = []string{domain.RoleAdmin}
+
+	sessionStorage.EXPECT().RotateRefreshToken(ctx, stored.Hash, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error) {
+			next.FamilyId = stored.FamilyId
+			next.UserId = stored.UserId
+			next.UserName = stored.UserName
+			return next, nil
+		})
+	authStorage.EXPECT().GetUser(ctx, testUser.Name).Return(promoted, nil)
+
+	refreshed, err := authService.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("refresh token was not rotated")
+	}
+
+	principal, err := authService.Authenticate(ctx, refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.SessionId != stored.FamilyId {
+		t.Errorf("got session %q, expected %q", principal.SessionId, stored.FamilyId)
+	}
+	if !principal.HasPermission(domain.PermissionManageRoles) {
+		t.Errorf("refreshed token does not have the granted role")
+	}
+
+	sessionStorage.EXPECT().RotateRefreshToken(ctx, stored.Hash, gomock.Any()).Return(domain.RefreshToken{},
+		fmt.Errorf("%w: %w", customErrors.ErrUnauthenticated, customErrors.ErrRefreshTokenReused))
+	sessionStorage.EXPECT().GetRevocations(ctx).Return(domain.Revocations{
+		Sessions: map[string]time.Time{stored.FamilyId: time.Now()},
+	}, nil)
+
+	_, err = authService.Refresh(ctx, tokens.RefreshToken)
+	if !errors.Is(err, customErrors.ErrUnauthenticated) || !errors.Is(err, customErrors.ErrRefreshTokenReused) {
+		t.Errorf("reused refresh token was accepted, got %v", err)
+	}
+
+	// the access tokens of the session end together with its refresh tokens
+	if _, err := authService.Authenticate(ctx, refreshed.AccessToken); !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("access token of a session with a reused refresh token was accepted")
+	}
+
+	_, err = authService.Refresh(ctx, "")
+	if !errors.Is(err, customErrors.ErrUnauthenticated) {
+		t.Errorf("empty refresh token was accepted, got %v", err)
+	}
+}
+
+func TestPasswords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	keyStorage := newKeyStorageMock(ctrl)
+	sessionStorage := storageMocks.NewMockSessionStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, keyStorage, sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	storedPassword, err := authService.encodePassword(ctx, "old_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authStorage.EXPECT().GetPassword(ctx, testUser.Name).DoAndReturn(
+		func(ctx context.Context, name string) (string, error) {
+			return storedPassword, nil
+		}).AnyTimes()
+	authStorage.EXPECT().UpdatePassword(ctx, testUser.Name, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, name string, password string) error {
+			storedPassword = password
+			return nil
+		})
+	sessionStorage.EXPECT().RevokeUserTokens(ctx, testUser.Id, gomock.Any()).Return(nil).Times(2)
+
+	principal := domain.Principal{UserId: testUser.Id, Name: testUser.Name}
+
+	err = authService.ChangePassword(ctx, principal, "wrong_password", "new_password")
+	if !errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+		t.Errorf("password was changed without the old one, got %v", err)
+	}
+
+	err = authService.ChangePassword(ctx, principal, "old_password", "short")
+	if !errors.Is(err, customErrors.ErrDataNotValid) {
+		t.Errorf("too short password was accepted, got %v", err)
+	}
+
+	err = authService.ChangePassword(ctx, principal, "old_password", "new_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = authService.loginUser(ctx, domain.UserCredantials{UserName: testUser.Name, Password: "new_password"})
+	if err != nil {
+		t.Errorf("changed password was not accepted: %v", err)
+	}
+
+	var reset domain.PasswordReset
+	authStorage.EXPECT().GetUser(ctx, testUser.Name).Return(testUser, nil)
+	authStorage.EXPECT().CreatePasswordReset(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, issued domain.PasswordReset) error {
+			reset = issued
+			return nil
+		})
+
+	admin := domain.Principal{UserId: 2, Name: "test_admin", Roles: []string{domain.RoleAdmin}}
+
+	resetToken, err := authService.CreatePasswordReset(ctx, admin, testUser.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.Hash != hashToken(resetToken.Token) || reset.UserId != testUser.Id {
+		t.Errorf("unexpected password reset %v", reset)
+	}
+
+	testAdmin := domain.User{Id: 2, Name: "test_admin", Roles: []string{domain.RoleAdmin}}
+	authStorage.EXPECT().GetUser(ctx, testAdmin.Name).Return(testAdmin, nil)
+
+	support := domain.Principal{UserId: 3, Name: "test_support", Roles: []string{domain.RoleSupport}}
+	_, err = authService.CreatePasswordReset(ctx, support, testAdmin.Name)
+	if !errors.Is(err, customErrors.ErrForbidden) {
+		t.Errorf("password reset of a more privileged user was issued, got %v", err)
+	}
+
+	authStorage.EXPECT().GetPasswordReset(ctx, reset.Hash).Return(reset, nil)
+	authStorage.EXPECT().ResetPassword(ctx, reset.Hash, gomock.Any()).Return(testUser, nil)
+
+	err = authService.ResetPassword(ctx, resetToken.Token, "reset_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown token is rejected without hashing the password or touching the storage
+	authStorage.EXPECT().GetPasswordReset(ctx, hashToken("unknown_token")).
+		Return(domain.PasswordReset{}, customErrors.ErrDataNotValid)
+
+	err = authService.ResetPassword(ctx, "unknown_token", "reset_password")
+	if !errors.Is(err, customErrors.ErrInvalidResetToken) {
+		t.Errorf("unknown reset token was accepted, got %v", err)
+	}
+
+	err = authService.ResetPassword(ctx, "", "reset_password")
+	if !errors.Is(err, customErrors.ErrDataNotValid) {
+		t.Errorf("empty reset token was accepted, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// PasswordHashParams are the argon2id parameters new hashes are created with,
+// Memory is in KiB. Hashes made with other parameters are upgraded on login.
+type PasswordHashParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+var DefaultPasswordHashParams = PasswordHashParams{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+}
+
+// legacyPasswordHashParams were used for the hashes stored before the PHC format,
+// such hashes are the base64 of the salt followed by the hash.
+var legacyPasswordHashParams = PasswordHashParams{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+}
+
+const passwordKeyLength = 32
+
+type passwordHash struct {
+	params PasswordHashParams
+	salt   []byte
+	hash   []byte
+	legacy bool
+}
+
+func newPasswordHash(password string, salt []byte, params PasswordHashParams) passwordHash {
+	return passwordHash{
+		params: params,
+		salt:   salt,
+		hash:   argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, passwordKeyLength),
+	}
+}
+
+// String encodes the hash in the PHC string format.
+func (h passwordHash) String() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(h.salt),
+		base64.RawStdEncoding.EncodeToString(h.hash))
+}
+
+func (h passwordHash) verify(password string) bool {
+	given := argon2.IDKey([]byte(password), h.salt,
+		h.params.Iterations, h.params.Memory, h.params.Parallelism, uint32(len(h.hash)))
+
+	return subtle.ConstantTimeCompare(given, h.hash) == 1
+}
+
+func (h passwordHash) outdated(params PasswordHashParams, saltLength int) bool {
+	return h.legacy ||
+		h.params != params ||
+		len(h.salt) != saltLength ||
+		len(h.hash) != passwordKeyLength
+}
+
+// parsePasswordHash reads a PHC string or a legacy hash,
+// the salt length of legacy hashes is not stored and must be given.
+func parsePasswordHash(encoded string, legacySaltLength int) (passwordHash, error) {
+	if !strings.HasPrefix(encoded, "$") {
+		decoded, err := base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil {
+			return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): %w", customErrors.ErrInternal, err)
+		}
+		if len(decoded) <= legacySaltLength {
+			return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): legacy hash too short",
+				customErrors.ErrInternal)
+		}
+
+		return passwordHash{
+			params: legacyPasswordHashParams,
+			salt:   decoded[:legacySaltLength],
+			hash:   decoded[legacySaltLength:],
+			legacy: true,
+		}, nil
+	}
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): unsupported hash format",
+			customErrors.ErrInternal)
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): %w", customErrors.ErrInternal, err)
+	}
+	if version != argon2.Version {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): unsupported argon2 version %d",
+			customErrors.ErrInternal, version)
+	}
+
+	var h passwordHash
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.params.Memory, &h.params.Iterations, &h.params.Parallelism)
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): %w", customErrors.ErrInternal, err)
+	}
+
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): %w", customErrors.ErrInternal, err)
+	}
+
+	h.hash, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(h.hash) == 0 {
+		return passwordHash{}, fmt.Errorf("%w (service.parsePasswordHash): malformed hash", customErrors.ErrInternal)
+	}
+
+	return h, nil
+}
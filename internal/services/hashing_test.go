@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func TestParsePasswordHash(t *testing.T) {
+	salt := []byte("0123456789")
+	hash := newPasswordHash("test_password", salt, DefaultPasswordHashParams)
+
+	legacy := base64.RawStdEncoding.EncodeToString(
+		append(append([]byte{}, salt...), argon2.IDKey([]byte("test_password"), salt, 1, 64*1024, 4, 32)...))
+
+	testData := []struct {
+		TestName        string
+		Encoded         string
+		ExpectedErr     bool
+		ExpectedVerify  bool
+		ExpectedOutdate bool
+	}{
+		{"phc", hash.String(), false, true, false},
+		{"legacy", legacy, false, true, true},
+		{"other parameters", newPasswordHash("test_password", salt,
+			PasswordHashParams{Memory: 8 * 1024, Iterations: 2, Parallelism: 1}).String(), false, true, true},
+		{"other algorithm", strings.Replace(hash.String(), "argon2id", "argon2i", 1), true, false, false},
+		{"other version", strings.Replace(hash.String(), "v=19", "v=16", 1), true, false, false},
+		{"malformed parameters", strings.Replace(hash.String(), "m=", "x=", 1), true, false, false},
+		{"truncated", hash.String()[:strings.LastIndex(hash.String(), "$")], true, false, false},
+		{"legacy too short", base64.RawStdEncoding.EncodeToString(salt), true, false, false},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			parsed, err := parsePasswordHash(testCase.Encoded, len(salt))
+			if testCase.ExpectedErr {
+				if !errors.Is(err, customErrors.ErrInternal) {
+					t.Errorf("malformed hash was parsed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if parsed.verify("test_password") != testCase.ExpectedVerify {
+				t.Errorf("unexpected verification result")
+			}
+			if parsed.verify("wrong_password") {
+				t.Errorf("wrong password was accepted")
+			}
+			if parsed.outdated(DefaultPasswordHashParams, len(salt)) != testCase.ExpectedOutdate {
+				t.Errorf("unexpected outdated result")
+			}
+		})
+	}
+}
+
+func TestRehashOnLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, newKeyStorageMock(ctrl), storageMocks.NewMockSessionStorage(ctrl), logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+	userCreds := domain.UserCredantials{UserName: testUser.Name, Password: "test_password"}
+
+	salt := []byte("0123456789")
+	legacy := base64.RawStdEncoding.EncodeToString(
+		append(append([]byte{}, salt...), argon2.IDKey([]byte("test_password"), salt, 1, 64*1024, 4, 32)...))
+
+	storedPassword := legacy
+	authStorage.EXPECT().GetPassword(ctx, testUser.Name).DoAndReturn(
+		func(ctx context.Context, name string) (string, error) {
+			return storedPassword, nil
+		}).Times(3)
+	authStorage.EXPECT().RehashPassword(ctx, testUser.Name, legacy, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, name string, oldPassword string, newPassword string) error {
+			storedPassword = newPassword
+			return nil
+		})
+
+	err = authService.loginUser(ctx, userCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(storedPassword, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Errorf("legacy hash was not upgraded, got %q", storedPassword)
+	}
+
+	// the upgraded hash is not rehashed again
+	err = authService.loginUser(ctx, userCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = authService.loginUser(ctx, domain.UserCredantials{UserName: testUser.Name, Password: "wrong_password"})
+	if !errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+		t.Errorf("wrong password was accepted, got %v", err)
+	}
+
+	authStorage.EXPECT().GetPassword(ctx, "unknown_user").Return("", customErrors.ErrDoesNotExist)
+
+	err = authService.loginUser(ctx, domain.UserCredantials{UserName: "unknown_user", Password: "test_password"})
+	if !errors.Is(err, customErrors.ErrIncorrectEmailOrPassword) {
+		t.Errorf("unknown user was accepted, got %v", err)
+	}
+}
+
+func TestChangePasswordSkipsRehash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+	sessionStorage := storageMocks.NewMockSessionStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authService, err := NewAuthService(authStorage, newKeyStorageMock(ctrl), sessionStorage, logger,
+		10, DefaultPasswordHashParams, 4, 60, 3600, 3600, true)
+	if err != nil {
+		log.Fatalf("error in auth service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	salt := []byte("0123456789")
+	legacy := base64.RawStdEncoding.EncodeToString(
+		append(append([]byte{}, salt...), argon2.IDKey([]byte("test_password"), salt, 1, 64*1024, 4, 32)...))
+
+	// the legacy hash is replaced by the new password only, RehashPassword is not expected
+	authStorage.EXPECT().GetPassword(ctx, testUser.Name).Return(legacy, nil)
+	authStorage.EXPECT().UpdatePassword(ctx, testUser.Name, gomock.Any()).Return(nil)
+	sessionStorage.EXPECT().RevokeUserTokens(ctx, testUser.Id, gomock.Any()).Return(nil)
+
+	principal := domain.Principal{UserId: testUser.Id, Name: testUser.Name}
+
+	err = authService.ChangePassword(ctx, principal, "test_password", "new_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+const (
+	keyIdLength           = 8
+	keySecretLength       = 32
+	minKeysReloadInterval = 5 * time.Second
+)
+
+type KeyStorage interface {
+	GetKeys(ctx context.Context) ([]domain.SigningKey, error)
+	AddActiveKey(ctx context.Context, key domain.SigningKey) error
+	AddInitialKey(ctx context.Context, key domain.SigningKey) (bool, error)
+	RetireKey(ctx context.Context, kid string) error
+}
+
+func (authService *AuthService) LoadKeys(ctx context.Context) error {
+	err := authService.reloadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.LoadKeys): %w", err)
+	}
+
+	authService.keysMu.RLock()
+	hasActiveKey := authService.activeKid != ""
+	authService.keysMu.RUnlock()
+
+	if hasActiveKey {
+		return nil
+	}
+
+	// several instances may start against an empty storage at once, so the
+	// first key is only stored if none of them got there first
+	key, err := newSigningKey()
+	if err != nil {
+		return fmt.Errorf("(service.LoadKeys): %w", err)
+	}
+
+	added, err := authService.keyStorage.AddInitialKey(ctx, key)
+	if err != nil {
+		return fmt.Errorf("(service.LoadKeys): %w", err)
+	}
+
+	err = authService.reloadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.LoadKeys): %w", err)
+	}
+
+	if added {
+		authService.logger.Infof("no active signing key found, generated a new one, active kid: %s", key.Id)
+	} else {
+		authService.logger.Infof("no active signing key found, using the one generated by another instance")
+	}
+
+	return nil
+}
+
+func (authService *AuthService) RotateKey(ctx context.Context) (string, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return "", fmt.Errorf("(service.RotateKey): %w", err)
+	}
+
+	err = authService.keyStorage.AddActiveKey(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("(service.RotateKey): %w", err)
+	}
+
+	err = authService.reloadKeys(ctx)
+	if err != nil {
+		return "", fmt.Errorf("(service.RotateKey): %w", err)
+	}
+
+	authService.logger.Infof("signing key rotated, active kid: %s", key.Id)
+
+	return key.Id, nil
+}
+
+func newSigningKey() (domain.SigningKey, error) {
+	kid, err := genRandomSalt(keyIdLength)
+	if err != nil {
+		return domain.SigningKey{}, fmt.Errorf("%w (service.newSigningKey): %w", customErrors.ErrFailedToGenJWTKey, err)
+	}
+
+	secret, err := genRandomSalt(keySecretLength)
+	if err != nil {
+		return domain.SigningKey{}, fmt.Errorf("%w (service.newSigningKey): %w", customErrors.ErrFailedToGenJWTKey, err)
+	}
+
+	return domain.SigningKey{
+		Id:        hex.EncodeToString(kid),
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (authService *AuthService) RetireKey(ctx context.Context, kid string) error {
+	keys, err := authService.keyStorage.GetKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.RetireKey): %w", err)
+	}
+
+	for _, key := range keys {
+		if key.Id == kid && key.Active {
+			return fmt.Errorf("%w (service.RetireKey): active key can not be retired", customErrors.ErrDataNotValid)
+		}
+	}
+
+	err = authService.keyStorage.RetireKey(ctx, kid)
+	if err != nil {
+		return fmt.Errorf("(service.RetireKey): %w", err)
+	}
+
+	err = authService.reloadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.RetireKey): %w", err)
+	}
+
+	return nil
+}
+
+func (authService *AuthService) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys, err := authService.keyStorage.GetKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("(service.ListKeys): %w", err)
+	}
+
+	for i := range keys {
+		keys[i].Secret = nil
+	}
+
+	return keys, nil
+}
+
+func (authService *AuthService) WatchKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := authService.reloadKeys(ctx)
+			if err != nil {
+				authService.logger.Errorf("failed to reload signing keys (service.WatchKeys): %v", err)
+			}
+		}
+	}
+}
+
+func (authService *AuthService) reloadKeys(ctx context.Context) error {
+	keys, err := authService.keyStorage.GetKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.reloadKeys): %w", err)
+	}
+
+	validKeys := make(map[string]domain.SigningKey, len(keys))
+	activeKid := ""
+	for _, key := range keys {
+		if key.Retired {
+			continue
+		}
+
+		validKeys[key.Id] = key
+		if key.Active {
+			activeKid = key.Id
+		}
+	}
+
+	authService.keysMu.Lock()
+	authService.keys = validKeys
+	authService.activeKid = activeKid
+	authService.keysReloadedAt = time.Now()
+	authService.keysMu.Unlock()
+
+	return nil
+}
+
+func (authService *AuthService) activeKey(ctx context.Context) (domain.SigningKey, error) {
+	authService.keysMu.RLock()
+	key, ok := authService.keys[authService.activeKid]
+	authService.keysMu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	err := authService.LoadKeys(ctx)
+	if err != nil {
+		return domain.SigningKey{}, fmt.Errorf("%w (service.activeKey): %w", customErrors.ErrNoActiveKey, err)
+	}
+
+	authService.keysMu.RLock()
+	key, ok = authService.keys[authService.activeKid]
+	authService.keysMu.RUnlock()
+	if !ok {
+		return domain.SigningKey{}, fmt.Errorf("%w (service.activeKey)", customErrors.ErrNoActiveKey)
+	}
+
+	return key, nil
+}
+
+func (authService *AuthService) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("%w (service.verificationKey): unexpected signing method %v",
+			customErrors.ErrUnknownSigningKey, token.Header["alg"])
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w (service.verificationKey): missing kid", customErrors.ErrUnknownSigningKey)
+	}
+
+	authService.keysMu.RLock()
+	key, ok := authService.keys[kid]
+	stale := time.Since(authService.keysReloadedAt) > minKeysReloadInterval
+	authService.keysMu.RUnlock()
+	if ok {
+		return key.Secret, nil
+	}
+
+	// the key may have been added by another replica since the last reload
+	if stale {
+		err := authService.reloadKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("(service.verificationKey): %w", err)
+		}
+
+		authService.keysMu.RLock()
+		key, ok = authService.keys[kid]
+		authService.keysMu.RUnlock()
+		if ok {
+			return key.Secret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w (service.verificationKey): %s", customErrors.ErrUnknownSigningKey, kid)
+}
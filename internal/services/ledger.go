@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+// LedgerStorage exposes the balances derived from the ledger, so that
+// they can be verified against the balances cached in the user rows.
+type LedgerStorage interface {
+	GetBalances(ctx context.Context) ([]domain.AccountBalance, error)
+	GetActivity(ctx context.Context) ([]domain.AccountActivity, error)
+}
+
+type LedgerService struct {
+	ledgerStorage LedgerStorage
+	logger        *zap.SugaredLogger
+}
+
+func NewLedgerService(ledgerStorage LedgerStorage, logger *zap.SugaredLogger) (*LedgerService, error) {
+	return &LedgerService{
+		ledgerStorage: ledgerStorage,
+		logger:        logger,
+	}, nil
+}
+
+// Reconcile checks the cached balance of every user against the ledger and
+// against the balance recomputed from the transfers and purchases.
+func (ledgerService *LedgerService) Reconcile(ctx context.Context) (domain.ReconciliationReport, error) {
+	report := domain.ReconciliationReport{
+		CheckedAt: time.Now().UTC(),
+		Drifting:  make([]domain.DriftingAccount, 0),
+	}
+
+	balances, err := ledgerService.ledgerStorage.GetBalances(ctx)
+	if err != nil {
+		ledgerService.logger.Errorf("failed to get ledger balances (service.Reconcile): %v", err)
+		return domain.ReconciliationReport{}, fmt.Errorf("(service.Reconcile): %w", err)
+	}
+	for _, balance := range balances {
+		report.LedgerTotal += balance.Balance
+	}
+
+	activity, err := ledgerService.ledgerStorage.GetActivity(ctx)
+	if err != nil {
+		ledgerService.logger.Errorf("failed to get account activity (service.Reconcile): %v", err)
+		return domain.ReconciliationReport{}, fmt.Errorf("(service.Reconcile): %w", err)
+	}
+	report.Accounts = len(activity)
+
+	for _, account := range activity {
+		issues := make([]string, 0)
+		if account.Cached != account.Ledger {
+			issues = append(issues, domain.IssueLedgerMismatch)
+		}
+		if account.Cached != account.Breakdown.Expected() {
+			issues = append(issues, domain.IssueActivityMismatch)
+			if account.Breakdown.UnpricedPurchases > 0 {
+				issues = append(issues, domain.IssueUnpricedPurchases)
+			}
+		}
+		if len(issues) == 0 {
+			continue
+		}
+
+		report.Drifting = append(report.Drifting, domain.DriftingAccount{
+			UserId:    account.UserId,
+			UserName:  account.UserName,
+			Cached:    account.Cached,
+			Ledger:    account.Ledger,
+			Expected:  account.Breakdown.Expected(),
+			Breakdown: account.Breakdown,
+			Issues:    issues,
+		})
+	}
+
+	return report, nil
+}
+
+// WatchBalances reconciles the balances every interval and logs the drifting accounts.
+func (ledgerService *LedgerService) WatchBalances(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := ledgerService.Reconcile(ctx)
+			if err != nil {
+				continue
+			}
+
+			if report.IsClean() {
+				ledgerService.logger.Infof("balances are reconciled: %d accounts checked", report.Accounts)
+				continue
+			}
+
+			ledgerService.logger.Warnw("balances drift found",
+				"ledgerTotal", report.LedgerTotal,
+				"drifting", report.Drifting)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"log"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func TestReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ledgerStorage := storageMocks.NewMockLedgerStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	ledgerService, err := NewLedgerService(ledgerStorage, logger)
+	if err != nil {
+		log.Fatalf("error in ledger service initialization: %v\n", err)
+	}
+
+	ctx := context.Background()
+
+	ledgerStorage.EXPECT().GetBalances(ctx).Return([]domain.AccountBalance{
+		{Account: domain.MintAccount, Balance: -4000},
+		{Account: domain.RevenueAccount, Balance: 100},
+		{Account: domain.UserAccount(1), Balance: 900},
+		{Account: domain.UserAccount(2), Balance: 1000},
+		{Account: domain.UserAccount(3), Balance: 1000},
+		{Account: domain.UserAccount(4), Balance: 1000},
+	}, nil)
+
+	ledgerStorage.EXPECT().GetActivity(ctx).Return([]domain.AccountActivity{
+		{
+			UserId:    1,
+			UserName:  "consistent",
+			Cached:    900,
+			Ledger:    900,
+			Breakdown: domain.BalanceBreakdown{Grant: 1000, Purchases: 100},
+		},
+		{
+			UserId:    2,
+			UserName:  "cache_drift",
+			Cached:    1010,
+			Ledger:    1000,
+			Breakdown: domain.BalanceBreakdown{Grant: 1000, Received: 10},
+		},
+		{
+			UserId:    3,
+			UserName:  "repriced",
+			Cached:    1000,
+			Ledger:    1000,
+			Breakdown: domain.BalanceBreakdown{Grant: 1000, Purchases: 50},
+		},
+		{
+			UserId:    4,
+			UserName:  "deleted_product",
+			Cached:    1000,
+			Ledger:    1000,
+			Breakdown: domain.BalanceBreakdown{Grant: 1000, UnpricedPurchases: 1, Received: 20},
+		},
+	}, nil)
+
+	report, err := ledgerService.Reconcile(ctx)
+	require.NoError(t, err)
+	require.False(t, report.IsClean())
+	require.Equal(t, 4, report.Accounts)
+	require.Zero(t, report.LedgerTotal)
+
+	issues := make(map[string][]string)
+	for _, account := range report.Drifting {
+		issues[account.UserName] = account.Issues
+	}
+	require.Equal(t, map[string][]string{
+		"cache_drift":     {domain.IssueLedgerMismatch},
+		"repriced":        {domain.IssueActivityMismatch},
+		"deleted_product": {domain.IssueActivityMismatch, domain.IssueUnpricedPurchases},
+	}, issues)
+
+	ledgerStorage.EXPECT().GetBalances(ctx).Return(nil, customErrors.ErrFailedToExecuteQuery)
+
+	_, err = ledgerService.Reconcile(ctx)
+	require.ErrorIs(t, err, customErrors.ErrFailedToExecuteQuery)
+}
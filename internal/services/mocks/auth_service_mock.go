@@ -35,40 +35,70 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
-// Check mocks base method.
-func (m *MockAuthService) Check(ctx context.Context, token string) bool {
+// Authenticate mocks base method.
+func (m *MockAuthService) Authenticate(ctx context.Context, token string) (domain.Principal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Check", ctx, token)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "Authenticate", ctx, token)
+	ret0, _ := ret[0].(domain.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockAuthServiceMockRecorder) Authenticate(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAuthService)(nil).Authenticate), ctx, token)
+}
+
+// ChangePassword mocks base method.
+func (m *MockAuthService) ChangePassword(ctx context.Context, principal domain.Principal, oldPassword, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, principal, oldPassword, newPassword)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Check indicates an expected call of Check.
-func (mr *MockAuthServiceMockRecorder) Check(ctx, token interface{}) *gomock.Call {
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockAuthServiceMockRecorder) ChangePassword(ctx, principal, oldPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthService)(nil).ChangePassword), ctx, principal, oldPassword, newPassword)
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockAuthService) CreatePasswordReset(ctx context.Context, principal domain.Principal, name string) (domain.PasswordResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, principal, name)
+	ret0, _ := ret[0].(domain.PasswordResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockAuthServiceMockRecorder) CreatePasswordReset(ctx, principal, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockAuthService)(nil).Check), ctx, token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockAuthService)(nil).CreatePasswordReset), ctx, principal, name)
 }
 
-// GetNameAndCheck mocks base method.
-func (m *MockAuthService) GetNameAndCheck(ctx context.Context, token string) (string, bool) {
+// Login mocks base method.
+func (m *MockAuthService) Login(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetNameAndCheck", ctx, token)
-	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(bool)
+	ret := m.ctrl.Call(m, "Login", ctx, userCreds)
+	ret0, _ := ret[0].(domain.Tokens)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetNameAndCheck indicates an expected call of GetNameAndCheck.
-func (mr *MockAuthServiceMockRecorder) GetNameAndCheck(ctx, token interface{}) *gomock.Call {
+// Login indicates an expected call of Login.
+func (mr *MockAuthServiceMockRecorder) Login(ctx, userCreds interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNameAndCheck", reflect.TypeOf((*MockAuthService)(nil).GetNameAndCheck), ctx, token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockAuthService)(nil).Login), ctx, userCreds)
 }
 
 // LoginOrCreateUser mocks base method.
-func (m *MockAuthService) LoginOrCreateUser(ctx context.Context, userCreds domain.UserCredantials) (string, error) {
+func (m *MockAuthService) LoginOrCreateUser(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "LoginOrCreateUser", ctx, userCreds)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(domain.Tokens)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -78,3 +108,136 @@ func (mr *MockAuthServiceMockRecorder) LoginOrCreateUser(ctx, userCreds interfac
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginOrCreateUser", reflect.TypeOf((*MockAuthService)(nil).LoginOrCreateUser), ctx, userCreds)
 }
+
+// Logout mocks base method.
+func (m *MockAuthService) Logout(ctx context.Context, principal domain.Principal) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, principal)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockAuthServiceMockRecorder) Logout(ctx, principal interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthService)(nil).Logout), ctx, principal)
+}
+
+// Refresh mocks base method.
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string) (domain.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, refreshToken)
+	ret0, _ := ret[0].(domain.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockAuthServiceMockRecorder) Refresh(ctx, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockAuthService)(nil).Refresh), ctx, refreshToken)
+}
+
+// Register mocks base method.
+func (m *MockAuthService) Register(ctx context.Context, userCreds domain.UserCredantials) (domain.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", ctx, userCreds)
+	ret0, _ := ret[0].(domain.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Register indicates an expected call of Register.
+func (mr *MockAuthServiceMockRecorder) Register(ctx, userCreds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockAuthService)(nil).Register), ctx, userCreds)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuthService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, resetToken, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthServiceMockRecorder) ResetPassword(ctx, resetToken, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuthService)(nil).ResetPassword), ctx, resetToken, newPassword)
+}
+
+// RevokeSessions mocks base method.
+func (m *MockAuthService) RevokeSessions(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSessions", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSessions indicates an expected call of RevokeSessions.
+func (mr *MockAuthServiceMockRecorder) RevokeSessions(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSessions", reflect.TypeOf((*MockAuthService)(nil).RevokeSessions), ctx, name)
+}
+
+// MockLoginThrottle is a mock of LoginThrottle interface.
+type MockLoginThrottle struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginThrottleMockRecorder
+}
+
+// MockLoginThrottleMockRecorder is the mock recorder for MockLoginThrottle.
+type MockLoginThrottleMockRecorder struct {
+	mock *MockLoginThrottle
+}
+
+// NewMockLoginThrottle creates a new mock instance.
+func NewMockLoginThrottle(ctrl *gomock.Controller) *MockLoginThrottle {
+	mock := &MockLoginThrottle{ctrl: ctrl}
+	mock.recorder = &MockLoginThrottleMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginThrottle) EXPECT() *MockLoginThrottleMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockLoginThrottle) Allow(name, client string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow", name, client)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockLoginThrottleMockRecorder) Allow(name, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockLoginThrottle)(nil).Allow), name, client)
+}
+
+// Failed mocks base method.
+func (m *MockLoginThrottle) Failed(name, client string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Failed", name, client)
+}
+
+// Failed indicates an expected call of Failed.
+func (mr *MockLoginThrottleMockRecorder) Failed(name, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Failed", reflect.TypeOf((*MockLoginThrottle)(nil).Failed), name, client)
+}
+
+// Succeeded mocks base method.
+func (m *MockLoginThrottle) Succeeded(name, client string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Succeeded", name, client)
+}
+
+// Succeeded indicates an expected call of Succeeded.
+func (mr *MockLoginThrottleMockRecorder) Succeeded(name, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Succeeded", reflect.TypeOf((*MockLoginThrottle)(nil).Succeeded), name, client)
+}
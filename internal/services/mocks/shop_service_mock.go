@@ -35,18 +35,49 @@ func (m *MockShopService) EXPECT() *MockShopServiceMockRecorder {
 	return m.recorder
 }
 
+// BuyCart mocks base method.
+func (m *MockShopService) BuyCart(ctx context.Context, username string, cart domain.Cart, idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BuyCart", ctx, username, cart, idempotencyKey)
+	ret0, _ := ret[0].(domain.CartReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BuyCart indicates an expected call of BuyCart.
+func (mr *MockShopServiceMockRecorder) BuyCart(ctx, username, cart, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyCart", reflect.TypeOf((*MockShopService)(nil).BuyCart), ctx, username, cart, idempotencyKey)
+}
+
 // BuyItem mocks base method.
-func (m *MockShopService) BuyItem(ctx context.Context, username, itemName string) error {
+func (m *MockShopService) BuyItem(ctx context.Context, username, itemName string, idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuyItem", ctx, username, itemName)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "BuyItem", ctx, username, itemName, idempotencyKey)
+	ret0, _ := ret[0].(domain.PurchaseReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // BuyItem indicates an expected call of BuyItem.
-func (mr *MockShopServiceMockRecorder) BuyItem(ctx, username, itemName interface{}) *gomock.Call {
+func (mr *MockShopServiceMockRecorder) BuyItem(ctx, username, itemName, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShopService)(nil).BuyItem), ctx, username, itemName, idempotencyKey)
+}
+
+// GetHistory mocks base method.
+func (m *MockShopService) GetHistory(ctx context.Context, username string, filter domain.HistoryFilter) (domain.HistoryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", ctx, username, filter)
+	ret0, _ := ret[0].(domain.HistoryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockShopServiceMockRecorder) GetHistory(ctx, username, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShopService)(nil).BuyItem), ctx, username, itemName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockShopService)(nil).GetHistory), ctx, username, filter)
 }
 
 // GetInfo mocks base method.
@@ -64,16 +95,76 @@ func (mr *MockShopServiceMockRecorder) GetInfo(ctx, username interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInfo", reflect.TypeOf((*MockShopService)(nil).GetInfo), ctx, username)
 }
 
+// GetProduct mocks base method.
+func (m *MockShopService) GetProduct(ctx context.Context, name string) (domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProduct", ctx, name)
+	ret0, _ := ret[0].(domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProduct indicates an expected call of GetProduct.
+func (mr *MockShopServiceMockRecorder) GetProduct(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockShopService)(nil).GetProduct), ctx, name)
+}
+
+// GetProducts mocks base method.
+func (m *MockShopService) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProducts", ctx)
+	ret0, _ := ret[0].([]domain.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProducts indicates an expected call of GetProducts.
+func (mr *MockShopServiceMockRecorder) GetProducts(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProducts", reflect.TypeOf((*MockShopService)(nil).GetProducts), ctx)
+}
+
+// GiftItem mocks base method.
+func (m *MockShopService) GiftItem(ctx context.Context, gift domain.Gift, idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GiftItem", ctx, gift, idempotencyKey)
+	ret0, _ := ret[0].(domain.GiftReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GiftItem indicates an expected call of GiftItem.
+func (mr *MockShopServiceMockRecorder) GiftItem(ctx, gift, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GiftItem", reflect.TypeOf((*MockShopService)(nil).GiftItem), ctx, gift, idempotencyKey)
+}
+
+// RefundPurchase mocks base method.
+func (m *MockShopService) RefundPurchase(ctx context.Context, username string, purchaseId int64) (domain.RefundReceipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundPurchase", ctx, username, purchaseId)
+	ret0, _ := ret[0].(domain.RefundReceipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundPurchase indicates an expected call of RefundPurchase.
+func (mr *MockShopServiceMockRecorder) RefundPurchase(ctx, username, purchaseId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundPurchase", reflect.TypeOf((*MockShopService)(nil).RefundPurchase), ctx, username, purchaseId)
+}
+
 // SendCoin mocks base method.
-func (m *MockShopService) SendCoin(ctx context.Context, transaction domain.Transaction) error {
+func (m *MockShopService) SendCoin(ctx context.Context, transaction domain.Transaction, idempotencyKey domain.IdempotencyKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendCoin", ctx, transaction)
+	ret := m.ctrl.Call(m, "SendCoin", ctx, transaction, idempotencyKey)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SendCoin indicates an expected call of SendCoin.
-func (mr *MockShopServiceMockRecorder) SendCoin(ctx, transaction interface{}) *gomock.Call {
+func (mr *MockShopServiceMockRecorder) SendCoin(ctx, transaction, idempotencyKey interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockShopService)(nil).SendCoin), ctx, transaction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockShopService)(nil).SendCoin), ctx, transaction, idempotencyKey)
 }
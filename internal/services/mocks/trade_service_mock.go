@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/handlers/trades.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTradeService is a mock of TradeService interface.
+type MockTradeService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeServiceMockRecorder
+}
+
+// MockTradeServiceMockRecorder is the mock recorder for MockTradeService.
+type MockTradeServiceMockRecorder struct {
+	mock *MockTradeService
+}
+
+// NewMockTradeService creates a new mock instance.
+func NewMockTradeService(ctrl *gomock.Controller) *MockTradeService {
+	mock := &MockTradeService{ctrl: ctrl}
+	mock.recorder = &MockTradeServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeService) EXPECT() *MockTradeServiceMockRecorder {
+	return m.recorder
+}
+
+// AcceptTrade mocks base method.
+func (m *MockTradeService) AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptTrade indicates an expected call of AcceptTrade.
+func (mr *MockTradeServiceMockRecorder) AcceptTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptTrade", reflect.TypeOf((*MockTradeService)(nil).AcceptTrade), ctx, username, tradeId)
+}
+
+// DeclineTrade mocks base method.
+func (m *MockTradeService) DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclineTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeclineTrade indicates an expected call of DeclineTrade.
+func (mr *MockTradeServiceMockRecorder) DeclineTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclineTrade", reflect.TypeOf((*MockTradeService)(nil).DeclineTrade), ctx, username, tradeId)
+}
+
+// GetTrade mocks base method.
+func (m *MockTradeService) GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrade", ctx, username, tradeId)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrade indicates an expected call of GetTrade.
+func (mr *MockTradeServiceMockRecorder) GetTrade(ctx, username, tradeId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrade", reflect.TypeOf((*MockTradeService)(nil).GetTrade), ctx, username, tradeId)
+}
+
+// GetTrades mocks base method.
+func (m *MockTradeService) GetTrades(ctx context.Context, username string) ([]domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrades", ctx, username)
+	ret0, _ := ret[0].([]domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrades indicates an expected call of GetTrades.
+func (mr *MockTradeServiceMockRecorder) GetTrades(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrades", reflect.TypeOf((*MockTradeService)(nil).GetTrades), ctx, username)
+}
+
+// ProposeTrade mocks base method.
+func (m *MockTradeService) ProposeTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProposeTrade", ctx, offer)
+	ret0, _ := ret[0].(domain.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProposeTrade indicates an expected call of ProposeTrade.
+func (mr *MockTradeServiceMockRecorder) ProposeTrade(ctx, offer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProposeTrade", reflect.TypeOf((*MockTradeService)(nil).ProposeTrade), ctx, offer)
+}
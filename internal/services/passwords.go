@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+// ChangePassword sets a new password after checking the old one,
+// all the sessions of the user including the current one are revoked.
+func (authService *AuthService) ChangePassword(
+	ctx context.Context,
+	principal domain.Principal,
+	oldPassword string,
+	newPassword string) error {
+	if err := domain.ValidatePassword(newPassword); err != nil {
+		return fmt.Errorf("(service.ChangePassword): %w", err)
+	}
+
+	// an outdated hash is not upgraded here, it is replaced by the new password anyway
+	_, _, err := authService.checkPassword(ctx, domain.UserCredantials{UserName: principal.Name, Password: oldPassword})
+	if err != nil {
+		authService.logger.Infof("failed to check old password (service.ChangePassword): %v", err)
+		return fmt.Errorf("(service.ChangePassword): %w", err)
+	}
+
+	password, err := authService.encodePassword(ctx, newPassword)
+	if err != nil {
+		authService.logger.Errorf("failed to hash password (service.ChangePassword): %v", err)
+		return fmt.Errorf("(service.ChangePassword): %w", err)
+	}
+
+	err = authService.authStorage.UpdatePassword(ctx, principal.Name, password)
+	if err != nil {
+		authService.logger.Errorf("failed to update password (service.ChangePassword): %v", err)
+		return fmt.Errorf("(service.ChangePassword): %w", err)
+	}
+
+	err = authService.revokeUserSessions(ctx, principal.UserId)
+	if err != nil {
+		return fmt.Errorf("(service.ChangePassword): %w", err)
+	}
+
+	authService.logger.Infof("password of %s changed", principal.Name)
+
+	return nil
+}
+
+// CreatePasswordReset issues a one-time token for setting the password of the user,
+// the tokens issued to the user before stop working. The principal has to hold
+// every role of the user, otherwise the token would let it take over a more privileged account.
+func (authService *AuthService) CreatePasswordReset(
+	ctx context.Context,
+	principal domain.Principal,
+	name string) (domain.PasswordResetToken, error) {
+	user, err := authService.authStorage.GetUser(ctx, name)
+	if err != nil {
+		return domain.PasswordResetToken{}, fmt.Errorf("(service.CreatePasswordReset): %w", err)
+	}
+
+	for _, role := range user.Roles {
+		if !slices.Contains(principal.Roles, role) {
+			return domain.PasswordResetToken{}, fmt.Errorf("%w (service.CreatePasswordReset): %s has role %s",
+				customErrors.ErrForbidden, user.Name, role)
+		}
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return domain.PasswordResetToken{}, fmt.Errorf("%w (service.CreatePasswordReset): %w",
+			customErrors.ErrFailedToCreateToken, err)
+	}
+
+	resetToken := domain.PasswordResetToken{
+		Token:     base64.RawURLEncoding.EncodeToString(secret),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(authService.resetTime)),
+	}
+
+	err = authService.authStorage.CreatePasswordReset(ctx, domain.PasswordReset{
+		Hash:      hashToken(resetToken.Token),
+		UserId:    user.Id,
+		ExpiresAt: resetToken.ExpiresAt,
+	})
+	if err != nil {
+		authService.logger.Errorf("failed to store password reset (service.CreatePasswordReset): %v", err)
+		return domain.PasswordResetToken{}, fmt.Errorf("(service.CreatePasswordReset): %w", err)
+	}
+
+	return resetToken, nil
+}
+
+// ResetPassword sets the password by a token from CreatePasswordReset
+// and revokes all the sessions of the user. The token is checked before the password
+// is hashed, so guessing tokens does not take the hashing slots from the logins.
+func (authService *AuthService) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	if resetToken == "" {
+		return fmt.Errorf("%w (service.ResetPassword): no reset token", customErrors.ErrDataNotValid)
+	}
+
+	if err := domain.ValidatePassword(newPassword); err != nil {
+		return fmt.Errorf("(service.ResetPassword): %w", err)
+	}
+
+	hash := hashToken(resetToken)
+
+	_, err := authService.authStorage.GetPasswordReset(ctx, hash)
+	if err != nil {
+		return authService.resetFailed(err)
+	}
+
+	password, err := authService.encodePassword(ctx, newPassword)
+	if err != nil {
+		authService.logger.Errorf("failed to hash password (service.ResetPassword): %v", err)
+		return fmt.Errorf("(service.ResetPassword): %w", err)
+	}
+
+	// the reset is consumed here, it may have been used by a concurrent request since the check
+	user, err := authService.authStorage.ResetPassword(ctx, hash, password)
+	if err != nil {
+		return authService.resetFailed(err)
+	}
+
+	err = authService.revokeUserSessions(ctx, user.Id)
+	if err != nil {
+		return fmt.Errorf("(service.ResetPassword): %w", err)
+	}
+
+	authService.logger.Infof("password of %s reset", user.Name)
+
+	return nil
+}
+
+func (authService *AuthService) resetFailed(err error) error {
+	authService.logger.Infof("failed to reset password (service.ResetPassword): %v", err)
+	if errors.Is(err, customErrors.ErrDataNotValid) {
+		return fmt.Errorf("%w (service.ResetPassword): %w", customErrors.ErrInvalidResetToken, err)
+	}
+
+	return fmt.Errorf("(service.ResetPassword): %w", err)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type ProductStorage interface {
+	CreateProduct(ctx context.Context, actor string, product domain.Product) (domain.Product, error)
+	UpdateProduct(ctx context.Context, name string, update domain.ProductUpdate) (domain.Product, error)
+	GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error)
+}
+
+// ProductService manages the catalog on behalf of admins,
+// every change is recorded in the product audit.
+type ProductService struct {
+	productStorage ProductStorage
+	logger         *zap.SugaredLogger
+}
+
+func NewProductService(productStorage ProductStorage, logger *zap.SugaredLogger) (*ProductService, error) {
+	return &ProductService{
+		productStorage: productStorage,
+		logger:         logger,
+	}, nil
+}
+
+func (productService *ProductService) CreateProduct(
+	ctx context.Context,
+	actor string,
+	product domain.Product) (domain.Product, error) {
+	if err := product.Validate(); err != nil {
+		return domain.Product{}, fmt.Errorf("(service.CreateProduct): %w", err)
+	}
+
+	created, err := productService.productStorage.CreateProduct(ctx, actor, product)
+	if err != nil {
+		productService.logger.Errorf("failed to create product (service.CreateProduct): %v", err)
+		return domain.Product{}, fmt.Errorf("(service.CreateProduct): %w", err)
+	}
+
+	productService.logger.Infof("product %s created by %s", created.Name, actor)
+
+	return created, nil
+}
+
+func (productService *ProductService) UpdateProduct(
+	ctx context.Context,
+	actor string,
+	name string,
+	update domain.ProductUpdate) (domain.Product, error) {
+	update.Actor = actor
+	update.Action = domain.ProductActionUpdate
+
+	return productService.updateProduct(ctx, name, update)
+}
+
+// ArchiveProduct stops the sales of the product, it stays in the inventories
+// of the users who have already bought it.
+func (productService *ProductService) ArchiveProduct(
+	ctx context.Context,
+	actor string,
+	name string) (domain.Product, error) {
+	available := false
+
+	return productService.updateProduct(ctx, name, domain.ProductUpdate{
+		Actor:     actor,
+		Action:    domain.ProductActionArchive,
+		Available: &available,
+	})
+}
+
+func (productService *ProductService) RepriceProduct(
+	ctx context.Context,
+	actor string,
+	name string,
+	price int) (domain.Product, error) {
+	return productService.updateProduct(ctx, name, domain.ProductUpdate{
+		Actor:  actor,
+		Action: domain.ProductActionReprice,
+		Price:  &price,
+	})
+}
+
+func (productService *ProductService) GetProductAudit(ctx context.Context, name string) ([]domain.ProductChange, error) {
+	changes, err := productService.productStorage.GetProductAudit(ctx, name)
+	if err != nil {
+		productService.logger.Errorf("failed to get product audit (service.GetProductAudit): %v", err)
+		return nil, fmt.Errorf("(service.GetProductAudit): %w", err)
+	}
+
+	return changes, nil
+}
+
+func (productService *ProductService) updateProduct(
+	ctx context.Context,
+	name string,
+	update domain.ProductUpdate) (domain.Product, error) {
+	if err := update.Validate(); err != nil {
+		return domain.Product{}, fmt.Errorf("(service.updateProduct): %w", err)
+	}
+
+	updated, err := productService.productStorage.UpdateProduct(ctx, name, update)
+	if err != nil {
+		productService.logger.Errorf("failed to update product (service.updateProduct): %v", err)
+		return domain.Product{}, fmt.Errorf("(service.updateProduct): %w", err)
+	}
+
+	productService.logger.Infof("product %s: %s by %s", name, update.Action, update.Actor)
+
+	return updated, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func TestRepriceProduct(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	productStorage := storageMocks.NewMockProductStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	productService, err := NewProductService(productStorage, logger)
+	if err != nil {
+		log.Fatalf("error in product service initialization: %v\n", err)
+	}
+
+	price := 40
+	productStorage.EXPECT().UpdateProduct(context.Background(), "mug", domain.ProductUpdate{
+		Actor:  "admin",
+		Action: domain.ProductActionReprice,
+		Price:  &price,
+	}).Return(domain.Product{Name: "mug", Price: price, Available: true}, nil)
+
+	testData := []struct {
+		TestName string
+		Price    int
+		Error    error
+	}{
+		{
+			"correct price",
+			40,
+			nil,
+		},
+		{
+			"zero price",
+			0,
+			customErrors.ErrDataNotValid,
+		},
+		{
+			"negative price",
+			-10,
+			customErrors.ErrDataNotValid,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			_, err = productService.RepriceProduct(context.Background(), "admin", "mug", testCase.Price)
+			if !errors.Is(err, testCase.Error) {
+				t.Error(err)
+			}
+		})
+	}
+}
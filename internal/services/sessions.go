@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+type SessionStorage interface {
+	RevokeToken(ctx context.Context, token domain.RevokedToken) error
+	RevokeUserTokens(ctx context.Context, userId int, before time.Time) error
+	GetRevocations(ctx context.Context) (domain.Revocations, error)
+	DeleteExpiredTokens(ctx context.Context, cutoffsBefore time.Time) error
+	CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, hash string, next domain.RefreshToken) (domain.RefreshToken, error)
+	RevokeRefreshFamily(ctx context.Context, familyId string) error
+}
+
+// Logout revokes the token the principal was authenticated with
+// together with the refresh tokens of its session.
+func (authService *AuthService) Logout(ctx context.Context, principal domain.Principal) error {
+	if principal.TokenId == "" {
+		return fmt.Errorf("%w (service.Logout): token without id", customErrors.ErrDataNotValid)
+	}
+
+	token := domain.RevokedToken{
+		Id:        principal.TokenId,
+		UserId:    principal.UserId,
+		ExpiresAt: principal.ExpiresAt,
+	}
+
+	err := authService.sessionStorage.RevokeToken(ctx, token)
+	if err != nil {
+		authService.logger.Errorf("failed to revoke token (service.Logout): %v", err)
+		return fmt.Errorf("(service.Logout): %w", err)
+	}
+
+	authService.revocationsMu.Lock()
+	authService.revocations.Tokens[token.Id] = token.ExpiresAt
+	authService.revocationsMu.Unlock()
+
+	if principal.SessionId != "" {
+		err = authService.sessionStorage.RevokeRefreshFamily(ctx, principal.SessionId)
+		if err != nil {
+			authService.logger.Errorf("failed to revoke refresh tokens (service.Logout): %v", err)
+			return fmt.Errorf("(service.Logout): %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RevokeSessions revokes every token issued to the user so far.
+func (authService *AuthService) RevokeSessions(ctx context.Context, name string) error {
+	user, err := authService.authStorage.GetUser(ctx, name)
+	if err != nil {
+		return fmt.Errorf("(service.RevokeSessions): %w", err)
+	}
+
+	err = authService.revokeUserSessions(ctx, user.Id)
+	if err != nil {
+		return fmt.Errorf("(service.RevokeSessions): %w", err)
+	}
+
+	authService.logger.Infof("all sessions of %s revoked", name)
+
+	return nil
+}
+
+func (authService *AuthService) revokeUserSessions(ctx context.Context, userId int) error {
+	// the storages may round the cutoff to microseconds, rounding it up here
+	// keeps the tokens issued just before it revoked
+	cutoff := time.Now().Truncate(time.Microsecond).Add(time.Microsecond)
+
+	err := authService.sessionStorage.RevokeUserTokens(ctx, userId, cutoff)
+	if err != nil {
+		authService.logger.Errorf("failed to revoke sessions (service.revokeUserSessions): %v", err)
+		return fmt.Errorf("(service.revokeUserSessions): %w", err)
+	}
+
+	authService.revocationsMu.Lock()
+	authService.revocations.Cutoffs[userId] = cutoff
+	authService.revocationsMu.Unlock()
+
+	return nil
+}
+
+func (authService *AuthService) LoadRevocations(ctx context.Context) error {
+	revocations, err := authService.sessionStorage.GetRevocations(ctx)
+	if err != nil {
+		return fmt.Errorf("(service.LoadRevocations): %w", err)
+	}
+
+	if revocations.Tokens == nil {
+		revocations.Tokens = make(map[string]time.Time)
+	}
+	if revocations.Cutoffs == nil {
+		revocations.Cutoffs = make(map[int]time.Time)
+	}
+	if revocations.Sessions == nil {
+		revocations.Sessions = make(map[string]time.Time)
+	}
+
+	authService.revocationsMu.Lock()
+	authService.revocations = revocations
+	authService.revocationsMu.Unlock()
+
+	return nil
+}
+
+// WatchRevocations picks up the sessions revoked by other replicas,
+// until the next reload they can still use their tokens here.
+func (authService *AuthService) WatchRevocations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := authService.sessionStorage.DeleteExpiredTokens(ctx, authService.staleCutoffsBefore())
+			if err != nil {
+				authService.logger.Errorf("failed to delete expired tokens (service.WatchRevocations): %v", err)
+			}
+
+			err = authService.LoadRevocations(ctx)
+			if err != nil {
+				authService.logger.Errorf("failed to reload revocations (service.WatchRevocations): %v", err)
+			}
+		}
+	}
+}
+
+// staleCutoffsBefore is the time the cutoffs and ended sessions older than revoke nothing
+// anymore, since every access token issued before them has expired.
+func (authService *AuthService) staleCutoffsBefore() time.Time {
+	return time.Now().Add(-time.Second*time.Duration(authService.expirationTime) - tokenClockSkew)
+}
+
+func (authService *AuthService) isRevoked(principal domain.Principal) bool {
+	authService.revocationsMu.RLock()
+	defer authService.revocationsMu.RUnlock()
+
+	return authService.revocations.IsRevoked(principal)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
 	"go.uber.org/zap"
@@ -10,19 +11,45 @@ import (
 
 type ShopStorage interface {
 	GetInfo(ctx context.Context, username string) (domain.InventoryInfo, error)
-	SendCoin(ctx context.Context, transaction domain.Transaction) error
-	BuyItem(ctx context.Context, username string, itemName string) error
+	SendCoin(ctx context.Context, transaction domain.Transaction, idempotencyKey domain.IdempotencyKey) error
+	BuyItem(
+		ctx context.Context,
+		username string,
+		itemName string,
+		idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error)
+	BuyCart(
+		ctx context.Context,
+		username string,
+		cart domain.Cart,
+		idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error)
+	GiftItem(ctx context.Context, gift domain.Gift, idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error)
+	RefundPurchase(
+		ctx context.Context,
+		username string,
+		purchaseId int64,
+		boughtAfter time.Time) (domain.RefundReceipt, error)
+	GetHistory(ctx context.Context, username string, filter domain.HistoryFilter) (domain.HistoryPage, error)
+	GetProducts(ctx context.Context) ([]domain.Product, error)
+	GetProduct(ctx context.Context, name string) (domain.Product, error)
 }
 
 type ShopService struct {
-	shopStorage ShopStorage
-	logger      *zap.SugaredLogger
+	shopStorage    ShopStorage
+	logger         *zap.SugaredLogger
+	idempotencyTTL time.Duration
+	refundWindow   time.Duration
 }
 
-func NewShopService(shopStorage ShopStorage, logger *zap.SugaredLogger) (*ShopService, error) {
+func NewShopService(
+	shopStorage ShopStorage,
+	logger *zap.SugaredLogger,
+	idempotencyTTL time.Duration,
+	refundWindow time.Duration) (*ShopService, error) {
 	return &ShopService{
-		shopStorage: shopStorage,
-		logger:      logger,
+		shopStorage:    shopStorage,
+		logger:         logger,
+		idempotencyTTL: idempotencyTTL,
+		refundWindow:   refundWindow,
 	}, nil
 }
 
@@ -36,8 +63,13 @@ func (shopService *ShopService) GetInfo(ctx context.Context, username string) (d
 	return info, nil
 }
 
-func (shopService *ShopService) SendCoin(ctx context.Context, transaction domain.Transaction) error {
-	err := shopService.shopStorage.SendCoin(ctx, transaction)
+func (shopService *ShopService) SendCoin(
+	ctx context.Context,
+	transaction domain.Transaction,
+	idempotencyKey domain.IdempotencyKey) error {
+	idempotencyKey.ExpiresAt = time.Now().Add(shopService.idempotencyTTL)
+
+	err := shopService.shopStorage.SendCoin(ctx, transaction, idempotencyKey)
 	if err != nil {
 		shopService.logger.Errorf("failed to send coins (service.SendCoin): %w", err)
 		return fmt.Errorf("(service.SendCoin): %w", err)
@@ -46,12 +78,99 @@ func (shopService *ShopService) SendCoin(ctx context.Context, transaction domain
 	return nil
 }
 
-func (shopService *ShopService) BuyItem(ctx context.Context, username string, itemName string) error {
-	err := shopService.shopStorage.BuyItem(ctx, username, itemName)
+func (shopService *ShopService) BuyItem(
+	ctx context.Context,
+	username string,
+	itemName string,
+	idempotencyKey domain.IdempotencyKey) (domain.PurchaseReceipt, error) {
+	idempotencyKey.ExpiresAt = time.Now().Add(shopService.idempotencyTTL)
+
+	receipt, err := shopService.shopStorage.BuyItem(ctx, username, itemName, idempotencyKey)
 	if err != nil {
 		shopService.logger.Errorf("failed to buy item (service.BuyItem): %w", err)
-		return fmt.Errorf("(service.BuyItem): %w", err)
+		return domain.PurchaseReceipt{}, fmt.Errorf("(service.BuyItem): %w", err)
 	}
 
-	return nil
+	return receipt, nil
+}
+
+func (shopService *ShopService) BuyCart(
+	ctx context.Context,
+	username string,
+	cart domain.Cart,
+	idempotencyKey domain.IdempotencyKey) (domain.CartReceipt, error) {
+	idempotencyKey.ExpiresAt = time.Now().Add(shopService.idempotencyTTL)
+
+	receipt, err := shopService.shopStorage.BuyCart(ctx, username, cart, idempotencyKey)
+	if err != nil {
+		shopService.logger.Errorf("failed to buy cart (service.BuyCart): %w", err)
+		return domain.CartReceipt{}, fmt.Errorf("(service.BuyCart): %w", err)
+	}
+
+	return receipt, nil
+}
+
+// GiftItem buys the item with the coins of the sender and puts it into the inventory of the recipient.
+func (shopService *ShopService) GiftItem(
+	ctx context.Context,
+	gift domain.Gift,
+	idempotencyKey domain.IdempotencyKey) (domain.GiftReceipt, error) {
+	idempotencyKey.ExpiresAt = time.Now().Add(shopService.idempotencyTTL)
+
+	receipt, err := shopService.shopStorage.GiftItem(ctx, gift, idempotencyKey)
+	if err != nil {
+		shopService.logger.Errorf("failed to gift item (service.GiftItem): %w", err)
+		return domain.GiftReceipt{}, fmt.Errorf("(service.GiftItem): %w", err)
+	}
+
+	return receipt, nil
+}
+
+// RefundPurchase returns the price paid for a purchase made within the refund window
+// and takes the item out of the inventory.
+func (shopService *ShopService) RefundPurchase(
+	ctx context.Context,
+	username string,
+	purchaseId int64) (domain.RefundReceipt, error) {
+	receipt, err := shopService.shopStorage.RefundPurchase(ctx, username, purchaseId,
+		time.Now().Add(-shopService.refundWindow))
+	if err != nil {
+		shopService.logger.Errorf("failed to refund purchase (service.RefundPurchase): %w", err)
+		return domain.RefundReceipt{}, fmt.Errorf("(service.RefundPurchase): %w", err)
+	}
+
+	return receipt, nil
+}
+
+func (shopService *ShopService) GetHistory(
+	ctx context.Context,
+	username string,
+	filter domain.HistoryFilter) (domain.HistoryPage, error) {
+	page, err := shopService.shopStorage.GetHistory(ctx, username, filter)
+	if err != nil {
+		shopService.logger.Errorf("failed to get coin history (service.GetHistory): %w", err)
+		return domain.HistoryPage{}, fmt.Errorf("(service.GetHistory): %w", err)
+	}
+
+	return page, nil
+}
+
+func (shopService *ShopService) GetProducts(ctx context.Context) ([]domain.Product, error) {
+	products, err := shopService.shopStorage.GetProducts(ctx)
+	if err != nil {
+		shopService.logger.Errorf("failed to get products (service.GetProducts): %w", err)
+		return nil, fmt.Errorf("(service.GetProducts): %w", err)
+	}
+
+	return products, nil
+}
+
+func (shopService *ShopService) GetProduct(ctx context.Context, name string) (domain.Product, error) {
+	product, err := shopService.shopStorage.GetProduct(ctx, name)
+	if err != nil {
+		shopService.logger.Errorf("failed to get product (service.GetProduct): %w", err)
+		return domain.Product{}, fmt.Errorf("(service.GetProduct): %w", err)
+	}
+
+	return product, nil
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"log"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"go.uber.org/zap/zaptest"
@@ -22,7 +24,7 @@ func TestGetInfo(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	shopService, err := NewShopService(shopStorage, logger)
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
@@ -66,7 +68,7 @@ func TestSendCoin(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	shopService, err := NewShopService(shopStorage, logger)
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
@@ -107,9 +109,9 @@ func TestSendCoin(t *testing.T) {
 
 	for _, testCase := range testData {
 		t.Run(testCase.TestName, func(t *testing.T) {
-			shopStorage.EXPECT().SendCoin(context.Background(), testCase.Transaction).Return(testCase.Error)
+			shopStorage.EXPECT().SendCoin(context.Background(), testCase.Transaction, gomock.Any()).Return(testCase.Error)
 
-			err = shopService.SendCoin(context.Background(), testCase.Transaction)
+			err = shopService.SendCoin(context.Background(), testCase.Transaction, domain.IdempotencyKey{})
 			if !errors.Is(err, testCase.Error) {
 				t.Error(err)
 			}
@@ -125,7 +127,7 @@ func TestBuyItem(t *testing.T) {
 
 	logger := zaptest.NewLogger(t).Sugar()
 
-	shopService, err := NewShopService(shopStorage, logger)
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, time.Hour)
 	if err != nil {
 		log.Fatalf("error in shop handler initialization: %v\n", err)
 	}
@@ -158,12 +160,152 @@ func TestBuyItem(t *testing.T) {
 
 	for _, testCase := range testData {
 		t.Run(testCase.TestName, func(t *testing.T) {
-			shopStorage.EXPECT().BuyItem(context.Background(), testCase.UserName, testCase.ItemName).Return(testCase.Error)
+			shopStorage.EXPECT().BuyItem(context.Background(), testCase.UserName, testCase.ItemName, gomock.Any()).Return(domain.PurchaseReceipt{}, testCase.Error)
 
-			err = shopService.BuyItem(context.Background(), testCase.UserName, testCase.ItemName)
+			_, err = shopService.BuyItem(context.Background(), testCase.UserName, testCase.ItemName, domain.IdempotencyKey{})
 			if !errors.Is(err, testCase.Error) {
 				t.Error(err)
 			}
 		})
 	}
 }
+
+func TestBuyCart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shopStorage := storageMocks.NewMockShopStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, time.Hour)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+
+	cart := domain.Cart{Lines: []domain.CartLine{{Item: "pen", Quantity: 5}}}
+
+	testData := []struct {
+		TestName string
+		Receipt  domain.CartReceipt
+		Error    error
+	}{
+		{
+			"correct data",
+			domain.CartReceipt{
+				Lines:   []domain.CartReceiptLine{{Item: "pen", Quantity: 5, Price: 10, Cost: 50}},
+				Total:   50,
+				Balance: 950,
+			},
+			nil,
+		},
+		{
+			"user has less money than cart total",
+			domain.CartReceipt{},
+			customErrors.ErrInsufficientFunds,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			shopStorage.EXPECT().BuyCart(context.Background(), "test_user", cart, gomock.Any()).
+				Return(testCase.Receipt, testCase.Error)
+
+			receipt, err := shopService.BuyCart(context.Background(), "test_user", cart, domain.IdempotencyKey{})
+			if !errors.Is(err, testCase.Error) {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(receipt, testCase.Receipt) {
+				t.Errorf("got receipt %v, expected %v", receipt, testCase.Receipt)
+			}
+		})
+	}
+}
+
+func TestGiftItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shopStorage := storageMocks.NewMockShopStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, time.Hour)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+
+	gift := domain.Gift{From: "test_user", To: "test_2_user", Item: "cup"}
+
+	testData := []struct {
+		TestName string
+		Receipt  domain.GiftReceipt
+		Error    error
+	}{
+		{
+			"correct data",
+			domain.GiftReceipt{Id: 7, To: gift.To, Item: gift.Item, Price: 20, Balance: 980},
+			nil,
+		},
+		{
+			"sender has less money than item price",
+			domain.GiftReceipt{},
+			customErrors.ErrInsufficientFunds,
+		},
+		{
+			"recipient does not exist",
+			domain.GiftReceipt{},
+			customErrors.ErrDoesNotExist,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			shopStorage.EXPECT().GiftItem(context.Background(), gift, gomock.Any()).
+				Return(testCase.Receipt, testCase.Error)
+
+			receipt, err := shopService.GiftItem(context.Background(), gift, domain.IdempotencyKey{})
+			if !errors.Is(err, testCase.Error) {
+				t.Error(err)
+			}
+			if receipt != testCase.Receipt {
+				t.Errorf("got receipt %v, expected %v", receipt, testCase.Receipt)
+			}
+		})
+	}
+}
+
+func TestRefundPurchase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	shopStorage := storageMocks.NewMockShopStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	shopService, err := NewShopService(shopStorage, logger, time.Hour, 2*time.Hour)
+	if err != nil {
+		log.Fatalf("error in shop handler initialization: %v\n", err)
+	}
+
+	receipt := domain.RefundReceipt{PurchaseId: 7, Item: "t-shirt", Amount: 80, Balance: 1000}
+
+	// only the purchases made within the refund window are refunded
+	shopStorage.EXPECT().RefundPurchase(context.Background(), "test_user", int64(7), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, username string, purchaseId int64,
+			boughtAfter time.Time) (domain.RefundReceipt, error) {
+			if window := time.Since(boughtAfter); window < 2*time.Hour || window > 2*time.Hour+time.Minute {
+				t.Errorf("got refund window %s, expected 2h", window)
+			}
+
+			return receipt, nil
+		})
+
+	got, err := shopService.RefundPurchase(context.Background(), "test_user", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != receipt {
+		t.Errorf("got receipt %v, expected %v", got, receipt)
+	}
+}
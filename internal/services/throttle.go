@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+)
+
+const (
+	baseLockout = time.Second
+	// maxThrottleEntries bounds the memory taken by the attempts of unknown user names,
+	// the entries that no longer lock anything out are dropped first.
+	maxThrottleEntries = 100000
+)
+
+type throttleEntry struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// LoginThrottle tracks failed logins per user name and per client address.
+// Once the allowed number of failures in a row is exceeded, the key is locked out
+// for a period that doubles with every next failure up to maxLockout.
+// The attempts are counted by each replica on its own.
+type LoginThrottle struct {
+	logger         *zap.SugaredLogger
+	userAttempts   int
+	clientAttempts int
+	maxLockout     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+func NewLoginThrottle(
+	logger *zap.SugaredLogger,
+	userAttempts int,
+	clientAttempts int,
+	maxLockout time.Duration) (*LoginThrottle, error) {
+	return &LoginThrottle{
+		logger:         logger,
+		userAttempts:   userAttempts,
+		clientAttempts: clientAttempts,
+		maxLockout:     maxLockout,
+		entries:        make(map[string]*throttleEntry),
+	}, nil
+}
+
+// Allow reports ErrTooManyRequests if the user or the client is locked out.
+func (throttle *LoginThrottle) Allow(name string, client string) error {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range throttleKeys(name, client) {
+		entry, ok := throttle.entries[key]
+		if ok && entry.lockedUntil.After(now) {
+			return fmt.Errorf("%w (service.Allow): %s locked out for %s", customErrors.ErrTooManyRequests,
+				key, entry.lockedUntil.Sub(now).Round(time.Second))
+		}
+	}
+
+	return nil
+}
+
+func (throttle *LoginThrottle) Failed(name string, client string) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	now := time.Now()
+	if len(throttle.entries) >= maxThrottleEntries {
+		throttle.prune(now)
+	}
+
+	keys := throttleKeys(name, client)
+	for i, attempts := range []int{throttle.userAttempts, throttle.clientAttempts} {
+		entry, ok := throttle.entries[keys[i]]
+		// failures long ago do not count anymore
+		if !ok || now.Sub(entry.lastFailure) > throttle.maxLockout {
+			entry = &throttleEntry{}
+			throttle.entries[keys[i]] = entry
+		}
+
+		entry.failures++
+		entry.lastFailure = now
+
+		if entry.failures <= attempts {
+			continue
+		}
+
+		lockout := throttle.maxLockout
+		if exponent := entry.failures - attempts - 1; exponent < 32 {
+			lockout = min(baseLockout<<exponent, throttle.maxLockout)
+		}
+		entry.lockedUntil = now.Add(lockout)
+
+		throttle.logger.Warnf("login locked out for %s: %s after %d failed attempts", keys[i], lockout, entry.failures)
+	}
+}
+
+func (throttle *LoginThrottle) Succeeded(name string, client string) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	// the client may still guess other users, so only the user is forgiven
+	delete(throttle.entries, throttleKeys(name, client)[0])
+}
+
+func (throttle *LoginThrottle) prune(now time.Time) {
+	for key, entry := range throttle.entries {
+		if !entry.lockedUntil.After(now) {
+			delete(throttle.entries, key)
+		}
+	}
+}
+
+func throttleKeys(name string, client string) [2]string {
+	return [2]string{"user " + name, "client " + client}
+}
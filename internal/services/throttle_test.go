@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	logger := zaptest.NewLogger(t).Sugar()
+
+	throttle, err := NewLoginThrottle(logger, 3, 5, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the allowed failures and the first one above them
+	for i := 0; i < 4; i++ {
+		if err := throttle.Allow("user", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d was rejected: %v", i, err)
+		}
+		throttle.Failed("user", "10.0.0.1")
+	}
+
+	err = throttle.Allow("user", "10.0.0.1")
+	if !errors.Is(err, customErrors.ErrTooManyRequests) {
+		t.Errorf("user was not locked out, got %v", err)
+	}
+	err = throttle.Allow("user", "10.0.0.2")
+	if !errors.Is(err, customErrors.ErrTooManyRequests) {
+		t.Errorf("user was not locked out for another client, got %v", err)
+	}
+	err = throttle.Allow("other", "10.0.0.2")
+	if err != nil {
+		t.Errorf("other user was locked out: %v", err)
+	}
+
+	// every next failure doubles the lockout up to the maximum
+	entry := throttle.entries["user user"]
+	for _, expected := range []time.Duration{2 * time.Second, 4 * time.Second} {
+		throttle.Failed("user", "10.0.0.2")
+		lockout := time.Until(entry.lockedUntil)
+		if lockout <= expected-time.Second || lockout > expected {
+			t.Errorf("got lockout %s, expected %s", lockout, expected)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		throttle.Failed("user", "10.0.0.2")
+	}
+	if time.Until(entry.lockedUntil) > time.Minute {
+		t.Errorf("lockout exceeds the maximum")
+	}
+
+	// the client guessing passwords of many users is locked out as well
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		throttle.Failed(name, "10.0.0.3")
+	}
+	err = throttle.Allow("g", "10.0.0.3")
+	if !errors.Is(err, customErrors.ErrTooManyRequests) {
+		t.Errorf("client was not locked out, got %v", err)
+	}
+
+	entry.lockedUntil = time.Now()
+	throttle.Succeeded("user", "10.0.0.1")
+	if _, ok := throttle.entries["user user"]; ok {
+		t.Errorf("failures of the user were not forgotten after a successful login")
+	}
+}
+
+func TestHashConcurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	authStorage := storageMocks.NewMockAuthStorage(ctrl)
+
+	authService, err := NewAuthService(authStorage, newKeyStorageMock(ctrl),
+		storageMocks.NewMockSessionStorage(ctrl), logger, 10, DefaultPasswordHashParams, 1, 60, 3600, 3600, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	release, err := authService.acquireHashSlot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = authService.encodePassword(ctx, "test_password")
+	if !errors.Is(err, customErrors.ErrTooManyRequests) {
+		t.Errorf("password was hashed with no free slots, got %v", err)
+	}
+
+	// guessed reset tokens are rejected before they wait for a slot
+	authStorage.EXPECT().GetPasswordReset(ctx, gomock.Any()).
+		Return(domain.PasswordReset{}, customErrors.ErrDataNotValid)
+
+	err = authService.ResetPassword(ctx, "guessed_token", "test_password")
+	if !errors.Is(err, customErrors.ErrInvalidResetToken) {
+		t.Errorf("got %v for a guessed reset token, expected %v", err, customErrors.ErrInvalidResetToken)
+	}
+
+	release()
+
+	_, err = authService.encodePassword(ctx, "test_password")
+	if err != nil {
+		t.Errorf("password was not hashed after the slot was released: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+)
+
+type TradeStorage interface {
+	CreateTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error)
+	AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+	DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+	GetTrades(ctx context.Context, username string) ([]domain.Trade, error)
+	GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error)
+}
+
+// TradeService exchanges items and coins between users. A trade is proposed
+// by one user and accepted or declined by the other before it expires.
+type TradeService struct {
+	tradeStorage TradeStorage
+	logger       *zap.SugaredLogger
+	tradeTTL     time.Duration
+}
+
+func NewTradeService(
+	tradeStorage TradeStorage,
+	logger *zap.SugaredLogger,
+	tradeTTL time.Duration) (*TradeService, error) {
+	return &TradeService{
+		tradeStorage: tradeStorage,
+		logger:       logger,
+		tradeTTL:     tradeTTL,
+	}, nil
+}
+
+func (tradeService *TradeService) ProposeTrade(ctx context.Context, offer domain.TradeOffer) (domain.Trade, error) {
+	offer.ExpiresAt = time.Now().Add(tradeService.tradeTTL)
+
+	trade, err := tradeService.tradeStorage.CreateTrade(ctx, offer)
+	if err != nil {
+		tradeService.logger.Errorf("failed to propose trade (service.ProposeTrade): %v", err)
+		return domain.Trade{}, fmt.Errorf("(service.ProposeTrade): %w", err)
+	}
+
+	return trade, nil
+}
+
+func (tradeService *TradeService) AcceptTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	trade, err := tradeService.tradeStorage.AcceptTrade(ctx, username, tradeId)
+	if err != nil {
+		tradeService.logger.Errorf("failed to accept trade (service.AcceptTrade): %v", err)
+		return domain.Trade{}, fmt.Errorf("(service.AcceptTrade): %w", err)
+	}
+
+	tradeService.logger.Infof("trade %d between %s and %s accepted", trade.Id, trade.From, trade.To)
+
+	return trade, nil
+}
+
+func (tradeService *TradeService) DeclineTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	trade, err := tradeService.tradeStorage.DeclineTrade(ctx, username, tradeId)
+	if err != nil {
+		tradeService.logger.Errorf("failed to decline trade (service.DeclineTrade): %v", err)
+		return domain.Trade{}, fmt.Errorf("(service.DeclineTrade): %w", err)
+	}
+
+	return trade, nil
+}
+
+func (tradeService *TradeService) GetTrades(ctx context.Context, username string) ([]domain.Trade, error) {
+	trades, err := tradeService.tradeStorage.GetTrades(ctx, username)
+	if err != nil {
+		tradeService.logger.Errorf("failed to get trades (service.GetTrades): %v", err)
+		return nil, fmt.Errorf("(service.GetTrades): %w", err)
+	}
+
+	return trades, nil
+}
+
+func (tradeService *TradeService) GetTrade(ctx context.Context, username string, tradeId int64) (domain.Trade, error) {
+	trade, err := tradeService.tradeStorage.GetTrade(ctx, username, tradeId)
+	if err != nil {
+		tradeService.logger.Errorf("failed to get trade (service.GetTrade): %v", err)
+		return domain.Trade{}, fmt.Errorf("(service.GetTrade): %w", err)
+	}
+
+	return trade, nil
+}
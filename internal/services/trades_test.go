@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/UserNameShouldBeHere/AvitoTask/internal/domain"
+	customErrors "github.com/UserNameShouldBeHere/AvitoTask/internal/errors"
+	storageMocks "github.com/UserNameShouldBeHere/AvitoTask/internal/infrastructure/mocks"
+)
+
+func TestProposeTrade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tradeStorage := storageMocks.NewMockTradeStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	tradeService, err := NewTradeService(tradeStorage, logger, time.Hour)
+	if err != nil {
+		log.Fatalf("error in trade service initialization: %v\n", err)
+	}
+
+	offer := domain.TradeOffer{
+		From:      "test_user",
+		To:        "test_2_user",
+		Offered:   domain.TradeSide{Coins: 30},
+		Requested: domain.TradeSide{Items: []domain.TradeItem{{Item: "cup", Quantity: 1}}},
+	}
+
+	testData := []struct {
+		TestName string
+		Trade    domain.Trade
+		Error    error
+	}{
+		{
+			"correct data",
+			domain.Trade{Id: 3, From: offer.From, To: offer.To, Status: domain.TradeStatusPending},
+			nil,
+		},
+		{
+			"proposer has less money than offered",
+			domain.Trade{},
+			customErrors.ErrInsufficientFunds,
+		},
+		{
+			"recipient does not exist",
+			domain.Trade{},
+			customErrors.ErrDoesNotExist,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			tradeStorage.EXPECT().CreateTrade(context.Background(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, proposed domain.TradeOffer) (domain.Trade, error) {
+					expiresAt := time.Now().Add(time.Hour)
+					if proposed.ExpiresAt.After(expiresAt) || proposed.ExpiresAt.Before(expiresAt.Add(-time.Minute)) {
+						t.Errorf("got expiration %v, expected about %v", proposed.ExpiresAt, expiresAt)
+					}
+
+					return testCase.Trade, testCase.Error
+				})
+
+			trade, err := tradeService.ProposeTrade(context.Background(), offer)
+			if !errors.Is(err, testCase.Error) {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(trade, testCase.Trade) {
+				t.Errorf("got trade %v, expected %v", trade, testCase.Trade)
+			}
+		})
+	}
+}
+
+func TestAcceptTrade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tradeStorage := storageMocks.NewMockTradeStorage(ctrl)
+
+	logger := zaptest.NewLogger(t).Sugar()
+
+	tradeService, err := NewTradeService(tradeStorage, logger, time.Hour)
+	if err != nil {
+		log.Fatalf("error in trade service initialization: %v\n", err)
+	}
+
+	testData := []struct {
+		TestName string
+		UserName string
+		Trade    domain.Trade
+		Error    error
+	}{
+		{
+			"correct data",
+			"test_2_user",
+			domain.Trade{Id: 3, From: "test_user", To: "test_2_user", Status: domain.TradeStatusAccepted},
+			nil,
+		},
+		{
+			"accepted by the proposer",
+			"test_user",
+			domain.Trade{},
+			customErrors.ErrForbidden,
+		},
+		{
+			"recipient no longer has the items",
+			"test_2_user",
+			domain.Trade{},
+			customErrors.ErrNotAvailable,
+		},
+		{
+			"trade already resolved",
+			"test_2_user",
+			domain.Trade{},
+			customErrors.ErrAlreadyExists,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.TestName, func(t *testing.T) {
+			tradeStorage.EXPECT().AcceptTrade(context.Background(), testCase.UserName, int64(3)).
+				Return(testCase.Trade, testCase.Error)
+
+			trade, err := tradeService.AcceptTrade(context.Background(), testCase.UserName, 3)
+			if !errors.Is(err, testCase.Error) {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(trade, testCase.Trade) {
+				t.Errorf("got trade %v, expected %v", trade, testCase.Trade)
+			}
+		})
+	}
+}